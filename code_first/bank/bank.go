@@ -4,200 +4,501 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"strconv"
+	"maps"
+	"net/url"
+	"slices"
 	"strings"
 	"time"
 )
 
 type TransactionType string
+type TransactionStatus string
 type AccountType string
 
 const (
 	Deposit  TransactionType = "deposit"
 	Withdraw TransactionType = "withdraw"
 	Transfer TransactionType = "transfer"
+	Fee      TransactionType = "fee"
+	Reversal TransactionType = "reversal"
+	Interest TransactionType = "interest"
+	Exchange TransactionType = "exchange"
 	Giro     AccountType     = "giro"
 	Savings  AccountType     = "savings"
+
+	StatusBooked   TransactionStatus = "booked"
+	StatusReversed TransactionStatus = "reversed"
 )
 
+var ErrInsufficientFunds = errors.New("Insufficient funds")
+
 type Transactions struct {
-	Time   time.Time
-	Amount float64
-	Type   TransactionType
+	Id           string `json:",omitempty"`
+	Time         time.Time
+	Amount       Money
+	Type         TransactionType
+	Counterparty string            `json:",omitempty"`
+	Reference    string            `json:",omitempty"`
+	Status       TransactionStatus `json:",omitempty"`
+	Reverses     string            `json:",omitempty"`
+	ReversedBy   string            `json:",omitempty"`
+	Rate         string            `json:",omitempty"`
+	RateDate     time.Time         `json:",omitzero"`
 }
 
 type Account struct {
-	Id           string
-	Name         string
-	Balance      float64
-	Overdraw     float64
+	Id      string
+	Name    string
+	IBAN    IBAN `json:",omitempty"`
+	Balance Money
+	// SubBalances holds money in currencies other than the base currency
+	// of Balance. They cannot be overdrawn.
+	SubBalances  map[Currency]Money `json:",omitempty"`
+	Overdraw     Money
 	AccountType  AccountType
+	Owner        string        `json:",omitempty"`
+	Status       AccountStatus `json:",omitempty"`
 	Transactions []Transactions
+
+	pending []Event
 }
 
 var initialAccounts = []Account{
 	{
 		Id:          "002",
 		Name:        "Alice",
-		Overdraw:    100.0,
-		Balance:     1000.0,
+		Overdraw:    NewMoney(10000, DefaultCurrency),
+		Balance:     NewMoney(100000, DefaultCurrency),
 		AccountType: Giro,
 	},
 	{
 		Id:          "003",
 		Name:        "Bob",
-		Overdraw:    100.0,
-		Balance:     500.0,
+		Overdraw:    NewMoney(10000, DefaultCurrency),
+		Balance:     NewMoney(50000, DefaultCurrency),
 		AccountType: Savings,
 	},
 }
 
-func InitialAccounts() {
+func InitialAccounts() error {
 	for _, acc := range initialAccounts {
-		AddOrUpdateAcc(&acc)
+		if err := OpenAccount(&acc); err != nil && !errors.Is(err, ErrAccountExists) {
+			return err
+		}
 	}
+	return nil
 }
 
-func (account *Account) Deposit(amount float64) error {
-	if amount <= 0 {
-		return fmt.Errorf("Amount should be larger then 0")
-	}
-
-	account.Balance += amount
+func NewAccountID() string {
+	return newID("acc")
+}
 
-	account.addTransaction(amount, Deposit)
-	AddOrUpdateAcc(account)
+// checkAccountID rejects ids of the ledger's internal accounts and ids that
+// could be mistaken for an IBAN, which would let an account catch transfers
+// meant for the IBAN's holder.
+func checkAccountID(id string) error {
+	if strings.TrimSpace(id) == "" {
+		return fmt.Errorf("%w: no id given", ErrInvalidAccountID)
+	}
+	if IsInternalAccount(id) {
+		return fmt.Errorf("%w: %s is reserved for the ledger", ErrInvalidAccountID, id)
+	}
+	if _, err := ParseIBAN(id); err == nil {
+		return fmt.Errorf("%w: %s is an IBAN", ErrInvalidAccountID, id)
+	}
 	return nil
 }
 
-func (account *Account) Withdraw(amount float64) error {
-	if amount <= 0 {
-		return fmt.Errorf("amount should be larger then 0")
+func OpenAccount(account *Account) error {
+	if err := checkAccountID(account.Id); err != nil {
+		return err
 	}
 
-	overdraw := 0.0
-	if account.AccountType == Giro {
-		overdraw = -account.Overdraw
-	}
+	unlock := lockAccounts(account.Id)
+	defer unlock()
 
-	if account.Balance-amount < overdraw {
-		return fmt.Errorf("Insufficient funds")
+	if _, err := store.Get(account.Id); err == nil {
+		return ErrAccountExists
+	} else if !errors.Is(err, ErrAccountNotFound) {
+		return err
 	}
 
-	account.Balance -= amount
-
-	account.addTransaction(amount, Withdraw)
-	AddOrUpdateAcc(account)
-	return nil
-}
+	taken, err := takenIBANs()
+	if err != nil {
+		return err
+	}
+	switch {
+	case account.IBAN == "":
+		if account.IBAN, err = issuer.newIBAN(account.Id, taken); err != nil {
+			return err
+		}
+	case taken[account.IBAN]:
+		return fmt.Errorf("%w: IBAN %s", ErrAccountExists, account.IBAN)
+	}
 
-func (account *Account) Transfer(amount float64, to string) error {
-	if amount <= 0 {
-		return fmt.Errorf("Amount should be larger then 0")
+	if account.Balance.IsZero() {
+		return account.save()
 	}
 
-	if account.Balance-amount < 0 {
-		return fmt.Errorf("Insufficient funds")
+	side, opening := Credit, account.Balance
+	if opening.IsNegative() {
+		side, opening = Debit, opening.Neg()
 	}
 
-	recipientAcc, err := searchingAcc(to)
+	return postAndSave(NewEntry("opening balance "+account.Id,
+		Leg{Account: OpeningBalanceAccount, Side: opposite(side), Amount: opening},
+		Leg{Account: account.Id, Side: side, Amount: opening},
+	), account)
+}
+
+// AssignOwner hands the account over to the customer with the given id.
+func AssignOwner(accountID, customerID string) error {
+	account, err := store.Get(accountID)
 	if err != nil {
-		return fmt.Errorf("unexcepteced error: %v\n", err)
+		return err
 	}
 
-	account.Balance -= amount
-	recipientAcc.Balance += amount
+	return update(func() error {
+		if err := account.record(Event{Type: OwnerChanged, Owner: customerID}); err != nil {
+			return err
+		}
+		return account.save()
+	}, account)
+}
 
-	account.addTransaction(amount, Transfer)
-	recipientAcc.addTransaction(amount, Transfer)
-	AddOrUpdateAcc(account)
-	AddOrUpdateAcc(recipientAcc)
-	return nil
+// Receipt is the account as a booking left it and the transactions the
+// booking added to it, fees included.
+type Receipt struct {
+	Account      Account
+	Transactions []Transactions
 }
 
-func (account *Account) ShowAccountDetails(w io.Writer, name string, criteria, filter string) error {
-	acc := account
+// receipt collects the transactions booked on the account after the first
+// booked ones. The caller holds the account lock.
+func (account *Account) receipt(booked int) *Receipt {
+	snapshot := account.clone()
+	return &Receipt{Account: snapshot, Transactions: slices.Clone(snapshot.Transactions[booked:])}
+}
 
-	if name != "" {
-		var err error
-		acc, err = searchingAcc(name)
+// Deposit books amount onto the account. An optional reference text is
+// stored with the transaction, the same holds for Withdraw and Transfer.
+func (account *Account) Deposit(amount Money, reference ...string) error {
+	_, err := account.BookDeposit(amount, reference...)
+	return err
+}
+
+// BookDeposit deposits like Deposit and returns the receipt of the booking.
+func (account *Account) BookDeposit(amount Money, reference ...string) (*Receipt, error) {
+	if err := checkAmount(amount); err != nil {
+		return nil, err
+	}
+
+	var receipt *Receipt
+	err := update(func() error {
+		booked := len(account.Transactions)
+		amount, err := account.checkCredit(amount)
 		if err != nil {
-			return fmt.Errorf("unexcepteced error: %v\n", err)
+			return err
 		}
-	}
 
-	fmt.Fprintf(w, "Balance: %.2f\n", acc.Balance)
-	for _, txn := range acc.Transactions {
-		if filterTo(txn, criteria, filter) {
-			fmt.Fprintf(w, "Time: %v, Amount: %.2f, Type: %v\n",
-				txn.Time, txn.Amount, txn.Type)
+		txID := newTransactionID()
+		err = account.record(Event{Type: Deposited, Amount: amount, TransactionID: txID, Reference: referenceText(reference)})
+		if err != nil {
+			return err
+		}
+
+		entry := NewEntry("deposit "+account.Id,
+			Leg{Account: CashAccount, Side: Debit, Amount: amount},
+			Leg{Account: account.Id, Side: Credit, Amount: amount},
+		)
+		entry.TransactionID = txID
+
+		entries, err := account.addTransactionFee([]JournalEntry{entry}, fees.transactionFee(account, Deposit), Deposit)
+		if err != nil {
+			return err
+		}
+		if err := postAllAndSave(entries, account); err != nil {
+			return err
 		}
+		receipt = account.receipt(booked)
+		return nil
+	}, account)
+	return receipt, err
+}
+
+func (account *Account) Withdraw(amount Money, reference ...string) error {
+	_, err := account.BookWithdrawal(amount, reference...)
+	return err
+}
+
+// BookWithdrawal withdraws like Withdraw and returns the receipt of the
+// booking.
+func (account *Account) BookWithdrawal(amount Money, reference ...string) (*Receipt, error) {
+	if err := checkAmount(amount); err != nil {
+		return nil, err
 	}
 
-	return nil
+	var receipt *Receipt
+	err := update(func() error {
+		booked := len(account.Transactions)
+		fee := fees.transactionFee(account, Withdraw)
+		amount, err := account.checkDebit(amount, fee)
+		if err != nil {
+			return err
+		}
+
+		txID := newTransactionID()
+		err = account.record(Event{Type: Withdrawn, Amount: amount, TransactionID: txID, Reference: referenceText(reference)})
+		if err != nil {
+			return err
+		}
+
+		entry := NewEntry("withdraw "+account.Id,
+			Leg{Account: account.Id, Side: Debit, Amount: amount},
+			Leg{Account: CashAccount, Side: Credit, Amount: amount},
+		)
+		entry.TransactionID = txID
+
+		entries, err := account.addTransactionFee([]JournalEntry{entry}, fee, Withdraw)
+		if err != nil {
+			return err
+		}
+		if err := postAllAndSave(entries, account); err != nil {
+			return err
+		}
+		receipt = account.receipt(booked)
+		return nil
+	}, account)
+	return receipt, err
 }
 
-var loadAccFunc func() ([]Account, error) = LoadAcc
+// Transfer moves amount to the account with the id, IBAN or name to. The
+// recipient is checked before anything is debited and the sender may use the
+// same overdraft as for a withdrawal. IBANs of other banks are queued as
+// SEPA credit transfers.
+func (account *Account) Transfer(amount Money, to string, reference ...string) error {
+	_, err := account.BookTransfer(amount, to, reference...)
+	return err
+}
+
+// BookTransfer transfers like Transfer and returns the receipt of the
+// sender.
+func (account *Account) BookTransfer(amount Money, to string, reference ...string) (*Receipt, error) {
+	if err := checkAmount(amount); err != nil {
+		return nil, err
+	}
+	if iban, err := ParseIBAN(to); err == nil && !issuer.Issues(iban) {
+		return account.BookSEPATransfer(amount, Party{IBAN: iban}, reference...)
+	}
 
-func searchingAcc(name string) (*Account, error) {
-	accounts, err := loadAccFunc()
+	recipientAcc, err := findRecipient(to)
 	if err != nil {
 		return nil, err
 	}
+	if recipientAcc.Id == account.Id {
+		return nil, ErrSelfTransfer
+	}
+
+	var receipt *Receipt
+	err = update(func() error {
+		booked := len(account.Transactions)
+		if err := recipientAcc.checkActive(); err != nil {
+			return err
+		}
 
-	for i, account := range accounts {
-		if strings.EqualFold(account.Name, name) {
-			return &accounts[i], nil
+		fee := fees.transactionFee(account, Transfer)
+		amount, err := account.checkDebit(amount, fee)
+		if err != nil {
+			return err
+		}
+		if _, err := recipientAcc.checkCredit(amount); err != nil {
+			return err
+		}
+
+		txID, ref := newTransactionID(), referenceText(reference)
+		err = account.record(Event{Type: TransferredOut, Amount: amount, Counterparty: recipientAcc.Id, TransactionID: txID, Reference: ref})
+		if err != nil {
+			return err
 		}
-		continue
+
+		err = recipientAcc.record(Event{Type: TransferredIn, Amount: amount, Counterparty: account.Id, TransactionID: txID, Reference: ref})
+		if err != nil {
+			return err
+		}
+
+		entry := NewEntry("transfer "+account.Id+" -> "+recipientAcc.Id,
+			Leg{Account: account.Id, Side: Debit, Amount: amount},
+			Leg{Account: recipientAcc.Id, Side: Credit, Amount: amount},
+		)
+		entry.TransactionID = txID
+
+		entries, err := account.addTransactionFee([]JournalEntry{entry}, fee, Transfer)
+		if err != nil {
+			return err
+		}
+		if err := postAllAndSave(entries, account, recipientAcc); err != nil {
+			return err
+		}
+		receipt = account.receipt(booked)
+		return nil
+	}, account, recipientAcc)
+	return receipt, err
+}
+
+// addTransactionFee charges the fee for a transaction of type tt and
+// returns entries with the booking of the fee appended.
+func (account *Account) addTransactionFee(entries []JournalEntry, fee Money, tt TransactionType) ([]JournalEntry, error) {
+	if !fee.IsPositive() {
+		return entries, nil
+	}
+
+	entry, err := account.chargeFee(fee, string(tt)+" fee", time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	return append(entries, entry), nil
+}
+
+func (account *Account) save() error {
+	return saveAll(account)
+}
+
+func saveAll(accounts ...*Account) error {
+	if err := store.SaveAll(accounts...); err != nil {
+		return err
+	}
+	for _, acc := range accounts {
+		acc.pending = nil
+	}
+	return nil
+}
+
+func (account *Account) Snapshot() (*Account, error) {
+	var snapshot Account
+	err := update(func() error {
+		snapshot = account.clone()
+		return nil
+	}, account)
+	return &snapshot, err
+}
+
+func (account *Account) overdrawLimit() int64 {
+	if account.AccountType != Giro {
+		return 0
+	}
+	return -account.Overdraw.Minor
+}
+
+// BaseCurrency is the currency of Balance, accounts stored without one are
+// in DefaultCurrency.
+func (account *Account) BaseCurrency() Currency {
+	if account.Balance.Currency == "" {
+		return DefaultCurrency
 	}
+	return account.Balance.Currency
+}
 
-	return nil, errors.New("could not find account")
+// BalanceIn returns the balance held in currency, zero if the account holds
+// none. An empty currency means the base currency.
+func (account *Account) BalanceIn(currency Currency) Money {
+	if account.isBase(currency) {
+		return account.Balance.WithCurrency(account.BaseCurrency())
+	}
+	if balance, ok := account.SubBalances[currency]; ok {
+		return balance
+	}
+	return NewMoney(0, currency)
 }
 
-func (account *Account) addTransaction(amount float64, tt TransactionType) {
-	account.Transactions = append(account.Transactions, Transactions{
-		Time:   time.Now(),
-		Amount: amount,
-		Type:   tt,
-	})
+func (account *Account) isBase(currency Currency) bool {
+	return currency == "" || currency == account.BaseCurrency()
 }
 
-func filterTo(txn Transactions, criteria, filter string) bool {
-	switch strings.ToLower(criteria) {
-	case "type":
-		return txn.Type == TransactionType(filter)
+// limitIn is the lowest balance allowed in currency, only the base currency
+// can be overdrawn.
+func (account *Account) limitIn(currency Currency) int64 {
+	if account.isBase(currency) {
+		return account.overdrawLimit()
+	}
+	return 0
+}
 
-	case "amount":
-		amount, err := strconv.ParseFloat(filter, 64)
+// adjust adds delta to the balance in its currency and opens a sub-balance
+// for a currency the account did not hold before.
+func (account *Account) adjust(delta Money) error {
+	if account.isBase(delta.Currency) {
+		balance, err := account.Balance.Add(delta)
 		if err != nil {
-			return false
+			return err
 		}
-		return txn.Amount == amount
+		account.Balance = balance
+		return nil
+	}
 
-	case "day":
-		day, err := strconv.Atoi(filter)
+	balance, err := account.BalanceIn(delta.Currency).Add(delta)
+	if err != nil {
+		return err
+	}
+	if account.SubBalances == nil {
+		account.SubBalances = map[Currency]Money{}
+	}
+	account.SubBalances[delta.Currency] = balance
+	return nil
+}
+
+func (account *Account) ShowAccountDetails(w io.Writer, name string, criteria, filter string) error {
+	query, err := ParseTransactionQuery(url.Values{"criteria": {criteria}, "filter": {filter}})
+	if err != nil {
+		return err
+	}
+	return account.ShowTransactions(w, name, query)
+}
+
+// ShowTransactions prints the balances and the transactions selected by
+// query of the account, or of the account held by name.
+func (account *Account) ShowTransactions(w io.Writer, name string, query TransactionQuery) error {
+	var acc *Account
+
+	if name != "" {
+		var err error
+		acc, err = store.FindByName(name)
 		if err != nil {
-			return false
+			return fmt.Errorf("unexcepteced error: %v\n", err)
 		}
-		return txn.Time.Day() == day
-
-	case "month":
-		month, err := strconv.Atoi(filter)
+	} else {
+		var err error
+		acc, err = account.Snapshot()
 		if err != nil {
-			return false
+			return err
 		}
-		return txn.Time.Month() == time.Month(month)
+	}
 
-	case "year":
-		year, err := strconv.Atoi(filter)
-		if err != nil {
-			return false
+	transactions, _ := query.Run(acc.Transactions)
+	return acc.WriteSummary(w, transactions)
+}
+
+// WriteSummary prints the balances of the account followed by transactions,
+// one per line.
+func (account *Account) WriteSummary(w io.Writer, transactions []Transactions) error {
+	if _, err := fmt.Fprintf(w, "Balance: %s\n", account.Balance.Decimal()); err != nil {
+		return err
+	}
+	for _, currency := range slices.Sorted(maps.Keys(account.SubBalances)) {
+		fmt.Fprintf(w, "Balance %s: %s\n", currency.Code(), account.SubBalances[currency].Decimal())
+	}
+	for _, txn := range transactions {
+		amount := txn.Amount.Decimal()
+		if !account.isBase(txn.Amount.Currency) {
+			amount = txn.Amount.String()
+		}
+		fmt.Fprintf(w, "Time: %v, Amount: %s, Type: %v",
+			txn.Time, amount, txn.Type)
+		if txn.Reference != "" {
+			fmt.Fprintf(w, ", Reference: %s", txn.Reference)
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
 		}
-		return txn.Time.Year() == year
-	case "":
-		return true
-	default:
-		return false
 	}
+	return nil
 }
@@ -2,14 +2,38 @@ package bank
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"math"
+	"os"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 )
 
+func TestMain(m *testing.M) {
+	SetStore(NewMemoryStore())
+	os.Exit(m.Run())
+}
+
+func eur(amount float64) Money {
+	return NewMoney(int64(math.Round(amount*100)), EUR)
+}
+
+func useStore(t *testing.T, accounts ...Account) *MemoryStore {
+	t.Helper()
+
+	original := store
+	ms := NewMemoryStore(accounts...)
+	SetStore(ms)
+	t.Cleanup(func() { SetStore(original) })
+	return ms
+}
+
 func TestDeposit(t *testing.T) {
+	useStore(t)
+
 	deposit_test := map[string]struct {
 		amount      float64
 		wantBalance float64
@@ -36,8 +60,8 @@ func TestDeposit(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			acc := &Account{}
-			err := acc.Deposit(tc.amount)
+			acc := &Account{Id: name}
+			err := acc.Deposit(eur(tc.amount))
 
 			if tc.wantError {
 				if err == nil {
@@ -47,7 +71,7 @@ func TestDeposit(t *testing.T) {
 				if err != nil {
 					t.Errorf("unexpected error: %v\n", err)
 				}
-				if acc.Balance != tc.wantBalance {
+				if acc.Balance != eur(tc.wantBalance) {
 					t.Errorf("balance = %v, want %v", acc.Balance, tc.wantBalance)
 				}
 			}
@@ -56,6 +80,8 @@ func TestDeposit(t *testing.T) {
 }
 
 func TestWithdraw(t *testing.T) {
+	useStore(t)
+
 	withdraw_test := map[string]struct {
 		amount      float64
 		overdraw    float64
@@ -112,13 +138,13 @@ func TestWithdraw(t *testing.T) {
 			t.Parallel()
 
 			acc := &Account{
-				Id:          "TestTest",
+				Id:          name,
 				Name:        "TestTest",
-				Balance:     550,
-				Overdraw:    tc.overdraw,
+				Balance:     eur(550),
+				Overdraw:    eur(tc.overdraw),
 				AccountType: tc.accountType,
 			}
-			err := acc.Withdraw(tc.amount)
+			err := acc.Withdraw(eur(tc.amount))
 
 			if tc.wantError {
 				if err == nil {
@@ -128,7 +154,7 @@ func TestWithdraw(t *testing.T) {
 				if err != nil {
 					t.Errorf("unexpected error: %v\n", err)
 				}
-				if acc.Balance != tc.wantBalance {
+				if acc.Balance != eur(tc.wantBalance) {
 					t.Errorf("balance = %v, want = %v\n", acc.Balance, tc.wantBalance)
 				}
 			}
@@ -138,19 +164,15 @@ func TestWithdraw(t *testing.T) {
 
 func TestShowAccount(t *testing.T) {
 	time := time.Now()
-	loadAccFunc = func() ([]Account, error) {
-		return []Account{
-			{
-				Name:        "TestTest",
-				Balance:     50,
-				AccountType: Giro,
-				Transactions: []Transactions{
-					{Time: time, Amount: 50, Type: Withdraw},
-				},
-			},
-		}, nil
-	}
-	defer func() { loadAccFunc = LoadAcc }()
+	useStore(t, Account{
+		Id:          "TestTest",
+		Name:        "TestTest",
+		Balance:     eur(50),
+		AccountType: Giro,
+		Transactions: []Transactions{
+			{Time: time, Amount: eur(50), Type: Withdraw},
+		},
+	})
 
 	show_account := map[string]struct {
 		person       string
@@ -179,13 +201,13 @@ func TestShowAccount(t *testing.T) {
 			acc := Account{
 				Id:          "TestTest",
 				Name:        "TestTest",
-				Balance:     50,
+				Balance:     eur(50),
 				AccountType: Giro,
 			}
 
 			txn := Transactions{
 				Time:   time,
-				Amount: tc.withdraw,
+				Amount: eur(tc.withdraw),
 				Type:   Withdraw,
 			}
 
@@ -209,19 +231,15 @@ func TestShowAccount(t *testing.T) {
 
 func TestFilterTransactions(t *testing.T) {
 	time := time.Now()
-	loadAccFunc = func() ([]Account, error) {
-		return []Account{
-			{
-				Name:        "TestTest",
-				Balance:     50,
-				AccountType: Giro,
-				Transactions: []Transactions{
-					{Time: time, Amount: 50, Type: Withdraw},
-				},
-			},
-		}, nil
-	}
-	defer func() { loadAccFunc = LoadAcc }()
+	useStore(t, Account{
+		Id:          "TestTest",
+		Name:        "TestTest",
+		Balance:     eur(50),
+		AccountType: Giro,
+		Transactions: []Transactions{
+			{Time: time, Amount: eur(50), Type: Withdraw},
+		},
+	})
 
 	tests := map[string]struct {
 		criteria    string
@@ -274,11 +292,11 @@ func TestFilterTransactions(t *testing.T) {
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			acc := Account{
-				Balance: 100.00,
+				Balance: eur(100.00),
 				Transactions: []Transactions{
 					{
 						Time:   time,
-						Amount: 50.00,
+						Amount: eur(50.00),
 						Type:   TransactionType(tc.transaction),
 					},
 				},
@@ -297,16 +315,6 @@ func TestFilterTransactions(t *testing.T) {
 }
 
 func TestAccountTransfer(t *testing.T) {
-	var testAccounts []Account
-
-	loadAccFunc = func() ([]Account, error) {
-		return []Account{
-			{Id: "1", Name: "Alice", Balance: 1000, AccountType: Giro},
-			{Id: "2", Name: "Bob", Balance: 500, AccountType: Giro},
-		}, nil
-	}
-	defer func() { loadAccFunc = LoadAcc }()
-
 	tests := []struct {
 		name        string
 		from        string
@@ -347,57 +355,40 @@ func TestAccountTransfer(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			testAccounts = []Account{
-				{Id: "1", Name: "Alice", Balance: 1000, AccountType: Giro},
-				{Id: "2", Name: "Bob", Balance: 500, AccountType: Giro},
-			}
+			ms := useStore(t,
+				Account{Id: "1", Name: "Alice", Balance: eur(1000), AccountType: Giro},
+				Account{Id: "2", Name: "Bob", Balance: eur(500), AccountType: Giro},
+			)
 
-			originalSearchingAcc := searchingAcc
-			searchingAcc := func(name string) (*Account, error) {
-				for i := range testAccounts {
-					if testAccounts[i].Name == name {
-						return &testAccounts[i], nil
-					}
-				}
-				return nil, fmt.Errorf("could not find account: %s", name)
-			}
-			defer func() { searchingAcc = originalSearchingAcc }()
-
-			var fromAcc *Account
-			for i := range testAccounts {
-				if testAccounts[i].Name == tt.from {
-					fromAcc = &testAccounts[i]
-					break
-				}
-			}
-			if fromAcc == nil {
+			fromAcc, err := ms.FindByName(tt.from)
+			if err != nil {
 				t.Fatalf("From account '%s' not found", tt.from)
 			}
 
-			err := fromAcc.Transfer(tt.amount, tt.to)
+			err = fromAcc.Transfer(eur(tt.amount), tt.to)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Transfer() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 
-			if fromAcc.Balance != tt.wantFromBal {
-				t.Errorf("from account (%s) balance = %.2f, want %.2f", tt.from, fromAcc.Balance, tt.wantFromBal)
+			if fromAcc.Balance != eur(tt.wantFromBal) {
+				t.Errorf("from account (%s) balance = %v, want %.2f", tt.from, fromAcc.Balance, tt.wantFromBal)
 			}
 
 			if tt.to != "Charlie" {
-				toAcc, searchErr := searchingAcc(tt.to)
+				toAcc, searchErr := ms.FindByName(tt.to)
 				if searchErr != nil {
 					t.Errorf("Error finding to account after transfer: %v", searchErr)
 				} else {
-					if toAcc.Balance != tt.wantToBal {
-						t.Errorf("to account (%s) balance = %.2f, want %.2f", tt.to, toAcc.Balance, tt.wantToBal)
+					if toAcc.Balance != eur(tt.wantToBal) {
+						t.Errorf("to account (%s) balance = %v, want %.2f", tt.to, toAcc.Balance, tt.wantToBal)
 					}
 				}
 			}
 
 			if !tt.wantErr {
-				t.Logf("Transfer successful: %s (%.2f) -> %s, amount: %.2f",
+				t.Logf("Transfer successful: %s (%v) -> %s, amount: %.2f",
 					tt.from, fromAcc.Balance, tt.to, tt.amount)
 			} else {
 				t.Logf("Transfer correctly failed: %s -> %s, amount: %.2f, error: %v",
@@ -425,16 +416,13 @@ func TestTransferValidation(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Setup mock
-			loadAccFunc = func() ([]Account, error) {
-				return []Account{
-					{Id: "1", Name: "Alice", Balance: tt.balance, AccountType: Giro},
-					{Id: "2", Name: "Bob", Balance: 500, AccountType: Giro},
-				}, nil
-			}
-			defer func() { loadAccFunc = LoadAcc }()
+			useStore(t,
+				Account{Id: "1", Name: "Alice", Balance: eur(tt.balance), AccountType: Giro},
+				Account{Id: "2", Name: "Bob", Balance: eur(500), AccountType: Giro},
+			)
 
-			fromAcc := &Account{Id: "1", Name: "Alice", Balance: tt.balance, AccountType: Giro}
-			err := fromAcc.Transfer(tt.amount, tt.recipient)
+			fromAcc := &Account{Id: "1", Name: "Alice", Balance: eur(tt.balance), AccountType: Giro}
+			err := fromAcc.Transfer(eur(tt.amount), tt.recipient)
 
 			if tt.shouldFail && err == nil {
 				t.Errorf("Expected transfer to fail but it succeeded")
@@ -444,3 +432,66 @@ func TestTransferValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestBookingReceipts(t *testing.T) {
+	useStore(t)
+	useBooks(t)
+	useFees(t, map[AccountType]FeeSchedule{
+		Giro: {PerTransaction: map[TransactionType]Money{Withdraw: eur(1)}},
+	})
+
+	giro := &Account{Id: "giro", Name: "Giro", Balance: eur(100), AccountType: Giro}
+	savings := &Account{Id: "savings", Name: "Savings", Balance: eur(0), AccountType: Savings}
+	openTestAccounts(t, giro, savings)
+
+	receipt, err := giro.BookDeposit(eur(50), "salary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Account.Balance != eur(150) {
+		t.Errorf("deposit balance = %v, want 150", receipt.Account.Balance)
+	}
+	if len(receipt.Transactions) != 1 || receipt.Transactions[0].Type != Deposit || receipt.Transactions[0].Reference != "salary" {
+		t.Errorf("deposit booked %+v", receipt.Transactions)
+	}
+
+	receipt, err = giro.BookWithdrawal(eur(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Account.Balance != eur(129) {
+		t.Errorf("withdrawal balance = %v, want 129", receipt.Account.Balance)
+	}
+	if len(receipt.Transactions) != 2 || receipt.Transactions[0].Type != Withdraw || receipt.Transactions[1].Type != Fee {
+		t.Errorf("withdrawal booked %+v, want the withdrawal and its fee", receipt.Transactions)
+	}
+
+	receipt, err = giro.BookTransfer(eur(29), "savings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Account.Balance != eur(100) || len(receipt.Transactions) != 1 || receipt.Transactions[0].Counterparty != "savings" {
+		t.Errorf("transfer receipt = %+v", receipt)
+	}
+
+	// The receipt is a copy, later bookings do not change it.
+	giro.Deposit(eur(1))
+	if len(receipt.Account.Transactions) != 4 {
+		t.Errorf("receipt has %d transactions, want 4", len(receipt.Account.Transactions))
+	}
+
+	if _, err := giro.BookWithdrawal(eur(1000)); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("overdrawing withdrawal: got %v", err)
+	}
+}
+
+func TestOpenAccountRejectsReservedIDs(t *testing.T) {
+	useStore(t)
+	useBooks(t)
+
+	for _, id := range []string{"", " ", CashAccount, "internal:anything"} {
+		if err := OpenAccount(&Account{Id: id, Name: "Mallory", AccountType: Giro}); !errors.Is(err, ErrInvalidAccountID) {
+			t.Errorf("OpenAccount(%q): got %v, want %v", id, err, ErrInvalidAccountID)
+		}
+	}
+}
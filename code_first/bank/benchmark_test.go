@@ -8,13 +8,13 @@ func BenchmarkDeposit(b *testing.B) {
 	account := &Account{
 		Id:          "123",
 		Name:        "",
-		Balance:     1000.0,
+		Balance:     eur(1000.0),
 		AccountType: Giro,
 	}
 
 	b.ResetTimer()
 	for b.Loop() {
-		account.Deposit(100.0)
+		account.Deposit(eur(100.0))
 	}
 }
 
@@ -22,14 +22,14 @@ func BenchmarkWithdraw(b *testing.B) {
 	account := &Account{
 		Id:          "123",
 		Name:        "",
-		Balance:     10000.0,
+		Balance:     eur(10000.0),
 		AccountType: Giro,
-		Overdraw:    500.0,
+		Overdraw:    eur(500.0),
 	}
 
 	b.ResetTimer()
 	for b.Loop() {
-		account.Withdraw(50.0)
+		account.Withdraw(eur(50.0))
 	}
 }
 
@@ -37,13 +37,13 @@ func BenchmarkTransfer(b *testing.B) {
 	account := &Account{
 		Id:          "123",
 		Name:        "",
-		Balance:     10000.0,
+		Balance:     eur(10000.0),
 		AccountType: Giro,
 	}
 
 	b.ResetTimer()
 	for b.Loop() {
-		account.Transfer(100.0, "recipient")
+		account.Transfer(eur(100.0), "recipient")
 	}
 }
 
@@ -51,6 +51,6 @@ func BenchmarkConversion(b *testing.B) {
 
 	b.ResetTimer()
 	for b.Loop() {
-		ConvertCurrency(10000.0, EUR, USD)
+		ConvertCurrency(eur(10000.0), EUR, USD)
 	}
 }
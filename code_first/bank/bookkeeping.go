@@ -0,0 +1,325 @@
+package bank
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Side string
+
+const (
+	Debit  Side = "debit"
+	Credit Side = "credit"
+)
+
+const (
+	CashAccount            = "internal:cash"
+	FeeIncomeAccount       = "internal:fees"
+	FXSuspenseAccount      = "internal:fx-suspense"
+	InterestExpenseAccount = "internal:interest"
+	OpeningBalanceAccount  = "internal:opening"
+	SEPAClearingAccount    = "internal:sepa-clearing"
+)
+
+var ErrUnbalancedEntry = errors.New("journal entry is not balanced")
+
+type Leg struct {
+	Account string
+	Side    Side
+	Amount  Money
+}
+
+type JournalEntry struct {
+	ID          string
+	Time        time.Time
+	Description string
+	Legs        []Leg
+	ReversalOf  string `json:",omitempty"`
+	// TransactionID links the entry to the account transactions it books.
+	TransactionID string `json:",omitempty"`
+}
+
+func NewEntry(description string, legs ...Leg) JournalEntry {
+	return JournalEntry{
+		ID:          newID("je"),
+		Time:        time.Now(),
+		Description: description,
+		Legs:        legs,
+	}
+}
+
+func (e JournalEntry) Validate() error {
+	if len(e.Legs) < 2 {
+		return fmt.Errorf("%w: needs at least two legs", ErrUnbalancedEntry)
+	}
+
+	totals := map[Currency]int64{}
+	for _, leg := range e.Legs {
+		if leg.Account == "" {
+			return fmt.Errorf("%w: leg without account", ErrUnbalancedEntry)
+		}
+		if !leg.Amount.IsPositive() {
+			return fmt.Errorf("%w: leg amount must be positive", ErrUnbalancedEntry)
+		}
+		switch leg.Side {
+		case Debit:
+			totals[leg.Amount.Currency] += leg.Amount.Minor
+		case Credit:
+			totals[leg.Amount.Currency] -= leg.Amount.Minor
+		default:
+			return fmt.Errorf("%w: unknown side %q", ErrUnbalancedEntry, leg.Side)
+		}
+	}
+
+	for currency, total := range totals {
+		if total != 0 {
+			return fmt.Errorf("%w: %s off by %s", ErrUnbalancedEntry, currency.Code(), NewMoney(total, currency).Decimal())
+		}
+	}
+	return nil
+}
+
+func (e JournalEntry) Reversal() JournalEntry {
+	legs := make([]Leg, len(e.Legs))
+	for i, leg := range e.Legs {
+		leg.Side = opposite(leg.Side)
+		legs[i] = leg
+	}
+
+	reversal := NewEntry("reversal: "+e.Description, legs...)
+	reversal.ReversalOf = e.ID
+	return reversal
+}
+
+type Books struct {
+	mu      sync.Mutex
+	path    string
+	entries []JournalEntry
+}
+
+var books = NewMemoryBooks()
+
+func SetBooks(b *Books) {
+	books = b
+}
+
+func GeneralLedger() *Books {
+	return books
+}
+
+func NewMemoryBooks() *Books {
+	return &Books{}
+}
+
+func NewBooks(path string) (*Books, error) {
+	b := &Books{path: path}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return b, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+		b.entries = append(b.entries, entry)
+	}
+	return b, scanner.Err()
+}
+
+func (b *Books) Post(entry JournalEntry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.path != "" {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.OpenFile(b.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(append(line, '\n')); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	b.entries = append(b.entries, entry)
+	return nil
+}
+
+func (b *Books) Entries() []JournalEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]JournalEntry(nil), b.entries...)
+}
+
+// EntryForTransaction returns the entry that booked the transaction.
+func (b *Books) EntryForTransaction(txID string) (JournalEntry, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i := len(b.entries) - 1; i >= 0; i-- {
+		if b.entries[i].TransactionID == txID {
+			return b.entries[i], true
+		}
+	}
+	return JournalEntry{}, false
+}
+
+// Balance is credits minus debits, so customer deposits (a liability of the
+// bank) show up positive and the internal cash account goes negative.
+func (b *Books) Balance(account string, currency Currency) Money {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	balance := NewMoney(0, currency)
+	for _, entry := range b.entries {
+		for _, leg := range entry.Legs {
+			if leg.Account != account || leg.Amount.Currency != currency {
+				continue
+			}
+			if leg.Side == Credit {
+				balance.Minor += leg.Amount.Minor
+			} else {
+				balance.Minor -= leg.Amount.Minor
+			}
+		}
+	}
+	return balance
+}
+
+type TrialBalanceLine struct {
+	Account string
+	Debit   Money
+	Credit  Money
+}
+
+func (b *Books) TrialBalance() ([]TrialBalanceLine, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	type key struct {
+		account  string
+		currency Currency
+	}
+	lines := map[key]*TrialBalanceLine{}
+	totals := map[Currency]int64{}
+
+	for _, entry := range b.entries {
+		for _, leg := range entry.Legs {
+			k := key{leg.Account, leg.Amount.Currency}
+			line, ok := lines[k]
+			if !ok {
+				line = &TrialBalanceLine{
+					Account: leg.Account,
+					Debit:   NewMoney(0, k.currency),
+					Credit:  NewMoney(0, k.currency),
+				}
+				lines[k] = line
+			}
+
+			if leg.Side == Debit {
+				line.Debit.Minor += leg.Amount.Minor
+				totals[k.currency] += leg.Amount.Minor
+			} else {
+				line.Credit.Minor += leg.Amount.Minor
+				totals[k.currency] -= leg.Amount.Minor
+			}
+		}
+	}
+
+	result := make([]TrialBalanceLine, 0, len(lines))
+	for _, line := range lines {
+		result = append(result, *line)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Account != result[j].Account {
+			return result[i].Account < result[j].Account
+		}
+		return result[i].Debit.Currency < result[j].Debit.Currency
+	})
+
+	for currency, total := range totals {
+		if total != 0 {
+			return result, fmt.Errorf("%w: books are off by %s", ErrUnbalancedEntry, NewMoney(total, currency))
+		}
+	}
+	return result, nil
+}
+
+func opposite(side Side) Side {
+	if side == Debit {
+		return Credit
+	}
+	return Debit
+}
+
+func postAndSave(entry JournalEntry, accounts ...*Account) error {
+	return postAllAndSave([]JournalEntry{entry}, accounts...)
+}
+
+// postAllAndSave posts the entries and saves the accounts, reversing the
+// posted entries again if the save fails.
+func postAllAndSave(entries []JournalEntry, accounts ...*Account) error {
+	for i, entry := range entries {
+		if err := books.Post(entry); err != nil {
+			return errors.Join(err, reverseEntries(entries[:i]))
+		}
+	}
+
+	if err := saveAll(accounts...); err != nil {
+		return errors.Join(err, reverseEntries(entries))
+	}
+	return nil
+}
+
+func reverseEntries(entries []JournalEntry) error {
+	var errs []error
+	for _, entry := range entries {
+		if err := books.Post(entry.Reversal()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func newID(prefix string) string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return prefix + "_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,180 @@
+package bank
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func useBooks(t *testing.T) *Books {
+	t.Helper()
+
+	original := books
+	b := NewMemoryBooks()
+	SetBooks(b)
+	t.Cleanup(func() { SetBooks(original) })
+	return b
+}
+
+type failingStore struct {
+	AccountStore
+	failID string
+}
+
+func (fs failingStore) Save(account *Account) error {
+	return fs.SaveAll(account)
+}
+
+func (fs failingStore) SaveAll(accounts ...*Account) error {
+	for _, account := range accounts {
+		if account.Id == fs.failID {
+			return errors.New("disk full")
+		}
+	}
+	return fs.AccountStore.SaveAll(accounts...)
+}
+
+func TestJournalEntryValidate(t *testing.T) {
+	tests := map[string]struct {
+		legs    []Leg
+		wantErr bool
+	}{
+		"Happy Path: balanced": {
+			legs: []Leg{{"a", Debit, eur(10)}, {"b", Credit, eur(10)}},
+		},
+		"Happy Path: split credit": {
+			legs: []Leg{{"a", Debit, eur(10)}, {"b", Credit, eur(7)}, {FeeIncomeAccount, Credit, eur(3)}},
+		},
+		"Happy Path: fx with suspense": {
+			legs: []Leg{
+				{"a", Debit, eur(10)}, {FXSuspenseAccount, Credit, eur(10)},
+				{FXSuspenseAccount, Debit, NewMoney(1100, USD)}, {"a", Credit, NewMoney(1100, USD)},
+			},
+		},
+		"Unhappy Path: unbalanced": {
+			legs:    []Leg{{"a", Debit, eur(10)}, {"b", Credit, eur(9)}},
+			wantErr: true,
+		},
+		"Unhappy Path: mixed currencies": {
+			legs:    []Leg{{"a", Debit, eur(10)}, {"b", Credit, NewMoney(1000, USD)}},
+			wantErr: true,
+		},
+		"Unhappy Path: single leg": {
+			legs:    []Leg{{"a", Debit, eur(10)}},
+			wantErr: true,
+		},
+		"Unhappy Path: negative leg": {
+			legs:    []Leg{{"a", Debit, eur(-10)}, {"b", Credit, eur(-10)}},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := NewEntry(name, tc.legs...).Validate()
+			if (err != nil) != tc.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestTransferPostsSingleBalancedEntry(t *testing.T) {
+	b := useBooks(t)
+	useStore(t)
+
+	alice := &Account{Id: "1", Name: "Alice", Balance: eur(100), AccountType: Giro}
+	bob := &Account{Id: "2", Name: "Bob", Balance: eur(50), AccountType: Giro}
+	for _, acc := range []*Account{alice, bob} {
+		if err := OpenAccount(acc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := alice.Deposit(eur(10)); err != nil {
+		t.Fatal(err)
+	}
+	if err := alice.Transfer(eur(30), "Bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := b.Entries()
+	if len(entries) != 4 {
+		t.Fatalf("got %d entries, want 4", len(entries))
+	}
+	if legs := entries[3].Legs; len(legs) != 2 || legs[0].Account != "1" || legs[1].Account != "2" {
+		t.Errorf("transfer legs = %+v", legs)
+	}
+
+	if _, err := b.TrialBalance(); err != nil {
+		t.Errorf("TrialBalance() error = %v", err)
+	}
+
+	tests := map[string]struct {
+		account string
+		want    Money
+	}{
+		"Alice": {account: "1", want: eur(80)},
+		"Bob":   {account: "2", want: eur(80)},
+		"Cash":  {account: CashAccount, want: eur(-10)},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := b.Balance(tc.account, EUR); got != tc.want {
+				t.Errorf("ledger balance = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTransferReversesEntryWhenSaveFails(t *testing.T) {
+	b := useBooks(t)
+	ms := useStore(t,
+		Account{Id: "1", Name: "Alice", Balance: eur(100), AccountType: Giro},
+		Account{Id: "2", Name: "Bob", Balance: eur(50), AccountType: Giro},
+	)
+	SetStore(failingStore{AccountStore: ms, failID: "2"})
+
+	alice, _ := ms.Get("1")
+	if err := alice.Transfer(eur(30), "Bob"); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	entries := b.Entries()
+	if len(entries) != 2 || entries[1].ReversalOf != entries[0].ID {
+		t.Fatalf("entries = %+v, want transfer followed by its reversal", entries)
+	}
+	if got := b.Balance("2", EUR); !got.IsZero() {
+		t.Errorf("Bob ledger balance = %v, want 0", got)
+	}
+	if stored, _ := ms.Get("1"); stored.Balance != eur(100) {
+		t.Errorf("Alice stored balance = %v, want 100 (transfer must not be half written)", stored.Balance)
+	}
+	if _, err := b.TrialBalance(); err != nil {
+		t.Errorf("TrialBalance() error = %v", err)
+	}
+}
+
+func TestBooksPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "books.jsonl")
+	b, err := NewBooks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := NewEntry("deposit", Leg{CashAccount, Debit, eur(5)}, Leg{"1", Credit, eur(5)})
+	if err := b.Post(entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Post(NewEntry("broken", Leg{CashAccount, Debit, eur(5)})); err == nil {
+		t.Error("expected unbalanced entry to be rejected")
+	}
+
+	reopened, err := NewBooks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries := reopened.Entries(); len(entries) != 1 || entries[0].ID != entry.ID {
+		t.Errorf("reopened entries = %+v", entries)
+	}
+}
@@ -0,0 +1,306 @@
+package bank
+
+import (
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const camt053Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.08"
+
+// camtDocument is the part of an ISO 20022 camt.053 bank-to-customer
+// statement this bank writes.
+type camtDocument struct {
+	XMLName    xml.Name        `xml:"Document"`
+	Namespace  string          `xml:"xmlns,attr,omitempty"`
+	MessageID  string          `xml:"BkToCstmrStmt>GrpHdr>MsgId"`
+	Created    string          `xml:"BkToCstmrStmt>GrpHdr>CreDtTm"`
+	Statements []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtStatement struct {
+	ID       string        `xml:"Id"`
+	Created  string        `xml:"CreDtTm"`
+	From     string        `xml:"FrToDt>FrDtTm"`
+	To       string        `xml:"FrToDt>ToDtTm"`
+	Account  camtAccount   `xml:"Acct"`
+	Balances []camtBalance `xml:"Bal"`
+	Entries  []camtEntry   `xml:"Ntry"`
+}
+
+type camtAccount struct {
+	camtOtherAccount
+	Currency string `xml:"Ccy,omitempty"`
+	Name     string `xml:"Nm,omitempty"`
+	Servicer string `xml:"Svcr>FinInstnId>Othr>Id,omitempty"`
+}
+
+type camtAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type camtBalance struct {
+	Code      string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amount    camtAmount `xml:"Amt"`
+	Indicator string     `xml:"CdtDbtInd"`
+	Date      string     `xml:"Dt>Dt"`
+}
+
+type camtDate struct {
+	Date     string `xml:"Dt,omitempty"`
+	DateTime string `xml:"DtTm,omitempty"`
+}
+
+type camtEntry struct {
+	Reference   string       `xml:"NtryRef,omitempty"`
+	Amount      camtAmount   `xml:"Amt"`
+	Indicator   string       `xml:"CdtDbtInd"`
+	Reversal    bool         `xml:"RvslInd,omitempty"`
+	Status      camtStatus   `xml:"Sts"`
+	BookingDate camtDate     `xml:"BookgDt"`
+	ValueDate   camtDate     `xml:"ValDt"`
+	ServicerRef string       `xml:"AcctSvcrRef,omitempty"`
+	BankCode    string       `xml:"BkTxCd>Prtry>Cd"`
+	Details     *camtDetails `xml:"NtryDtls>TxDtls"`
+	Info        string       `xml:"AddtlNtryInf,omitempty"`
+}
+
+// camtStatus is the entry status, a code since camt.053.001.08 and plain
+// text before.
+type camtStatus struct {
+	Code string `xml:"Cd,omitempty"`
+	Text string `xml:",chardata"`
+}
+
+func (s camtStatus) String() string {
+	return strings.TrimSpace(s.Code + s.Text)
+}
+
+type camtDetails struct {
+	EndToEndID      string            `xml:"Refs>EndToEndId,omitempty"`
+	Debtor          *camtParty        `xml:"RltdPties>Dbtr,omitempty"`
+	DebtorAccount   *camtOtherAccount `xml:"RltdPties>DbtrAcct,omitempty"`
+	Creditor        *camtParty        `xml:"RltdPties>Cdtr,omitempty"`
+	CreditorAccount *camtOtherAccount `xml:"RltdPties>CdtrAcct,omitempty"`
+	Remittance      *camtRemittance   `xml:"RmtInf,omitempty"`
+}
+
+// camtParty is a debtor or creditor, named directly before camt.053.001.08
+// and as a party since.
+type camtParty struct {
+	Name      string `xml:"Nm,omitempty"`
+	PartyName string `xml:"Pty>Nm,omitempty"`
+}
+
+// camtOtherAccount is an account identified by IBAN or something else, here
+// the account id.
+type camtOtherAccount struct {
+	IBAN  string     `xml:"Id>IBAN,omitempty"`
+	Other *camtOther `xml:"Id>Othr,omitempty"`
+}
+
+type camtOther struct {
+	ID string `xml:"Id"`
+}
+
+func otherAccount(id string) *camtOtherAccount {
+	return &camtOtherAccount{Other: &camtOther{ID: id}}
+}
+
+// ID is the IBAN or else the other identification of the account.
+func (a camtOtherAccount) ID() string {
+	if a.IBAN != "" || a.Other == nil {
+		return a.IBAN
+	}
+	return a.Other.ID
+}
+
+type camtRemittance struct {
+	Unstructured []string `xml:"Ustrd"`
+}
+
+// camtMoney splits money into the unsigned amount and credit or debit
+// indicator camt uses.
+func camtMoney(m Money) (camtAmount, string) {
+	if m.IsNegative() {
+		return camtAmount{Currency: m.Currency.Code(), Value: m.Neg().Decimal()}, "DBIT"
+	}
+	return camtAmount{Currency: m.Currency.Code(), Value: m.Decimal()}, "CRDT"
+}
+
+func camtBalanceOf(code string, m Money, day time.Time) camtBalance {
+	amount, indicator := camtMoney(m)
+	return camtBalance{Code: code, Amount: amount, Indicator: indicator, Date: day.UTC().Format(time.DateOnly)}
+}
+
+// WriteCAMT053 writes the statement as an ISO 20022 camt.053.001.08
+// message with opening (OPBD) and closing (CLBD) booked balances.
+func (s *Statement) WriteCAMT053(w io.Writer) error {
+	// Accounts opened before IBANs are identified by their id.
+	id := camtOtherAccount{IBAN: s.IBAN.String()}
+	if id.IBAN == "" {
+		id = *otherAccount(s.AccountID)
+	}
+	statement := camtStatement{
+		ID:      s.AccountID + "-" + s.From.UTC().Format("20060102") + "-" + s.To.UTC().Format("20060102"),
+		Created: s.Created.UTC().Format(time.RFC3339),
+		From:    s.From.UTC().Format(time.RFC3339),
+		To:      s.To.UTC().Format(time.RFC3339),
+		Account: camtAccount{
+			camtOtherAccount: id,
+			Currency:         s.Currency.Code(),
+			Name:             truncate(s.AccountName, 70),
+			Servicer:         StatementBankID,
+		},
+		Balances: []camtBalance{
+			camtBalanceOf("OPBD", s.Opening, s.From),
+			camtBalanceOf("CLBD", s.Closing, s.To.Add(-time.Nanosecond)),
+		},
+	}
+
+	for _, line := range s.Lines {
+		amount, indicator := camtMoney(line.Amount)
+		details := &camtDetails{EndToEndID: "NOTPROVIDED"}
+		if line.Reference != "" {
+			details.Remittance = &camtRemittance{Unstructured: []string{truncate(line.Reference, 140)}}
+		}
+		switch {
+		case line.Counterparty == "":
+		case indicator == "DBIT":
+			details.CreditorAccount = otherAccount(line.Counterparty)
+		default:
+			details.DebtorAccount = otherAccount(line.Counterparty)
+		}
+		statement.Entries = append(statement.Entries, camtEntry{
+			Reference:   line.Id,
+			Amount:      amount,
+			Indicator:   indicator,
+			Reversal:    line.Type == Reversal,
+			Status:      camtStatus{Code: "BOOK"},
+			BookingDate: camtDate{DateTime: line.Time.UTC().Format(time.RFC3339)},
+			ValueDate:   camtDate{Date: line.Time.UTC().Format(time.DateOnly)},
+			ServicerRef: line.Id,
+			BankCode:    string(line.Type),
+			Details:     details,
+		})
+	}
+
+	doc := camtDocument{
+		Namespace:  camt053Namespace,
+		MessageID:  newID("camt"),
+		Created:    statement.Created,
+		Statements: []camtStatement{statement},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// ParseCAMT053 reads the booked entries of every statement in a camt.053
+// message of any version. Pending and informational entries are skipped.
+func ParseCAMT053(r io.Reader) ([]ImportedLine, []LineError, error) {
+	var doc camtDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+	}
+	if !strings.HasPrefix(doc.XMLName.Space, "urn:iso:std:iso:20022:tech:xsd:camt.053.") {
+		return nil, nil, fmt.Errorf("%w: not a camt.053 message but %q", ErrInvalidImport, doc.XMLName.Space)
+	}
+
+	var lines []ImportedLine
+	var lineErrors []LineError
+	n := 0
+	for _, statement := range doc.Statements {
+		for _, entry := range statement.Entries {
+			n++
+			if status := entry.Status.String(); status != "" && status != "BOOK" {
+				continue
+			}
+			line, err := entry.importedLine(n)
+			if err != nil {
+				lineErrors = append(lineErrors, lineError(n, "%v", err))
+				continue
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines, lineErrors, nil
+}
+
+// importedLine reads an entry. The indicator is the direction of the entry
+// itself, also for reversals.
+func (e camtEntry) importedLine(n int) (ImportedLine, error) {
+	currency, err := ParseCurrency(e.Amount.Currency)
+	if err != nil {
+		return ImportedLine{}, err
+	}
+	amount, err := ParseMoney(strings.TrimSpace(e.Amount.Value), currency)
+	if err != nil {
+		return ImportedLine{}, err
+	}
+	switch e.Indicator {
+	case "CRDT":
+	case "DBIT":
+		amount = amount.Neg()
+	default:
+		return ImportedLine{}, fmt.Errorf("invalid credit debit indicator %q", e.Indicator)
+	}
+
+	booked := cmp.Or(e.BookingDate.DateTime, e.BookingDate.Date, e.ValueDate.DateTime, e.ValueDate.Date)
+	line := ImportedLine{Line: n, Amount: amount, Type: TransactionType(strings.ToLower(e.BankCode))}
+	if line.Time, err = camtTime(booked); err != nil {
+		return ImportedLine{}, err
+	}
+
+	line.Reference = strings.TrimSpace(e.Info)
+	if details := e.Details; details != nil {
+		if details.Remittance != nil {
+			line.Reference = cmp.Or(strings.TrimSpace(strings.Join(details.Remittance.Unstructured, " ")), line.Reference)
+		}
+		if amount.IsNegative() {
+			line.Counterparty = counterpartyName(details.Creditor, details.CreditorAccount)
+		} else {
+			line.Counterparty = counterpartyName(details.Debtor, details.DebtorAccount)
+		}
+	}
+	return line, nil
+}
+
+// counterpartyName prefers the name of the party to its account.
+func counterpartyName(party *camtParty, account *camtOtherAccount) string {
+	if party != nil {
+		if name := strings.TrimSpace(cmp.Or(party.PartyName, party.Name)); name != "" {
+			return name
+		}
+	}
+	if account != nil {
+		return strings.TrimSpace(account.ID())
+	}
+	return ""
+}
+
+// camtTime reads ISO dates and date times, which may leave out the zone.
+func camtTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, errors.New("no booking date")
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", time.DateOnly} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
@@ -2,45 +2,70 @@ package bank
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
+	"math/big"
+	"time"
 )
 
 var frankfurterAPI = "https://api.frankfurter.app"
 
-type Currency string
-
-const (
-	EUR Currency = "eur"
-	USD Currency = "usd"
-	JPN Currency = "jpn"
-	GBP Currency = "gbp"
-)
+var ErrRateUnavailable = errors.New("exchange rate unavailable")
 
 type RatesResponse struct {
-	Rates map[string]float64 `json:"rates"`
-	Base  string             `json:"base"`
+	Rates map[string]json.Number `json:"rates"`
+	Base  string                 `json:"base"`
+}
+
+// ConvertCurrency converts amount at the latest rate of the configured
+// ExchangeRateProvider, rounding half to even.
+func ConvertCurrency(amount Money, base Currency, target Currency) (*Money, error) {
+	return ConvertCurrencyOn(amount, base, target, time.Time{})
 }
 
-func ConvertCurrency(amount float64, base Currency, target Currency) (*float64, error) {
-	url := fmt.Sprintf("%s/latest?amount=%f&from=%s&to=%s",
-		frankfurterAPI, amount, base, target)
+// ConvertCurrencyOn converts amount at the rate that applied on day.
+func ConvertCurrencyOn(amount Money, base Currency, target Currency, day time.Time) (*Money, error) {
+	if amount.Currency != "" && amount.Currency != base {
+		return nil, fmt.Errorf("%w: %s != %s", ErrCurrencyMismatch, amount.Currency.Code(), base.Code())
+	}
 
-	response, err := http.Get(url)
+	rate, err := ExchangeRate(base, target, day)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching rates: %s\n", err)
-
+		return nil, err
 	}
-	defer response.Body.Close()
 
-	var rates RatesResponse
-	if err := json.NewDecoder(response.Body).Decode(&rates); err != nil {
-		return nil, fmt.Errorf("error decoding json: %s\n", err)
+	converted, err := MoneyFromRat(new(big.Rat).Mul(amount.WithCurrency(base).Rat(), rate.Rate), target, RoundHalfEven)
+	if err != nil {
+		return nil, err
 	}
+	return &converted, nil
+}
 
-	for _, convert := range rates.Rates {
-		return &convert, nil
+// ExchangeRate returns how many units of target one unit of base bought on
+// day, or buys now for the zero time.
+func ExchangeRate(base, target Currency, day time.Time) (DatedRate, error) {
+	if err := errors.Join(checkCurrency(base), checkCurrency(target)); err != nil {
+		return DatedRate{}, err
+	}
+	if base == target {
+		if day.IsZero() {
+			day = time.Now()
+		}
+		return DatedRate{Date: startOfDay(day), Rate: big.NewRat(1, 1)}, nil
 	}
+	return rates.Rate(base, target, day)
+}
 
-	return nil, fmt.Errorf("something went wrong while converting")
+// formatRate prints the rate as a decimal, exactly if it has at most 18
+// places.
+func formatRate(rate *big.Rat) string {
+	for decimals := 0; decimals <= 18; decimals++ {
+		text := rate.FloatString(decimals)
+		if parsed, ok := new(big.Rat).SetString(text); ok && parsed.Cmp(rate) == 0 {
+			return text
+		}
+	}
+	// Cross rates through a third currency may not end, ten places are
+	// plenty to show on a statement.
+	return rate.FloatString(10)
 }
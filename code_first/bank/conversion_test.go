@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 )
 
@@ -13,7 +14,7 @@ func TestConverCurrency(t *testing.T) {
 		target     Currency
 		amount     float64
 		mockRate   float64
-		wantAmount float64
+		wantAmount string
 		wantErr    bool
 	}{
 		"Happy Path: EUR->USD": {
@@ -21,15 +22,15 @@ func TestConverCurrency(t *testing.T) {
 			target:     USD,
 			amount:     100,
 			mockRate:   1.2,
-			wantAmount: 120,
+			wantAmount: "120.00 USD",
 			wantErr:    false,
 		},
-		"Happy Path: EUR->JPN": {
+		"Happy Path: EUR->JPY": {
 			base:       EUR,
-			target:     JPN,
+			target:     JPY,
 			amount:     100,
 			mockRate:   0.7,
-			wantAmount: 70,
+			wantAmount: "70 JPY",
 			wantErr:    false,
 		},
 		"Happy Path: EUR->GBP": {
@@ -37,7 +38,7 @@ func TestConverCurrency(t *testing.T) {
 			target:     GBP,
 			amount:     100,
 			mockRate:   1.3,
-			wantAmount: 130,
+			wantAmount: "130.00 GBP",
 			wantErr:    false,
 		},
 		"Unhappy Path: API return empty rates": {
@@ -45,7 +46,7 @@ func TestConverCurrency(t *testing.T) {
 			target:     USD,
 			amount:     100,
 			mockRate:   0,
-			wantAmount: 0,
+			wantAmount: "",
 			wantErr:    true,
 		},
 	}
@@ -55,14 +56,14 @@ func TestConverCurrency(t *testing.T) {
 			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				if tt.wantErr {
 					_ = json.NewEncoder(w).Encode(RatesResponse{
-						Rates: map[string]float64{},
+						Rates: map[string]json.Number{},
 					})
 					return
 				}
 
 				resp := RatesResponse{
-					Rates: map[string]float64{
-						string(tt.target): tt.mockRate * tt.amount,
+					Rates: map[string]json.Number{
+						string(tt.target): json.Number(strconv.FormatFloat(tt.mockRate, 'f', -1, 64)),
 					},
 					Base: string(tt.base),
 				}
@@ -74,7 +75,7 @@ func TestConverCurrency(t *testing.T) {
 			frankfurterAPI = server.URL
 			defer func() { frankfurterAPI = oldApi }()
 
-			got, err := ConvertCurrency(tt.amount, tt.base, tt.target)
+			got, err := ConvertCurrency(eur(tt.amount), tt.base, tt.target)
 
 			if tt.wantErr {
 				if err == nil {
@@ -87,7 +88,7 @@ func TestConverCurrency(t *testing.T) {
 				t.Errorf("unexcepted error: %v", err)
 			}
 
-			if got == nil || *got != tt.wantAmount {
+			if got == nil || got.String() != tt.wantAmount {
 				t.Errorf("got %v, want %v", got, tt.wantAmount)
 			}
 
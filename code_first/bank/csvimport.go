@@ -0,0 +1,288 @@
+package bank
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// CSVMapping tells ParseCSVStatement which columns of a CSV file hold what,
+// by their name in the header row. Either Amount holds the signed amount or
+// Debit and Credit hold the amounts going out and coming in.
+type CSVMapping struct {
+	Date         string
+	Amount       string `json:",omitempty"`
+	Debit        string `json:",omitempty"`
+	Credit       string `json:",omitempty"`
+	Currency     string `json:",omitempty"`
+	Counterparty string `json:",omitempty"`
+	Reference    string `json:",omitempty"`
+	Type         string `json:",omitempty"`
+
+	// DateFormat is a Go time layout, empty for YYYY-MM-DD or RFC 3339.
+	DateFormat string `json:",omitempty"`
+	// Delimiter separates the fields, a comma if zero.
+	Delimiter rune `json:",omitempty"`
+	// DecimalComma reads 1.234,56 instead of 1,234.56.
+	DecimalComma bool `json:",omitempty"`
+	// DefaultCurrency is used for lines without a currency column, the
+	// account's base currency if empty.
+	DefaultCurrency Currency `json:",omitempty"`
+}
+
+// DefaultCSVMapping reads the CSV statements this bank exports.
+var DefaultCSVMapping = CSVMapping{
+	Date:         "Date",
+	Amount:       "Amount",
+	Currency:     "Currency",
+	Counterparty: "Counterparty",
+	Reference:    "Reference",
+	Type:         "Type",
+}
+
+// csvMappingColumns are the URL parameters ParseCSVMapping reads column
+// names from.
+var csvMappingColumns = map[string]func(*CSVMapping) *string{
+	"date_column":         func(m *CSVMapping) *string { return &m.Date },
+	"amount_column":       func(m *CSVMapping) *string { return &m.Amount },
+	"debit_column":        func(m *CSVMapping) *string { return &m.Debit },
+	"credit_column":       func(m *CSVMapping) *string { return &m.Credit },
+	"currency_column":     func(m *CSVMapping) *string { return &m.Currency },
+	"counterparty_column": func(m *CSVMapping) *string { return &m.Counterparty },
+	"reference_column":    func(m *CSVMapping) *string { return &m.Reference },
+	"type_column":         func(m *CSVMapping) *string { return &m.Type },
+}
+
+// dateFormatTokens turns the date patterns people write into Go layouts.
+var dateFormatTokens = strings.NewReplacer("YYYY", "2006", "YY", "06", "MM", "01", "DD", "02", "hh", "15", "mm", "04", "ss", "05")
+
+// ParseCSVMapping reads a mapping from URL parameters:
+//
+//	date_column, amount_column, debit_column, credit_column,
+//	currency_column, counterparty_column, reference_column, type_column
+//	                 names of the columns, without any DefaultCSVMapping
+//	date_format      a pattern of YYYY, YY, MM, DD, hh, mm and ss
+//	delimiter        the field separator, tab for tabs
+//	decimal          , for a decimal comma
+//	currency         currency of files without a currency column
+func ParseCSVMapping(values url.Values) (CSVMapping, error) {
+	mapping := DefaultCSVMapping
+	for name := range csvMappingColumns {
+		if values.Has(name) {
+			mapping = CSVMapping{}
+			break
+		}
+	}
+	for name, field := range csvMappingColumns {
+		if value := strings.TrimSpace(values.Get(name)); value != "" {
+			*field(&mapping) = value
+		}
+	}
+
+	if format := values.Get("date_format"); format != "" {
+		mapping.DateFormat = dateFormatTokens.Replace(format)
+	}
+	switch delimiter := values.Get("delimiter"); {
+	case delimiter == "":
+	case delimiter == "tab" || delimiter == `\t`:
+		mapping.Delimiter = '\t'
+	case utf8.RuneCountInString(delimiter) == 1:
+		mapping.Delimiter, _ = utf8.DecodeRuneInString(delimiter)
+	default:
+		return mapping, fmt.Errorf("%w: delimiter must be a single character", ErrInvalidImport)
+	}
+	switch decimal := values.Get("decimal"); decimal {
+	case "", ".":
+	case ",":
+		mapping.DecimalComma = true
+	default:
+		return mapping, fmt.Errorf("%w: decimal must be . or ,", ErrInvalidImport)
+	}
+	if code := values.Get("currency"); code != "" {
+		currency, err := ParseCurrency(code)
+		if err != nil {
+			return mapping, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+		}
+		mapping.DefaultCurrency = currency
+	}
+	return mapping, mapping.validate()
+}
+
+func (m CSVMapping) validate() error {
+	if m.Date == "" {
+		return fmt.Errorf("%w: no date column", ErrInvalidImport)
+	}
+	if m.Amount == "" && m.Debit == "" && m.Credit == "" {
+		return fmt.Errorf("%w: no amount, debit or credit column", ErrInvalidImport)
+	}
+	return nil
+}
+
+// ParseCSVStatement reads a CSV file with a header row. The date and amount
+// columns must be there, the others are read if they are. Rows without any
+// amount, such as the balance rows of the statements this bank exports, are
+// skipped.
+func ParseCSVStatement(r io.Reader, mapping CSVMapping) ([]ImportedLine, []LineError, error) {
+	if err := mapping.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	reader := csv.NewReader(r)
+	if mapping.Delimiter != 0 {
+		reader.Comma = mapping.Delimiter
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, fmt.Errorf("%w: empty file", ErrInvalidImport)
+		}
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	index := func(name string, required bool) (int, error) {
+		i, ok := columns[strings.ToLower(name)]
+		if !ok && name != "" && required {
+			return -1, fmt.Errorf("%w: no column %q", ErrInvalidImport, name)
+		}
+		if !ok {
+			return -1, nil
+		}
+		return i, nil
+	}
+
+	var cols struct{ date, amount, debit, credit, currency, counterparty, reference, kind int }
+	for _, c := range []struct {
+		name     string
+		index    *int
+		required bool
+	}{
+		{mapping.Date, &cols.date, true},
+		{mapping.Amount, &cols.amount, true},
+		{mapping.Debit, &cols.debit, true},
+		{mapping.Credit, &cols.credit, true},
+		{mapping.Currency, &cols.currency, false},
+		{mapping.Counterparty, &cols.counterparty, false},
+		{mapping.Reference, &cols.reference, false},
+		{mapping.Type, &cols.kind, false},
+	} {
+		if *c.index, err = index(c.name, c.required); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var lines []ImportedLine
+	var lineErrors []LineError
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, nil, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+			}
+			lineErrors = append(lineErrors, lineError(parseErr.Line, "%v", parseErr.Err))
+			continue
+		}
+		row, _ := reader.FieldPos(0)
+		field := func(i int) string {
+			if i < 0 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		amount, credit, debit := field(cols.amount), field(cols.credit), field(cols.debit)
+		if amount == "" && credit == "" && debit == "" {
+			continue
+		}
+
+		line := ImportedLine{
+			Line:         row,
+			Counterparty: field(cols.counterparty),
+			Reference:    field(cols.reference),
+			Type:         TransactionType(strings.ToLower(field(cols.kind))),
+		}
+		if line.Time, err = mapping.parseDate(field(cols.date)); err != nil {
+			lineErrors = append(lineErrors, lineError(row, "%v", err))
+			continue
+		}
+		currency := mapping.DefaultCurrency
+		if code := field(cols.currency); code != "" {
+			if currency, err = ParseCurrency(code); err != nil {
+				lineErrors = append(lineErrors, lineError(row, "%v", err))
+				continue
+			}
+		}
+		if line.Amount, err = mapping.parseAmount(amount, credit, debit, currency); err != nil {
+			lineErrors = append(lineErrors, lineError(row, "%v", err))
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, lineErrors, nil
+}
+
+func (m CSVMapping) parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, errors.New("no booking date")
+	}
+	layouts := []string{time.DateOnly, time.RFC3339}
+	if m.DateFormat != "" {
+		layouts = []string{m.DateFormat}
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("date %q does not match %s", value, strings.Join(layouts, " or "))
+}
+
+// parseAmount takes the signed amount, or credit less debit. Debits count
+// as money going out whether they are written with a minus or not.
+func (m CSVMapping) parseAmount(amount, credit, debit string, currency Currency) (Money, error) {
+	if amount != "" {
+		return ParseMoney(m.normalizeDecimal(amount), currency)
+	}
+
+	total := NewMoney(0, currency)
+	if credit != "" {
+		in, err := ParseMoney(m.normalizeDecimal(credit), currency)
+		if err != nil {
+			return Money{}, err
+		}
+		total.Minor = in.Minor
+	}
+	if debit != "" {
+		out, err := ParseMoney(strings.TrimPrefix(m.normalizeDecimal(debit), "-"), currency)
+		if err != nil {
+			return Money{}, err
+		}
+		if total, err = total.Sub(out); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// normalizeDecimal drops thousands separators and makes the decimal
+// separator a dot.
+func (m CSVMapping) normalizeDecimal(s string) string {
+	if m.DecimalComma {
+		return strings.ReplaceAll(strings.ReplaceAll(s, ".", ""), ",", ".")
+	}
+	return strings.ReplaceAll(s, ",", "")
+}
@@ -0,0 +1,131 @@
+package bank
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestParseCurrency(t *testing.T) {
+	tests := map[string]struct {
+		input   string
+		want    Currency
+		wantErr bool
+	}{
+		"lower case":   {input: "eur", want: EUR},
+		"upper case":   {input: "JPY", want: JPY},
+		"mixed case":   {input: " cHf ", want: CHF},
+		"numeric":      {input: "840", want: USD},
+		"leading zero": {input: "008", want: "all"},
+		"legacy yen":   {input: "JPN", want: JPY},
+		"unknown":      {input: "XYZ", wantErr: true},
+		"metal":        {input: "XAU", wantErr: true},
+		"empty":        {input: "", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseCurrency(tc.input)
+			if tc.wantErr {
+				if !errors.Is(err, ErrUnknownCurrency) {
+					t.Errorf("ParseCurrency(%q) = %v, %v, want %v", tc.input, got, err, ErrUnknownCurrency)
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Errorf("ParseCurrency(%q) = %v, %v, want %v", tc.input, got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestCurrencyCatalog(t *testing.T) {
+	codes := map[string]bool{}
+	for _, info := range Currencies() {
+		if len(info.Code) != 3 || len(info.Numeric) != 3 || info.Name == "" {
+			t.Errorf("malformed entry %+v", info)
+		}
+		if codes[info.Code] {
+			t.Errorf("duplicate code %s", info.Code)
+		}
+		codes[info.Code] = true
+	}
+
+	for currency, want := range map[Currency]int{EUR: 2, JPY: 0, "bhd": 3, "clf": 4, "": 2} {
+		if got := currency.MinorUnits(); got != want {
+			t.Errorf("%q.MinorUnits() = %d, want %d", currency, got, want)
+		}
+	}
+	if GBP.Numeric() != "826" || !GBP.Valid() || Currency("jpn").Valid() {
+		t.Errorf("GBP numeric %s, jpn valid %v", GBP.Numeric(), Currency("jpn").Valid())
+	}
+}
+
+func TestMinorUnitRounding(t *testing.T) {
+	tests := map[string]struct {
+		amount string
+		target Currency
+		want   string
+	}{
+		"yen has no decimals":    {amount: "1234.5", target: JPY, want: "1234 JPY"},
+		"yen rounds half even":   {amount: "1235.5", target: JPY, want: "1236 JPY"},
+		"dinar has three":        {amount: "1.2345", target: "kwd", want: "1.234 KWD"},
+		"unidad de fomento four": {amount: "0.123456", target: "clf", want: "0.1235 CLF"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r, _ := new(big.Rat).SetString(tc.amount)
+			got, err := MoneyFromRat(r, tc.target, RoundHalfEven)
+			if err != nil || got.String() != tc.want {
+				t.Errorf("MoneyFromRat(%s) = %v, %v, want %s", tc.amount, got, err, tc.want)
+			}
+		})
+	}
+
+	if _, err := ParseMoney("100.5 JPY", ""); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("yen with decimals: got %v, want %v", err, ErrInvalidAmount)
+	}
+	if _, err := ParseMoney("1.00 ABC", ""); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("unknown code: got %v, want %v", err, ErrUnknownCurrency)
+	}
+
+	var body struct {
+		Base   Currency
+		Amount Money
+	}
+	if err := json.Unmarshal([]byte(`{"Base": "Usd", "Amount": "5 jpy"}`), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Base != USD || body.Amount != NewMoney(5, JPY) {
+		t.Errorf("decoded %+v", body)
+	}
+	if err := json.Unmarshal([]byte(`{"Base": "usx"}`), &body); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("decoding an unknown code: got %v, want %v", err, ErrUnknownCurrency)
+	}
+}
+
+func TestAmountWithoutCurrency(t *testing.T) {
+	hundred, err := ParseMoney("100", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for currency, want := range map[Currency]Money{EUR: NewMoney(10000, EUR), JPY: NewMoney(100, JPY), "bhd": NewMoney(100000, "bhd")} {
+		if got := hundred.WithCurrency(currency); got != want {
+			t.Errorf("100 WithCurrency(%s) = %v, want %v", currency, got, want)
+		}
+		if got, err := hundred.InCurrency(currency); err != nil || got != want {
+			t.Errorf("100 InCurrency(%s) = %v, %v, want %v", currency, got, err, want)
+		}
+	}
+
+	if got := NewMoney(250, JPY).WithCurrency(EUR); got != NewMoney(250, JPY) {
+		t.Errorf("amount with currency changed to %v", got)
+	}
+	if got := NewMoney(10050, "").WithCurrency(JPY); got != NewMoney(100, JPY) {
+		t.Errorf("100.50 WithCurrency(JPY) = %v, want 100 JPY", got)
+	}
+	if _, err := NewMoney(10050, "").InCurrency(JPY); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("100.50 InCurrency(JPY): got %v, want %v", err, ErrInvalidAmount)
+	}
+}
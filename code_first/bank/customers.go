@@ -0,0 +1,204 @@
+package bank
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	passwordIterations = 210000
+	passwordKeyLength  = 32
+	passwordSaltLength = 16
+	minPasswordLength  = 8
+)
+
+var (
+	ErrCustomerNotFound   = errors.New("could not find customer")
+	ErrCustomerExists     = errors.New("customer already exists")
+	ErrInvalidCredentials = errors.New("invalid name or password")
+	ErrWeakPassword       = errors.New("password must have at least 8 characters")
+)
+
+// Customer holds the login credentials of an account owner. The password is
+// only kept as a salted PBKDF2-SHA256 hash.
+type Customer struct {
+	Id         string
+	Name       string
+	Salt       []byte
+	Hash       []byte
+	Iterations int
+}
+
+type CustomerStore struct {
+	mu        sync.Mutex
+	path      string
+	customers []Customer
+}
+
+var customers = NewMemoryCustomerStore()
+
+func SetCustomers(cs *CustomerStore) {
+	customers = cs
+}
+
+func Customers() *CustomerStore {
+	return customers
+}
+
+func NewMemoryCustomerStore() *CustomerStore {
+	return &CustomerStore{}
+}
+
+func NewCustomerStore(path string) (*CustomerStore, error) {
+	cs := &CustomerStore{path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cs, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &cs.customers); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+func (cs *CustomerStore) Register(name, password string) (*Customer, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	if len(password) < minPasswordLength {
+		return nil, ErrWeakPassword
+	}
+
+	salt := make([]byte, passwordSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	hash, err := hashPassword(password, salt, passwordIterations)
+	if err != nil {
+		return nil, err
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if _, ok := cs.findByName(name); ok {
+		return nil, ErrCustomerExists
+	}
+
+	customer := Customer{
+		Id:         newID("cus"),
+		Name:       name,
+		Salt:       salt,
+		Hash:       hash,
+		Iterations: passwordIterations,
+	}
+	if err := cs.persist(append(cs.customers, customer)); err != nil {
+		return nil, err
+	}
+	return &customer, nil
+}
+
+// Authenticate returns the customer if the password matches. Unknown names
+// and wrong passwords produce the same error.
+func (cs *CustomerStore) Authenticate(name, password string) (*Customer, error) {
+	cs.mu.Lock()
+	customer, ok := cs.findByName(strings.TrimSpace(name))
+	cs.mu.Unlock()
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	hash, err := hashPassword(password, customer.Salt, customer.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(hash, customer.Hash) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	return &customer, nil
+}
+
+func (cs *CustomerStore) Get(id string) (*Customer, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	for _, customer := range cs.customers {
+		if customer.Id == id {
+			return &customer, nil
+		}
+	}
+	return nil, ErrCustomerNotFound
+}
+
+func (cs *CustomerStore) FindByName(name string) (*Customer, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	customer, ok := cs.findByName(name)
+	if !ok {
+		return nil, ErrCustomerNotFound
+	}
+	return &customer, nil
+}
+
+func (cs *CustomerStore) findByName(name string) (Customer, bool) {
+	for _, customer := range cs.customers {
+		if strings.EqualFold(customer.Name, name) {
+			return customer, true
+		}
+	}
+	return Customer{}, false
+}
+
+func (cs *CustomerStore) persist(next []Customer) error {
+	if cs.path != "" {
+		data, err := json.MarshalIndent(next, "", "  ")
+		if err != nil {
+			return err
+		}
+		// The file holds password hashes, keep it private to the owner.
+		if err := writeFileAtomic(cs.path, data, 0600); err != nil {
+			return err
+		}
+	}
+	cs.customers = next
+	return nil
+}
+
+func hashPassword(password string, salt []byte, iterations int) ([]byte, error) {
+	return pbkdf2.Key(sha256.New, password, salt, iterations, passwordKeyLength)
+}
+
+// OwnedAccounts lists the accounts that belong to the customer.
+func OwnedAccounts(customerID string) ([]Account, error) {
+	accounts, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	owned := []Account{}
+	for _, acc := range accounts {
+		if acc.OwnedBy(customerID) {
+			owned = append(owned, acc)
+		}
+	}
+	return owned, nil
+}
+
+// OwnedBy reports whether the account belongs to the customer. Accounts
+// without an owner belong to nobody.
+func (account *Account) OwnedBy(customerID string) bool {
+	return customerID != "" && account.Owner == customerID
+}
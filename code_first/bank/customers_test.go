@@ -0,0 +1,91 @@
+package bank
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCustomerStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "customers.json")
+	cs, err := NewCustomerStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	customer, err := cs.Register("Alice", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cs.Register("alice", "another password"); !errors.Is(err, ErrCustomerExists) {
+		t.Errorf("duplicate name: got %v, want %v", err, ErrCustomerExists)
+	}
+	if _, err := cs.Register("Bob", "short"); !errors.Is(err, ErrWeakPassword) {
+		t.Errorf("short password: got %v, want %v", err, ErrWeakPassword)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "correct horse") {
+		t.Error("customer file must hold a hash, not the password")
+	}
+
+	reloaded, err := NewCustomerStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := reloaded.Authenticate("ALICE", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Id != customer.Id {
+		t.Errorf("authenticated %s, want %s", got.Id, customer.Id)
+	}
+
+	for _, creds := range [][2]string{{"Alice", "wrong horse"}, {"Mallory", "correct horse"}} {
+		if _, err := reloaded.Authenticate(creds[0], creds[1]); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("Authenticate(%q, %q) = %v, want %v", creds[0], creds[1], err, ErrInvalidCredentials)
+		}
+	}
+}
+
+func TestAssignOwner(t *testing.T) {
+	for name, setup := range map[string]func(t *testing.T){
+		"memory": func(t *testing.T) { useStore(t) },
+		"events": func(t *testing.T) { useEventStore(t, t.TempDir()) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			setup(t)
+
+			acc := &Account{Id: "owned", Name: "Owned", Balance: eur(10), AccountType: Savings}
+			if err := OpenAccount(acc); err != nil {
+				t.Fatal(err)
+			}
+			if err := AssignOwner("owned", "cus_1"); err != nil {
+				t.Fatal(err)
+			}
+			if err := acc.Deposit(eur(5)); err != nil {
+				t.Fatal(err)
+			}
+			if err := AssignOwner("missing", "cus_1"); !errors.Is(err, ErrAccountNotFound) {
+				t.Errorf("unknown account: got %v, want %v", err, ErrAccountNotFound)
+			}
+
+			owned, err := OwnedAccounts("cus_1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(owned) != 1 || owned[0].Balance != eur(15) {
+				t.Errorf("owned accounts = %+v, want one with balance 15", owned)
+			}
+			if others, _ := OwnedAccounts(""); len(others) != 0 {
+				t.Errorf("accounts without owner must not match the empty customer: %+v", others)
+			}
+		})
+	}
+}
@@ -2,44 +2,120 @@ package bank
 
 import (
 	"encoding/json"
-	"fmt"
 	"os"
+	"sync"
 )
 
 const dbFile = "acc_db.json"
 
-func AddOrUpdateAcc(newAcc *Account) {
-	allAcc, err := LoadAcc()
+type JSONStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewJSONStore(path string) *JSONStore {
+	return &JSONStore{path: path}
+}
+
+func (js *JSONStore) Get(id string) (*Account, error) {
+	accounts, err := js.List()
 	if err != nil {
-		fmt.Println("could not load accounts:", err)
+		return nil, err
 	}
 
-	updated := false
-	for index, account := range allAcc {
-		if account.Id == newAcc.Id {
-			allAcc[index] = *newAcc
-			updated = true
-			break
+	for i := range accounts {
+		if accounts[i].Id == id {
+			return &accounts[i], nil
 		}
 	}
+	return nil, ErrAccountNotFound
+}
+
+func (js *JSONStore) List() ([]Account, error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
 
-	if !updated {
-		allAcc = append(allAcc, *newAcc)
+	unlock, err := lockFile(js.path+".lock", false)
+	if err != nil {
+		return nil, err
 	}
+	defer unlock()
 
-	data, err := json.MarshalIndent(allAcc, "", "  ")
+	return js.load()
+}
+
+func (js *JSONStore) Save(account *Account) error {
+	return js.SaveAll(account)
+}
+
+func (js *JSONStore) SaveAll(accounts ...*Account) error {
+	return js.update(func(allAcc []Account) ([]Account, error) {
+		for _, newAcc := range accounts {
+			updated := false
+			for index, account := range allAcc {
+				if account.Id == newAcc.Id {
+					allAcc[index] = *newAcc
+					updated = true
+					break
+				}
+			}
+
+			if !updated {
+				allAcc = append(allAcc, *newAcc)
+			}
+		}
+		return allAcc, nil
+	})
+}
+
+func (js *JSONStore) Delete(id string) error {
+	return js.update(func(allAcc []Account) ([]Account, error) {
+		for index, account := range allAcc {
+			if account.Id == id {
+				return append(allAcc[:index], allAcc[index+1:]...), nil
+			}
+		}
+		return nil, ErrAccountNotFound
+	})
+}
+
+func (js *JSONStore) FindByName(name string) (*Account, error) {
+	accounts, err := js.List()
+	if err != nil {
+		return nil, err
+	}
+	return findByName(accounts, name)
+}
+
+func (js *JSONStore) update(fn func([]Account) ([]Account, error)) error {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	unlock, err := lockFile(js.path+".lock", true)
 	if err != nil {
-		fmt.Println("could not save account:", err)
-		return
+		return err
 	}
+	defer unlock()
 
-	if err := os.WriteFile(dbFile, data, 0644); err != nil {
-		fmt.Println("could not write file:", err)
+	allAcc, err := js.load()
+	if err != nil {
+		return err
+	}
+
+	allAcc, err = fn(allAcc)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(allAcc, "", "  ")
+	if err != nil {
+		return err
 	}
+	return writeFileAtomic(js.path, data, 0644)
 }
 
-func LoadAcc() ([]Account, error) {
-	data, err := os.ReadFile(dbFile)
+func (js *JSONStore) load() ([]Account, error) {
+	data, err := os.ReadFile(js.path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return []Account{}, nil
@@ -0,0 +1,232 @@
+package bank
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const dirStoreTxnFile = ".txn.json"
+
+type DirStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func NewDirStore(dir string) *DirStore {
+	return &DirStore{dir: dir}
+}
+
+func (ds *DirStore) Get(id string) (*Account, error) {
+	path, err := ds.accountPath(id)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := ds.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return readAccountFile(path)
+}
+
+func (ds *DirStore) List() ([]Account, error) {
+	unlock, err := ds.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	entries, err := os.ReadDir(ds.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Account{}, nil
+		}
+		return nil, err
+	}
+
+	accounts := []Account{}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		acc, err := readAccountFile(filepath.Join(ds.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *acc)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Id < accounts[j].Id })
+	return accounts, nil
+}
+
+func (ds *DirStore) Save(account *Account) error {
+	return ds.SaveAll(account)
+}
+
+// SaveAll first writes every account into a transaction file and only then
+// replaces the per-account files, so a crash in between is finished by
+// recover on the next access instead of leaving half of a transfer on disk.
+func (ds *DirStore) SaveAll(accounts ...*Account) error {
+	for _, account := range accounts {
+		if _, err := ds.accountPath(account.Id); err != nil {
+			return err
+		}
+	}
+
+	unlock, err := ds.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if len(accounts) == 1 {
+		return ds.writeAccounts(accounts)
+	}
+
+	data, err := json.Marshal(accounts)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(ds.txnPath(), data, 0644); err != nil {
+		return err
+	}
+	if err := ds.writeAccounts(accounts); err != nil {
+		return err
+	}
+	return os.Remove(ds.txnPath())
+}
+
+func (ds *DirStore) Delete(id string) error {
+	path, err := ds.accountPath(id)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := ds.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return ErrAccountNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (ds *DirStore) FindByName(name string) (*Account, error) {
+	accounts, err := ds.List()
+	if err != nil {
+		return nil, err
+	}
+	return findByName(accounts, name)
+}
+
+func (ds *DirStore) lock(exclusive bool) (func() error, error) {
+	ds.mu.Lock()
+
+	if err := os.MkdirAll(ds.dir, 0755); err != nil {
+		ds.mu.Unlock()
+		return nil, err
+	}
+
+	unlock, err := lockFile(filepath.Join(ds.dir, ".lock"), true)
+	if err != nil {
+		ds.mu.Unlock()
+		return nil, err
+	}
+
+	if err := ds.recover(); err != nil {
+		unlock()
+		ds.mu.Unlock()
+		return nil, err
+	}
+	if !exclusive {
+		// Recovery needs the exclusive lock, readers can share afterwards.
+		unlock()
+		if unlock, err = lockFile(filepath.Join(ds.dir, ".lock"), false); err != nil {
+			ds.mu.Unlock()
+			return nil, err
+		}
+	}
+
+	return func() error {
+		defer ds.mu.Unlock()
+		return unlock()
+	}, nil
+}
+
+func (ds *DirStore) recover() error {
+	data, err := os.ReadFile(ds.txnPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var accounts []*Account
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		return err
+	}
+	if err := ds.writeAccounts(accounts); err != nil {
+		return err
+	}
+	return os.Remove(ds.txnPath())
+}
+
+func (ds *DirStore) writeAccounts(accounts []*Account) error {
+	for _, account := range accounts {
+		path, err := ds.accountPath(account.Id)
+		if err != nil {
+			return err
+		}
+
+		data, err := json.MarshalIndent(account, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(path, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ds *DirStore) txnPath() string {
+	return filepath.Join(ds.dir, dirStoreTxnFile)
+}
+
+func (ds *DirStore) accountPath(id string) (string, error) {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return "", fmt.Errorf("invalid account id: %q", id)
+	}
+	return filepath.Join(ds.dir, id+".json"), nil
+}
+
+func readAccountFile(path string) (*Account, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrAccountNotFound
+		}
+		return nil, err
+	}
+
+	var acc Account
+	if err := json.Unmarshal(data, &acc); err != nil {
+		return nil, err
+	}
+	return &acc, nil
+}
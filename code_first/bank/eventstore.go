@@ -0,0 +1,397 @@
+package bank
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	journalFile   = "journal.jsonl"
+	snapshotFile  = "snapshot.json"
+	snapshotEvery = 100
+)
+
+// ErrUnrecordedChange is returned for an account saved to the event store
+// with changes that no event recorded, which the journal could not replay.
+var ErrUnrecordedChange = errors.New("account changed without recorded events")
+
+type snapshot struct {
+	Seq      uint64
+	Offset   int64
+	Accounts []Account
+}
+
+// EventStore keeps accounts as a journal of events with a snapshot every
+// SnapshotEvery events. A snapshot that cannot be written does not fail the
+// save, the events are in the journal and the next save tries again;
+// OnSnapshotError is told about the failure if it is set.
+type EventStore struct {
+	mu              sync.Mutex
+	dir             string
+	seq             uint64
+	offset          int64
+	snapshotSeq     uint64
+	SnapshotEvery   int
+	OnSnapshotError func(error)
+	accounts        map[string]Account
+}
+
+func NewEventStore(dir string) (*EventStore, error) {
+	es := &EventStore{
+		dir:           dir,
+		SnapshotEvery: snapshotEvery,
+		accounts:      map[string]Account{},
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := es.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	unlock, err := es.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	return es, unlock()
+}
+
+func (es *EventStore) Get(id string) (*Account, error) {
+	unlock, err := es.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	acc, ok := es.accounts[id]
+	if !ok {
+		return nil, ErrAccountNotFound
+	}
+	acc = acc.clone()
+	return &acc, nil
+}
+
+func (es *EventStore) List() ([]Account, error) {
+	unlock, err := es.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return es.list(), nil
+}
+
+func (es *EventStore) FindByName(name string) (*Account, error) {
+	accounts, err := es.List()
+	if err != nil {
+		return nil, err
+	}
+	return findByName(accounts, name)
+}
+
+func (es *EventStore) Save(account *Account) error {
+	return es.SaveAll(account)
+}
+
+func (es *EventStore) SaveAll(accounts ...*Account) error {
+	unlock, err := es.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var events []Event
+	for _, account := range accounts {
+		stored, ok := es.accounts[account.Id]
+		if !ok {
+			opened, err := openingEvent(account)
+			if err != nil {
+				return err
+			}
+			events = append(events, opened)
+		} else if len(account.pending) == 0 && changedWithoutEvents(stored, *account) {
+			return fmt.Errorf("%w: %s", ErrUnrecordedChange, account.Id)
+		}
+		events = append(events, account.pending...)
+	}
+	return es.append(events)
+}
+
+func (es *EventStore) Delete(id string) error {
+	unlock, err := es.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := es.accounts[id]; !ok {
+		return ErrAccountNotFound
+	}
+	return es.append([]Event{{Time: time.Now(), Type: AccountClosed, AccountID: id}})
+}
+
+func (es *EventStore) Events() ([]Event, error) {
+	unlock, err := es.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var events []Event
+	_, err = es.readJournal(0, func(e Event) error {
+		events = append(events, e)
+		return nil
+	})
+	return events, err
+}
+
+func (es *EventStore) Snapshot() error {
+	unlock, err := es.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return es.writeSnapshot()
+}
+
+// lock serializes access inside the process and, through a lock file, with
+// other processes sharing the directory, then catches up on events they
+// appended since the last call.
+func (es *EventStore) lock(exclusive bool) (func() error, error) {
+	es.mu.Lock()
+
+	unlock, err := lockFile(filepath.Join(es.dir, ".lock"), exclusive)
+	if err != nil {
+		es.mu.Unlock()
+		return nil, err
+	}
+
+	if err := es.catchUp(); err != nil {
+		unlock()
+		es.mu.Unlock()
+		return nil, err
+	}
+
+	return func() error {
+		defer es.mu.Unlock()
+		return unlock()
+	}, nil
+}
+
+func (es *EventStore) catchUp() error {
+	offset, err := es.readJournal(es.offset, func(e Event) error {
+		if e.Seq <= es.seq {
+			return nil
+		}
+
+		next := map[string]*Account{}
+		if err := applyTo(es.accounts, next, e); err != nil {
+			return fmt.Errorf("replaying event %d: %w", e.Seq, err)
+		}
+		commit(es.accounts, next)
+		es.seq = e.Seq
+		return nil
+	})
+	es.offset = offset
+	return err
+}
+
+func (es *EventStore) append(events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	next := map[string]*Account{}
+	var lines bytes.Buffer
+	seq := es.seq
+	for _, e := range events {
+		seq++
+		e.Seq = seq
+		if err := applyTo(es.accounts, next, e); err != nil {
+			return err
+		}
+
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		lines.Write(append(line, '\n'))
+	}
+
+	f, err := os.OpenFile(es.journalPath(), os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	// Anything after the last complete line is a write torn by a crash.
+	if err := f.Truncate(es.offset); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.WriteAt(lines.Bytes(), es.offset); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	commit(es.accounts, next)
+	es.seq = seq
+	es.offset += int64(lines.Len())
+
+	if es.SnapshotEvery > 0 && es.seq-es.snapshotSeq >= uint64(es.SnapshotEvery) {
+		if err := es.writeSnapshot(); err != nil && es.OnSnapshotError != nil {
+			es.OnSnapshotError(err)
+		}
+	}
+	return nil
+}
+
+// changedWithoutEvents reports whether account differs from the stored
+// account in anything events set.
+func changedWithoutEvents(stored, account Account) bool {
+	return stored.Name != account.Name || stored.IBAN != account.IBAN ||
+		stored.Balance != account.Balance || !maps.Equal(stored.SubBalances, account.SubBalances) ||
+		stored.Overdraw != account.Overdraw || stored.AccountType != account.AccountType ||
+		stored.Owner != account.Owner || stored.Status != account.Status ||
+		len(stored.Transactions) != len(account.Transactions)
+}
+
+func applyTo(current map[string]Account, next map[string]*Account, e Event) error {
+	acc, staged := next[e.AccountID]
+	if !staged {
+		if existing, ok := current[e.AccountID]; ok {
+			existing = existing.clone()
+			acc = &existing
+		}
+	}
+
+	if acc == nil && e.Type != AccountOpened {
+		return fmt.Errorf("%w: %s", ErrAccountNotFound, e.AccountID)
+	}
+	if e.Type == AccountClosed {
+		next[e.AccountID] = nil
+		return nil
+	}
+	if acc == nil {
+		acc = &Account{}
+	}
+
+	if err := acc.apply(e); err != nil {
+		return err
+	}
+	next[e.AccountID] = acc
+	return nil
+}
+
+func commit(accounts map[string]Account, next map[string]*Account) {
+	for id, acc := range next {
+		if acc == nil {
+			delete(accounts, id)
+			continue
+		}
+		accounts[id] = *acc
+	}
+}
+
+// readJournal calls fn for every complete line after offset and returns the
+// offset just past the last complete line.
+func (es *EventStore) readJournal(offset int64, fn func(Event) error) (int64, error) {
+	f, err := os.Open(es.journalPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return offset, nil
+		}
+		return offset, err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return offset, err
+	}
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return offset, nil
+		}
+		if err != nil {
+			return offset, err
+		}
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			var e Event
+			if err := json.Unmarshal(line, &e); err != nil {
+				return offset, err
+			}
+			if err := fn(e); err != nil {
+				return offset, err
+			}
+		}
+		offset += int64(len(line))
+	}
+}
+
+func (es *EventStore) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(es.dir, snapshotFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	for _, acc := range snap.Accounts {
+		es.accounts[acc.Id] = acc
+	}
+	es.seq, es.snapshotSeq, es.offset = snap.Seq, snap.Seq, snap.Offset
+	return nil
+}
+
+func (es *EventStore) writeSnapshot() error {
+	snap := snapshot{Seq: es.seq, Offset: es.offset, Accounts: es.list()}
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(filepath.Join(es.dir, snapshotFile), data, 0644); err != nil {
+		return err
+	}
+	es.snapshotSeq = es.seq
+	return nil
+}
+
+func (es *EventStore) list() []Account {
+	accounts := make([]Account, 0, len(es.accounts))
+	for _, acc := range es.accounts {
+		accounts = append(accounts, acc.clone())
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Id < accounts[j].Id })
+	return accounts
+}
+
+func (es *EventStore) journalPath() string {
+	return filepath.Join(es.dir, journalFile)
+}
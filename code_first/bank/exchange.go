@@ -0,0 +1,140 @@
+package bank
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+var ErrSameCurrency = errors.New("cannot exchange a currency into itself")
+
+// Exchange sells amount out of the balance in its currency, the base
+// currency if it has none, and books what it buys in target at the current
+// rate less the spread of the quote store. The proceeds are rounded down to
+// whole minor units. It returns the amount bought.
+func (account *Account) Exchange(amount Money, target Currency, reference ...string) (Money, error) {
+	return account.ExchangeAt(amount, target, time.Time{}, reference...)
+}
+
+// ExchangeAt exchanges at the rate of valueDate, which prices a backdated
+// booking. The rate and the day it was published for are stored on both
+// sides of the transaction.
+func (account *Account) ExchangeAt(amount Money, target Currency, valueDate time.Time, reference ...string) (Money, error) {
+	receipt, err := account.BookExchange(amount, target, valueDate, reference...)
+	if err != nil {
+		return Money{}, err
+	}
+	// The bought side follows the sold side.
+	return receipt.Transactions[1].Amount, nil
+}
+
+// BookExchange exchanges like ExchangeAt and returns the receipt of the
+// booking, the sold side first.
+func (account *Account) BookExchange(amount Money, target Currency, valueDate time.Time, reference ...string) (*Receipt, error) {
+	snapshot, err := account.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	amount, err = snapshot.sellAmount(amount, target)
+	if err != nil {
+		return nil, err
+	}
+
+	mid, err := ExchangeRate(amount.Currency, target, valueDate)
+	if err != nil {
+		return nil, err
+	}
+	// Exchanges without a quote pay the same spread as quoted ones.
+	rate := DatedRate{Date: mid.Date, Rate: quotes.customerRate(mid.Rate)}
+	bought, err := buyAmount(amount, rate.Rate, target)
+	if err != nil {
+		return nil, err
+	}
+
+	fee := fees.transactionFee(snapshot, Exchange)
+	return account.bookExchange(amount, bought, fee, rate, referenceText(reference))
+}
+
+// sellAmount checks the amount to sell for target and fills in the base
+// currency if it has none.
+func (account *Account) sellAmount(amount Money, target Currency) (Money, error) {
+	if err := checkAmount(amount); err != nil {
+		return Money{}, err
+	}
+	amount, err := amount.InCurrency(account.BaseCurrency())
+	if err != nil {
+		return Money{}, err
+	}
+	if target == "" || target == amount.Currency {
+		return Money{}, ErrSameCurrency
+	}
+	if err := checkCurrency(target); err != nil {
+		return Money{}, err
+	}
+	return amount, nil
+}
+
+// buyAmount is what amount buys of target at rate, rounded down to whole
+// minor units.
+func buyAmount(amount Money, rate *big.Rat, target Currency) (Money, error) {
+	bought, err := MoneyFromRat(new(big.Rat).Mul(amount.Rat(), rate), target, RoundDown)
+	if err != nil {
+		return Money{}, err
+	}
+	if !bought.IsPositive() {
+		return Money{}, fmt.Errorf("%w: %s buys no %s", ErrNonPositiveAmount, amount, target.Code())
+	}
+	return bought, nil
+}
+
+// bookExchange records both sides of an exchange at the given rate, charges
+// fee and posts the journal entry.
+func (account *Account) bookExchange(amount, bought, fee Money, rate DatedRate, reference string) (*Receipt, error) {
+	var receipt *Receipt
+	err := update(func() error {
+		booked := len(account.Transactions)
+		if _, err := account.checkDebit(amount, fee); err != nil {
+			return err
+		}
+		if _, err := account.checkCredit(bought); err != nil {
+			return err
+		}
+
+		out := Event{
+			Type:          ExchangedOut,
+			Amount:        amount,
+			TransactionID: newTransactionID(),
+			Reference:     reference,
+			Rate:          formatRate(rate.Rate),
+			RateDate:      rate.Date,
+		}
+		in := out
+		in.Type, in.Amount = ExchangedIn, bought
+		if err := account.record(out); err != nil {
+			return err
+		}
+		if err := account.record(in); err != nil {
+			return err
+		}
+
+		entry := NewEntry("exchange "+account.Id,
+			Leg{Account: account.Id, Side: Debit, Amount: amount},
+			Leg{Account: FXSuspenseAccount, Side: Credit, Amount: amount},
+			Leg{Account: FXSuspenseAccount, Side: Debit, Amount: bought},
+			Leg{Account: account.Id, Side: Credit, Amount: bought},
+		)
+		entry.TransactionID = out.TransactionID
+
+		entries, err := account.addTransactionFee([]JournalEntry{entry}, fee, Exchange)
+		if err != nil {
+			return err
+		}
+		if err := postAllAndSave(entries, account); err != nil {
+			return err
+		}
+		receipt = account.receipt(booked)
+		return nil
+	}, account)
+	return receipt, err
+}
@@ -0,0 +1,203 @@
+package bank
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useRates serves the given rates for one unit of any base currency.
+func useRates(t *testing.T, quotes map[Currency]string) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target := Currency(strings.ToLower(r.URL.Query().Get("to")))
+		rate, ok := quotes[target]
+		if !ok {
+			http.Error(w, "unknown currency", http.StatusNotFound)
+			return
+		}
+		day := strings.TrimPrefix(r.URL.Path, "/")
+		if day == "latest" {
+			day = "2026-01-05"
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"base":  r.URL.Query().Get("from"),
+			"date":  day,
+			"rates": map[string]json.Number{target.Code(): json.Number(rate)},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	original := rates
+	SetRates(NewFrankfurterProvider(server.URL, time.Second))
+	t.Cleanup(func() { SetRates(original) })
+}
+
+func TestSubBalances(t *testing.T) {
+	useStore(t)
+	b := useBooks(t)
+	openTestAccounts(t, &Account{Id: "acc", Name: "Acc", Balance: eur(100), Overdraw: eur(50), AccountType: Giro})
+
+	acc, err := Store().Get("acc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := acc.Deposit(NewMoney(2000, USD)); err != nil {
+		t.Fatal(err)
+	}
+	if err := acc.Withdraw(NewMoney(500, USD)); err != nil {
+		t.Fatal(err)
+	}
+	if err := acc.Withdraw(NewMoney(1600, USD)); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("overdrawing a sub-balance: got %v, want %v", err, ErrInsufficientFunds)
+	}
+	if err := acc.Withdraw(eur(140)); err != nil {
+		t.Errorf("overdraft on the base currency: %v", err)
+	}
+
+	stored, err := Store().Get("acc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Balance != eur(-40) || stored.BalanceIn(USD) != NewMoney(1500, USD) || stored.BalanceIn(GBP) != NewMoney(0, GBP) {
+		t.Errorf("balances = %v and %v, want -40.00 EUR and 15.00 USD", stored.Balance, stored.SubBalances)
+	}
+	if got := b.Balance("acc", USD); got != NewMoney(1500, USD) {
+		t.Errorf("books USD balance = %v, want 15.00 USD", got)
+	}
+}
+
+func TestExchange(t *testing.T) {
+	useStore(t)
+	b := useBooks(t)
+	useRates(t, map[Currency]string{USD: "1.0832", EUR: "0.9232"})
+	openTestAccounts(t, &Account{Id: "acc", Name: "Acc", Balance: eur(100), AccountType: Savings})
+
+	acc, err := Store().Get("acc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bought, err := acc.Exchange(eur(10.01), USD, "holiday")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 10.01 * 1.0832 less the spread of 0.5% = 10.78861784, rounded down.
+	if bought != NewMoney(1078, USD) {
+		t.Errorf("bought %v, want 10.78 USD", bought)
+	}
+	if acc.Balance != eur(89.99) || acc.BalanceIn(USD) != bought {
+		t.Errorf("balances = %v and %v, want 89.99 EUR and 10.78 USD", acc.Balance, acc.SubBalances)
+	}
+
+	out, in := acc.Transactions[0], acc.Transactions[1]
+	if out.Type != Exchange || in.Type != Exchange || out.Id != in.Id || out.Rate != "1.077784" || in.Reference != "holiday" {
+		t.Errorf("transactions = %+v and %+v, want both sides of one exchange at 1.077784", out, in)
+	}
+	if in.RateDate != date(2026, 1, 5, 0, 0) {
+		t.Errorf("rate date = %v, want the date the rate was published for", in.RateDate)
+	}
+	if _, err := b.TrialBalance(); err != nil {
+		t.Errorf("books after exchange: %v", err)
+	}
+	if got := b.Balance(FXSuspenseAccount, EUR); got != eur(10.01) {
+		t.Errorf("fx suspense = %v, want 10.01 EUR", got)
+	}
+
+	if _, err := acc.Exchange(NewMoney(1000, USD), USD); !errors.Is(err, ErrSameCurrency) {
+		t.Errorf("same currency: got %v, want %v", err, ErrSameCurrency)
+	}
+	if _, err := acc.Exchange(NewMoney(2000, USD), EUR); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("selling more than the sub-balance: got %v, want %v", err, ErrInsufficientFunds)
+	}
+	if _, err := acc.Exchange(eur(1), GBP); !errors.Is(err, ErrRateUnavailable) {
+		t.Errorf("unknown rate: got %v, want %v", err, ErrRateUnavailable)
+	}
+
+	if _, err := Reverse(out.Id); err != nil {
+		t.Fatal(err)
+	}
+	if acc, err = Store().Get("acc"); err != nil {
+		t.Fatal(err)
+	}
+	if acc.Balance != eur(100) || !acc.BalanceIn(USD).IsZero() {
+		t.Errorf("balances after reversal = %v and %v, want 100.00 EUR and nothing in USD", acc.Balance, acc.SubBalances)
+	}
+}
+
+func TestSubBalancesReplay(t *testing.T) {
+	dir := t.TempDir()
+	useEventStore(t, dir)
+	useBooks(t)
+	useRates(t, map[Currency]string{GBP: "0.85"})
+	openTestAccounts(t, &Account{Id: "acc", Name: "Acc", Balance: eur(100), AccountType: Savings})
+
+	acc, err := Store().Get("acc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := acc.Deposit(NewMoney(700, USD)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := acc.Exchange(eur(20), GBP); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, err := NewEventStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := replayed.Get("acc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Balance != eur(80) || got.BalanceIn(USD) != NewMoney(700, USD) || got.BalanceIn(GBP) != NewMoney(1691, GBP) {
+		t.Errorf("replayed balances = %v and %v, want 80.00 EUR, 7.00 USD and 16.91 GBP", got.Balance, got.SubBalances)
+	}
+}
+
+func TestBackdatedExchange(t *testing.T) {
+	useStore(t)
+	useBooks(t)
+	openTestAccounts(t, &Account{Id: "acc", Name: "Acc", Balance: eur(100), AccountType: Savings})
+
+	archive, err := LoadStaticRates(writeRatesFile(t, "hist.xml", ecbDaily))
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := rates
+	SetRates(archive)
+	t.Cleanup(func() { SetRates(original) })
+
+	acc, err := Store().Get("acc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bought, err := acc.ExchangeAt(eur(100), USD, date(2024, 1, 4, 12, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bought != NewMoney(10845, USD) {
+		t.Errorf("bought %v, want 108.45 USD at the rate of 2024-01-04 less the spread", bought)
+	}
+	if txn := acc.Transactions[0]; txn.Rate != "1.08455" || txn.RateDate != date(2024, 1, 4, 0, 0) {
+		t.Errorf("transaction = %+v, want rate 1.08455 of 2024-01-04", txn)
+	}
+
+	if _, err := acc.ExchangeAt(NewMoney(100, USD), EUR, date(2023, 12, 1, 0, 0)); !errors.Is(err, ErrRateUnavailable) {
+		t.Errorf("value date before the archive: got %v, want %v", err, ErrRateUnavailable)
+	}
+
+	converted, err := ConvertCurrencyOn(eur(10), EUR, USD, date(2024, 1, 5, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *converted != NewMoney(1092, USD) {
+		t.Errorf("converted = %v, want 10.92 USD", converted)
+	}
+}
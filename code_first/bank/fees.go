@@ -0,0 +1,367 @@
+package bank
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FeeSchedule lists what an account type is charged. Zero amounts and an
+// empty OverdraftRate charge nothing.
+type FeeSchedule struct {
+	// OverdraftRate is the annual rate charged on negative daily balances,
+	// accrued daily and charged at the end of each month.
+	OverdraftRate     string
+	OverdraftDayCount DayCount
+	// Maintenance is charged at the end of each month.
+	Maintenance     Money
+	PerTransaction  map[TransactionType]Money
+	ReturnedPayment Money
+}
+
+var DefaultFeeSchedules = map[AccountType]FeeSchedule{
+	Giro: {
+		OverdraftRate:     "9.5%",
+		OverdraftDayCount: Act365,
+		ReturnedPayment:   NewMoney(500, DefaultCurrency),
+	},
+}
+
+func (s FeeSchedule) Validate() error {
+	if s.OverdraftRate != "" {
+		if _, err := ParseRate(s.OverdraftRate); err != nil {
+			return err
+		}
+		switch s.OverdraftDayCount {
+		case Act365, Act360, Thirty360:
+		default:
+			return fmt.Errorf("unknown day count %q", s.OverdraftDayCount)
+		}
+	}
+
+	amounts := []Money{s.Maintenance, s.ReturnedPayment}
+	for _, fee := range s.PerTransaction {
+		amounts = append(amounts, fee)
+	}
+	for _, fee := range amounts {
+		if fee.IsNegative() {
+			return errors.New("fees cannot be negative")
+		}
+	}
+	return nil
+}
+
+// FeeState holds the overdraft interest of one account that has not been
+// charged yet, in minor units.
+type FeeState struct {
+	AccountID      string
+	AccruedThrough time.Time
+	Overdraft      *big.Rat
+}
+
+// FeeEngine charges per-transaction fees as part of the booking and runs the
+// daily job for overdraft interest and maintenance fees. The job saves its
+// state with each month's charges and never books a month twice.
+type FeeEngine struct {
+	mu        sync.Mutex
+	path      string
+	Schedules map[AccountType]FeeSchedule
+	states    map[string]FeeState
+}
+
+var fees = NewMemoryFeeEngine(nil)
+
+func SetFees(e *FeeEngine) {
+	fees = e
+}
+
+func Fees() *FeeEngine {
+	return fees
+}
+
+func NewMemoryFeeEngine(schedules map[AccountType]FeeSchedule) *FeeEngine {
+	return &FeeEngine{Schedules: schedules, states: map[string]FeeState{}}
+}
+
+func NewFeeEngine(path string, schedules map[AccountType]FeeSchedule) (*FeeEngine, error) {
+	for accType, schedule := range schedules {
+		if err := schedule.Validate(); err != nil {
+			return nil, fmt.Errorf("fees for %s: %w", accType, err)
+		}
+	}
+
+	e := NewMemoryFeeEngine(schedules)
+	e.path = path
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return e, nil
+		}
+		return nil, err
+	}
+
+	var states []FeeState
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, err
+	}
+	for _, state := range states {
+		if state.Overdraft == nil {
+			state.Overdraft = new(big.Rat)
+		}
+		e.states[state.AccountID] = state
+	}
+	return e, nil
+}
+
+// LoadFeeSchedules reads a JSON object mapping account types to fee
+// schedules.
+func LoadFeeSchedules(path string) (map[AccountType]FeeSchedule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var schedules map[AccountType]FeeSchedule
+	if err := json.Unmarshal(data, &schedules); err != nil {
+		return nil, err
+	}
+	for accType, schedule := range schedules {
+		if err := schedule.Validate(); err != nil {
+			return nil, fmt.Errorf("fees for %s: %w", accType, err)
+		}
+	}
+	return schedules, nil
+}
+
+func (e *FeeEngine) State(accountID string) (FeeState, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	state, ok := e.states[accountID]
+	if ok {
+		state.Overdraft = new(big.Rat).Set(state.Overdraft)
+	}
+	return state, ok
+}
+
+// transactionFee is what the account pays for a transaction of type tt.
+func (e *FeeEngine) transactionFee(account *Account, tt TransactionType) Money {
+	fee := e.Schedules[account.AccountType].PerTransaction[tt]
+	return fee.WithCurrency(account.Balance.Currency)
+}
+
+// Run accrues overdraft interest for every day before asOf and charges it,
+// together with the maintenance fee, for each month that ended.
+func (e *FeeEngine) Run(asOf time.Time) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	accounts, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	today := startOfDay(asOf)
+	var errs []error
+	for _, acc := range accounts {
+		schedule, ok := e.Schedules[acc.AccountType]
+		if !ok {
+			continue
+		}
+		if err := e.accrue(&acc, schedule, today); err != nil {
+			errs = append(errs, fmt.Errorf("fees for %s: %w", acc.Id, err))
+		}
+	}
+
+	if err := e.persist(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// RunEvery calls Run with the current time every interval until ctx is done.
+func (e *FeeEngine) RunEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.Run(time.Now()); err != nil {
+			fmt.Println("fees:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *FeeEngine) accrue(acc *Account, schedule FeeSchedule, today time.Time) error {
+	state, ok := e.states[acc.Id]
+	if !ok {
+		e.states[acc.Id] = FeeState{AccountID: acc.Id, AccruedThrough: today, Overdraft: new(big.Rat)}
+		return nil
+	}
+
+	overdraft := InterestProduct{
+		Name:     "overdraft",
+		Tiers:    []InterestTier{{Rate: schedule.OverdraftRate}},
+		DayCount: schedule.OverdraftDayCount,
+	}
+	monthly := InterestProduct{Capitalization: CapitalizeMonthly}
+	signed := books.transactionDeltas(acc.Id, acc.BaseCurrency())
+
+	for day, n := state.AccruedThrough, 0; day.Before(today) && n < maxAccrualDays; day, n = day.AddDate(0, 0, 1), n+1 {
+		// The day only counts once all of it is booked, so a failed
+		// charge is retried by the next run.
+		next := day.AddDate(0, 0, 1)
+		accrued := new(big.Rat).Set(state.Overdraft)
+
+		// Each missed day is charged on the balance it closed with, not on
+		// today's.
+		balance := acc.balanceAt(acc.BaseCurrency(), next, signed)
+		if schedule.OverdraftRate != "" && balance.IsNegative() {
+			interest, err := overdraft.dailyInterest(balance.Neg(), day)
+			if err != nil {
+				return err
+			}
+			accrued.Add(accrued, interest)
+		}
+
+		if !monthly.periodEnds(day) {
+			state.Overdraft, state.AccruedThrough = accrued, next
+			continue
+		}
+
+		period := day.Format("2006-01")
+		minor, err := roundRat(accrued, RoundDown)
+		if err != nil {
+			e.states[acc.Id] = state
+			return err
+		}
+		if minor > 0 {
+			charged, err := acc.chargeFeeOnce(NewMoney(minor, acc.Balance.Currency), "overdraft interest "+period, next)
+			if err != nil {
+				e.states[acc.Id] = state
+				return err
+			}
+			accrued.Sub(accrued, new(big.Rat).SetInt64(charged.Minor))
+		}
+
+		if schedule.Maintenance.IsPositive() {
+			fee := schedule.Maintenance.WithCurrency(acc.Balance.Currency)
+			if _, err := acc.chargeFeeOnce(fee, "maintenance fee "+period, next); err != nil {
+				e.states[acc.Id] = state
+				return err
+			}
+		}
+
+		state.Overdraft, state.AccruedThrough = accrued, next
+		e.states[acc.Id] = state
+		if err := e.persist(); err != nil {
+			return err
+		}
+	}
+
+	e.states[acc.Id] = state
+	return nil
+}
+
+// ChargeReturnedPayment charges the returned-payment fee of the account's
+// type, if it has one.
+func (e *FeeEngine) ChargeReturnedPayment(accountID, reference string) error {
+	account, err := store.Get(accountID)
+	if err != nil {
+		return err
+	}
+
+	fee := e.Schedules[account.AccountType].ReturnedPayment.WithCurrency(account.Balance.Currency)
+	if !fee.IsPositive() {
+		return nil
+	}
+	return account.ChargeFee(fee, "returned payment fee: "+reference, time.Now())
+}
+
+func (e *FeeEngine) persist() error {
+	if e.path == "" {
+		return nil
+	}
+
+	states := make([]FeeState, 0, len(e.states))
+	for _, state := range e.states {
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].AccountID < states[j].AccountID })
+
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(e.path, data, 0644)
+}
+
+// ChargeFee books a fee the bank imposes, such as overdraft interest. Unlike
+// a withdrawal it may take the account past its overdraft limit.
+func (account *Account) ChargeFee(fee Money, reference string, at time.Time) error {
+	if !fee.IsPositive() {
+		return fmt.Errorf("fee should be larger then 0")
+	}
+
+	return update(func() error {
+		entry, err := account.chargeFee(fee, reference, at)
+		if err != nil {
+			return err
+		}
+		return postAndSave(entry, account)
+	}, account)
+}
+
+// chargeFeeOnce is ChargeFee for the charges of the daily job. A fee with
+// the same reference and time already on the account is returned instead of
+// charged again, so a run that could not save its state is safe to repeat.
+func (account *Account) chargeFeeOnce(fee Money, reference string, at time.Time) (Money, error) {
+	charged := fee
+	err := update(func() error {
+		for _, txn := range account.Transactions {
+			if txn.Type == Fee && txn.Time.Equal(at) && txn.Reference == reference {
+				charged = txn.Amount
+				return nil
+			}
+		}
+
+		entry, err := account.chargeFee(fee, reference, at)
+		if err != nil {
+			return err
+		}
+		return postAndSave(entry, account)
+	}, account)
+	if err != nil {
+		return Money{}, err
+	}
+	return charged, nil
+}
+
+// chargeFee records the fee on the account and returns the journal entry
+// that books it. The caller holds the account lock and saves.
+func (account *Account) chargeFee(fee Money, reference string, at time.Time) (JournalEntry, error) {
+	txID := newTransactionID()
+	err := account.record(Event{Type: FeeCharged, Time: at, Amount: fee, TransactionID: txID, Reference: reference})
+	if err != nil {
+		return JournalEntry{}, err
+	}
+
+	entry := NewEntry("fee "+account.Id,
+		Leg{Account: account.Id, Side: Debit, Amount: fee},
+		Leg{Account: FeeIncomeAccount, Side: Credit, Amount: fee},
+	)
+	entry.TransactionID = txID
+	return entry, nil
+}
@@ -0,0 +1,227 @@
+package bank
+
+import (
+	"bytes"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func useFees(t *testing.T, schedules map[AccountType]FeeSchedule) *FeeEngine {
+	t.Helper()
+
+	original := fees
+	e := NewMemoryFeeEngine(schedules)
+	SetFees(e)
+	t.Cleanup(func() { SetFees(original) })
+	return e
+}
+
+func TestTransactionFees(t *testing.T) {
+	useStore(t)
+	b := useBooks(t)
+	useFees(t, map[AccountType]FeeSchedule{
+		Giro: {PerTransaction: map[TransactionType]Money{Withdraw: eur(1), Transfer: eur(0.5)}},
+	})
+
+	giro := &Account{Id: "giro", Name: "Giro", Balance: eur(100), AccountType: Giro}
+	savings := &Account{Id: "savings", Name: "Savings", Balance: eur(100), AccountType: Savings}
+	openTestAccounts(t, giro, savings)
+
+	if err := giro.Withdraw(eur(10)); err != nil {
+		t.Fatal(err)
+	}
+	if err := giro.Transfer(eur(20), "Savings"); err != nil {
+		t.Fatal(err)
+	}
+	if err := savings.Withdraw(eur(10)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := balanceOf(t, "giro"); got != eur(68.5) {
+		t.Errorf("giro balance = %v, want 68.50", got)
+	}
+	if got := balanceOf(t, "savings"); got != eur(110) {
+		t.Errorf("savings balance = %v, want 110 without fees", got)
+	}
+	if got := b.Balance(FeeIncomeAccount, EUR); got != eur(1.5) {
+		t.Errorf("fee income = %v, want 1.50", got)
+	}
+
+	var types []TransactionType
+	for _, txn := range giro.Transactions {
+		types = append(types, txn.Type)
+	}
+	if want := []TransactionType{Withdraw, Fee, Transfer, Fee}; !slices.Equal(types, want) {
+		t.Errorf("giro transactions = %v, want %v", types, want)
+	}
+
+	// The fee counts towards the available funds.
+	if err := giro.Transfer(eur(68.5), "Savings"); err == nil {
+		t.Error("transfer left no room for its fee but was booked")
+	}
+	if got := balanceOf(t, "giro"); got != eur(68.5) {
+		t.Errorf("rejected transfer changed the balance to %v", got)
+	}
+
+	var buf bytes.Buffer
+	if err := giro.ShowAccountDetails(&buf, "", "type", "fee"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Type: fee, Reference: withdraw fee") {
+		t.Errorf("ShowAccountDetails does not show the fee:\n%s", buf.String())
+	}
+}
+
+func TestOverdraftInterestAndMaintenance(t *testing.T) {
+	useStore(t)
+	b := useBooks(t)
+	engine := useFees(t, map[AccountType]FeeSchedule{
+		Giro: {OverdraftRate: "10%", OverdraftDayCount: Act365, Maintenance: eur(2)},
+	})
+
+	openTestAccounts(t,
+		&Account{Id: "overdrawn", Name: "Overdrawn", Balance: eur(-3650), Overdraw: eur(5000), AccountType: Giro},
+		&Account{Id: "positive", Name: "Positive", Balance: eur(100), AccountType: Giro},
+		&Account{Id: "savings", Name: "Savings", Balance: eur(100), AccountType: Savings},
+	)
+
+	for _, asOf := range []time.Time{date(2026, 1, 1, 0, 0), date(2026, 2, 1, 6, 0), date(2026, 2, 1, 18, 0)} {
+		if err := engine.Run(asOf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 3650 at 10% is 1.00 a day, 31.00 for January, plus the maintenance fee.
+	if got := balanceOf(t, "overdrawn"); got != eur(-3683) {
+		t.Errorf("overdrawn balance = %v, want -3683", got)
+	}
+	if got := balanceOf(t, "positive"); got != eur(98) {
+		t.Errorf("positive balance = %v, want 98 after the maintenance fee only", got)
+	}
+	if got := balanceOf(t, "savings"); got != eur(100) {
+		t.Errorf("savings were charged: %v", got)
+	}
+	if got := b.Balance(FeeIncomeAccount, EUR); got != eur(35) {
+		t.Errorf("fee income = %v, want 35", got)
+	}
+
+	overdrawn, err := Store().Get("overdrawn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var references []string
+	for _, txn := range overdrawn.Transactions {
+		references = append(references, txn.Reference)
+	}
+	if want := []string{"overdraft interest 2026-01", "maintenance fee 2026-01"}; !slices.Equal(references, want) {
+		t.Errorf("fee references = %v, want %v", references, want)
+	}
+}
+
+func TestOverdraftInterestOnMissedDays(t *testing.T) {
+	useStore(t)
+	useBooks(t)
+	engine := useFees(t, map[AccountType]FeeSchedule{
+		Giro: {OverdraftRate: "10%", OverdraftDayCount: Act365},
+	})
+	openTestAccounts(t, &Account{Id: "overdrawn", Name: "Overdrawn", Balance: eur(-3650), Overdraw: eur(5000), AccountType: Giro})
+
+	for _, asOf := range []time.Time{date(2026, 1, 1, 0, 0), date(2026, 1, 16, 0, 0)} {
+		if err := engine.Run(asOf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	overdrawn, err := Store().Get("overdrawn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The deposit is booked today, after the days the next run catches up on.
+	if err := overdrawn.Deposit(eur(3650)); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Run(date(2026, 2, 1, 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every day of January closed 3650 overdrawn, 1.00 a day.
+	if got := balanceOf(t, "overdrawn"); got != eur(-31) {
+		t.Errorf("balance = %v, want -31 after 31.00 overdraft interest", got)
+	}
+}
+
+func TestFeesChargedOnceWhenStateIsLost(t *testing.T) {
+	useStore(t)
+	b := useBooks(t)
+	schedules := map[AccountType]FeeSchedule{
+		Giro: {OverdraftRate: "10%", OverdraftDayCount: Act365, Maintenance: eur(2)},
+	}
+	openTestAccounts(t, &Account{Id: "overdrawn", Name: "Overdrawn", Balance: eur(-3650), Overdraw: eur(5000), AccountType: Giro})
+
+	path := filepath.Join(t.TempDir(), "fees.json")
+	engine, err := NewFeeEngine(path, schedules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Run(date(2026, 1, 1, 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The fees are booked but their state cannot be saved, as if the
+	// process died in between.
+	engine.path = filepath.Join(t.TempDir(), "missing", "fees.json")
+	if err := engine.Run(date(2026, 2, 1, 0, 0)); err == nil {
+		t.Fatal("run saved its state into a missing directory")
+	}
+
+	if engine, err = NewFeeEngine(path, schedules); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Run(date(2026, 2, 1, 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if got := balanceOf(t, "overdrawn"); got != eur(-3683) {
+		t.Errorf("balance after the rerun = %v, want -3683 with January charged once", got)
+	}
+	if got := b.Balance(FeeIncomeAccount, EUR); got != eur(33) {
+		t.Errorf("fee income = %v, want 33", got)
+	}
+	if state, _ := engine.State("overdrawn"); !state.AccruedThrough.Equal(date(2026, 2, 1, 0, 0)) || state.Overdraft.Sign() != 0 {
+		t.Errorf("state after the rerun = %+v", state)
+	}
+}
+
+func TestReturnedPaymentFee(t *testing.T) {
+	scheduler, _ := useScheduler(t, date(2026, 1, 1, 0, 0))
+	b := useBooks(t)
+	useFees(t, map[AccountType]FeeSchedule{Giro: {ReturnedPayment: eur(5)}})
+
+	openTestAccounts(t,
+		&Account{Id: "payer", Name: "Payer", Balance: eur(10), Overdraw: eur(100), AccountType: Giro},
+		&Account{Id: "payee", Name: "Payee", Balance: eur(0), AccountType: Savings},
+	)
+
+	_, err := scheduler.Orders.Create(StandingOrder{
+		AccountID: "payer",
+		To:        "Payee",
+		Amount:    eur(500),
+		Schedule:  "monthly 1",
+		Start:     date(2026, 1, 1, 0, 0),
+		Retry:     RetryPolicy{MaxAttempts: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := scheduler.RunDue(); err != nil {
+		t.Fatal(err)
+	}
+	if got := balanceOf(t, "payer"); got != eur(5) {
+		t.Errorf("payer balance = %v, want 5 after the returned payment fee", got)
+	}
+	if got := b.Balance(FeeIncomeAccount, EUR); got != eur(5) {
+		t.Errorf("fee income = %v, want 5", got)
+	}
+}
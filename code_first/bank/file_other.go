@@ -0,0 +1,12 @@
+//go:build !unix
+
+package bank
+
+// Without flock the stores only serialize writers inside one process.
+func lockFile(path string, exclusive bool) (func() error, error) {
+	return func() error { return nil }, nil
+}
+
+func syncDir(dir string) error {
+	return nil
+}
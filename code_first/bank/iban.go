@@ -0,0 +1,385 @@
+package bank
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrInvalidIBAN = errors.New("invalid IBAN")
+	ErrInvalidBIC  = errors.New("invalid BIC")
+)
+
+// IBAN is an international bank account number in electronic form, upper
+// case without spaces.
+type IBAN string
+
+// ibanFormat is the length of a country's IBANs and where the bank code
+// sits in the BBAN, the part after the check digits.
+type ibanFormat struct {
+	length     int
+	bankOffset int
+	bankLength int
+}
+
+// ibanFormats covers the SEPA countries. IBANs of other countries are
+// checked for their checksum only.
+var ibanFormats = map[string]ibanFormat{
+	"AD": {24, 0, 4}, "AT": {20, 0, 5}, "BE": {16, 0, 3}, "BG": {22, 0, 4},
+	"CH": {21, 0, 5}, "CY": {28, 0, 3}, "CZ": {24, 0, 4}, "DE": {22, 0, 8},
+	"DK": {18, 0, 4}, "EE": {20, 0, 2}, "ES": {24, 0, 4}, "FI": {18, 0, 3},
+	"FR": {27, 0, 5}, "GB": {22, 0, 4}, "GI": {23, 0, 4}, "GR": {27, 0, 3},
+	"HR": {21, 0, 7}, "HU": {28, 0, 3}, "IE": {22, 0, 4}, "IS": {26, 0, 4},
+	"IT": {27, 1, 5}, "LI": {21, 0, 5}, "LT": {20, 0, 5}, "LU": {20, 0, 3},
+	"LV": {21, 0, 4}, "MC": {27, 0, 5}, "MT": {31, 0, 4}, "NL": {18, 0, 4},
+	"NO": {15, 0, 4}, "PL": {28, 0, 3}, "PT": {25, 0, 4}, "RO": {24, 0, 4},
+	"SE": {24, 0, 3}, "SI": {19, 0, 5}, "SK": {24, 0, 4}, "SM": {27, 1, 5},
+	"VA": {22, 0, 3},
+}
+
+// ParseIBAN accepts an IBAN in electronic or print form, in any case, and
+// checks its length and mod-97 check digits.
+func ParseIBAN(s string) (IBAN, error) {
+	value := strings.ToUpper(strings.Join(strings.Fields(s), ""))
+	if len(value) < 15 || len(value) > 34 {
+		return "", fmt.Errorf("%w: %q has %d characters", ErrInvalidIBAN, s, len(value))
+	}
+	for i, r := range value {
+		switch {
+		case i < 2 && (r < 'A' || r > 'Z'),
+			i >= 2 && i < 4 && (r < '0' || r > '9'),
+			(r < '0' || r > '9') && (r < 'A' || r > 'Z'):
+			return "", fmt.Errorf("%w: %q is not a country code, check digits and letters or digits", ErrInvalidIBAN, s)
+		}
+	}
+	if format, ok := ibanFormats[value[:2]]; ok && len(value) != format.length {
+		return "", fmt.Errorf("%w: %s IBANs have %d characters, not %d", ErrInvalidIBAN, value[:2], format.length, len(value))
+	}
+	if ibanRemainder(value[4:]+value[:4]) != 1 {
+		return "", fmt.Errorf("%w: %q has wrong check digits", ErrInvalidIBAN, s)
+	}
+	return IBAN(value), nil
+}
+
+// NewIBAN puts the check digits in front of the BBAN of a country.
+func NewIBAN(country, bban string) (IBAN, error) {
+	country, bban = strings.ToUpper(country), strings.ToUpper(bban)
+	check := 98 - ibanRemainder(bban+country+"00")
+	return ParseIBAN(fmt.Sprintf("%s%02d%s", country, check, bban))
+}
+
+// ibanRemainder is the value mod 97 of the letters and digits with letters
+// counting as 10 to 35.
+func ibanRemainder(s string) int {
+	remainder := 0
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			remainder = (remainder*10 + int(r-'0')) % 97
+		case r >= 'A' && r <= 'Z':
+			remainder = (remainder*100 + int(r-'A') + 10) % 97
+		}
+	}
+	return remainder
+}
+
+func (iban IBAN) String() string {
+	return string(iban)
+}
+
+// PrintFormat groups the IBAN in blocks of four as it is printed on paper.
+func (iban IBAN) PrintFormat() string {
+	var b strings.Builder
+	for i, r := range string(iban) {
+		if i > 0 && i%4 == 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (iban IBAN) Country() string {
+	if len(iban) < 2 {
+		return ""
+	}
+	return string(iban[:2])
+}
+
+func (iban IBAN) CheckDigits() string {
+	if len(iban) < 4 {
+		return ""
+	}
+	return string(iban[2:4])
+}
+
+// BBAN is the national account number, everything after the check digits.
+func (iban IBAN) BBAN() string {
+	if len(iban) < 4 {
+		return ""
+	}
+	return string(iban[4:])
+}
+
+// BankCode is the part of the BBAN naming the bank, empty for countries
+// whose format is not known.
+func (iban IBAN) BankCode() string {
+	format, ok := ibanFormats[iban.Country()]
+	bban := iban.BBAN()
+	if !ok || len(bban) < format.bankOffset+format.bankLength {
+		return ""
+	}
+	return bban[format.bankOffset : format.bankOffset+format.bankLength]
+}
+
+// BIC is the business identifier code of the bank holding the account, as
+// far as it is this bank or one registered with RegisterBIC.
+func (iban IBAN) BIC() (string, bool) {
+	if issuer.Issues(iban) {
+		return issuer.BIC, true
+	}
+
+	bics.mu.RLock()
+	defer bics.mu.RUnlock()
+	bic, ok := bics.byBank[iban.Country()+iban.BankCode()]
+	return bic, ok && iban.BankCode() != ""
+}
+
+func (iban *IBAN) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*iban = ""
+		return nil
+	}
+	parsed, err := ParseIBAN(string(text))
+	if err != nil {
+		return err
+	}
+	*iban = parsed
+	return nil
+}
+
+// IBANInfo is an IBAN taken apart. BIC is empty for banks without a
+// registered BIC.
+type IBANInfo struct {
+	IBAN        IBAN
+	PrintFormat string
+	Country     string
+	CheckDigits string
+	BBAN        string
+	BankCode    string `json:",omitempty"`
+	BIC         string `json:",omitempty"`
+}
+
+func (iban IBAN) Info() IBANInfo {
+	bic, _ := iban.BIC()
+	return IBANInfo{
+		IBAN:        iban,
+		PrintFormat: iban.PrintFormat(),
+		Country:     iban.Country(),
+		CheckDigits: iban.CheckDigits(),
+		BBAN:        iban.BBAN(),
+		BankCode:    iban.BankCode(),
+		BIC:         bic,
+	}
+}
+
+// ParseBIC checks the form of a BIC: four letters for the bank, the
+// country, two letters or digits for the location and optionally three
+// for the branch.
+func ParseBIC(s string) (string, error) {
+	bic := strings.ToUpper(strings.TrimSpace(s))
+	if len(bic) != 8 && len(bic) != 11 {
+		return "", fmt.Errorf("%w: %q must have 8 or 11 characters", ErrInvalidBIC, s)
+	}
+	for i, r := range bic {
+		letter, digit := r >= 'A' && r <= 'Z', r >= '0' && r <= '9'
+		if i < 6 && !letter || i >= 6 && !letter && !digit {
+			return "", fmt.Errorf("%w: %q", ErrInvalidBIC, s)
+		}
+	}
+	return bic, nil
+}
+
+var bics = struct {
+	mu     sync.RWMutex
+	byBank map[string]string
+}{byBank: map[string]string{}}
+
+// RegisterBIC associates the BIC with the IBANs of a bank, given by
+// country and bank code.
+func RegisterBIC(country, bankCode, bic string) error {
+	bic, err := ParseBIC(bic)
+	if err != nil {
+		return err
+	}
+	country, bankCode = strings.ToUpper(country), strings.ToUpper(bankCode)
+	if bic[4:6] != country {
+		return fmt.Errorf("%w: %s is not a BIC in %s", ErrInvalidBIC, bic, country)
+	}
+
+	bics.mu.Lock()
+	defer bics.mu.Unlock()
+	bics.byBank[country+bankCode] = bic
+	return nil
+}
+
+// IBANIssuer is what goes into the IBANs of new accounts: the country, the
+// bank code in front of the account number and the BIC of the bank.
+type IBANIssuer struct {
+	Country  string
+	BankCode string
+	BIC      string
+}
+
+var DefaultIBANIssuer = IBANIssuer{Country: "DE", BankCode: "10020030", BIC: "CODEDEFFXXX"}
+
+var issuer = DefaultIBANIssuer
+
+// SetIBANIssuer checks the issuer against the IBAN format of its country.
+func SetIBANIssuer(i IBANIssuer) error {
+	i.Country, i.BankCode = strings.ToUpper(i.Country), strings.ToUpper(i.BankCode)
+	format, ok := ibanFormats[i.Country]
+	if !ok || format.bankOffset != 0 {
+		return fmt.Errorf("%w: cannot issue IBANs in %q", ErrInvalidIBAN, i.Country)
+	}
+	if len(i.BankCode) != format.bankLength {
+		return fmt.Errorf("%w: bank codes in %s have %d characters", ErrInvalidIBAN, i.Country, format.bankLength)
+	}
+	bic, err := ParseBIC(i.BIC)
+	if err != nil {
+		return err
+	}
+	if bic[4:6] != i.Country {
+		return fmt.Errorf("%w: %s is not a BIC in %s", ErrInvalidBIC, bic, i.Country)
+	}
+	i.BIC = bic
+	issuer = i
+	return nil
+}
+
+func Issuer() IBANIssuer {
+	return issuer
+}
+
+// Issues reports whether the IBAN belongs to an account of this bank.
+func (i IBANIssuer) Issues(iban IBAN) bool {
+	return iban.Country() == i.Country && iban.BankCode() == i.BankCode
+}
+
+// newIBAN makes an IBAN for the account. Numeric account ids that fit become
+// the account number, other ids get a random one. IBANs in taken are not
+// used again.
+func (i IBANIssuer) newIBAN(accountID string, taken map[IBAN]bool) (IBAN, error) {
+	digits := ibanFormats[i.Country].length - 4 - len(i.BankCode)
+	if isDigits(accountID) && len(strings.TrimLeft(accountID, "0")) <= digits {
+		iban, err := NewIBAN(i.Country, i.BankCode+zeroPad(strings.TrimLeft(accountID, "0"), digits))
+		if err == nil && !taken[iban] {
+			return iban, nil
+		}
+	}
+
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil)
+	for range 10 {
+		n, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return "", err
+		}
+		iban, err := NewIBAN(i.Country, i.BankCode+zeroPad(n.String(), digits))
+		if err != nil {
+			return "", err
+		}
+		if !taken[iban] {
+			return iban, nil
+		}
+	}
+	return "", fmt.Errorf("%w: no free account number", ErrInvalidIBAN)
+}
+
+func zeroPad(s string, n int) string {
+	return strings.Repeat("0", max(n-len(s), 0)) + s
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// takenIBANs are the IBANs of the accounts in the store.
+func takenIBANs() (map[IBAN]bool, error) {
+	accounts, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	taken := map[IBAN]bool{}
+	for _, account := range accounts {
+		if account.IBAN != "" {
+			taken[account.IBAN] = true
+		}
+	}
+	return taken, nil
+}
+
+// findByIBAN returns the account of this bank with the IBAN.
+func findByIBAN(iban IBAN) (*Account, error) {
+	accounts, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range accounts {
+		if accounts[i].IBAN == iban {
+			return store.Get(accounts[i].Id)
+		}
+	}
+	return nil, fmt.Errorf("%w: IBAN %s", ErrAccountNotFound, iban)
+}
+
+// AssignIBANs gives every account opened before accounts had IBANs one.
+func AssignIBANs() error {
+	taken, err := takenIBANs()
+	if err != nil {
+		return err
+	}
+	accounts, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	for _, listed := range accounts {
+		if listed.IBAN != "" {
+			continue
+		}
+		account, err := store.Get(listed.Id)
+		if err != nil {
+			return err
+		}
+		err = update(func() error {
+			if account.IBAN != "" {
+				return nil
+			}
+			iban, err := issuer.newIBAN(account.Id, taken)
+			if err != nil {
+				return err
+			}
+			if err := account.record(Event{Type: IBANAssigned, IBAN: iban}); err != nil {
+				return err
+			}
+			taken[iban] = true
+			return account.save()
+		}, account)
+		if err != nil {
+			return fmt.Errorf("assigning an IBAN to %s: %w", account.Id, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package bank
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func useIBANIssuer(t *testing.T, i IBANIssuer) {
+	t.Helper()
+
+	original := issuer
+	if err := SetIBANIssuer(i); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { issuer = original })
+}
+
+func TestParseIBAN(t *testing.T) {
+	valid := map[string]IBAN{
+		"DE89370400440532013000":            "DE89370400440532013000",
+		"de89 3704 0044 0532 0130 00":       "DE89370400440532013000",
+		"GB82 WEST 1234 5698 7654 32":       "GB82WEST12345698765432",
+		"NL91ABNA0417164300":                "NL91ABNA0417164300",
+		"FR14 2004 1010 0505 0001 3M02 606": "FR1420041010050500013M02606",
+		"IT60X0542811101000000123456":       "IT60X0542811101000000123456",
+		"BE68539007547034":                  "BE68539007547034",
+		"CH9300762011623852957":             "CH9300762011623852957",
+		// Countries outside the table are checked for the checksum only.
+		"BR1800360305000010009795493C1": "BR1800360305000010009795493C1",
+	}
+	for input, want := range valid {
+		got, err := ParseIBAN(input)
+		if err != nil || got != want {
+			t.Errorf("ParseIBAN(%q) = %q, %v, want %q", input, got, err, want)
+		}
+	}
+
+	for _, input := range []string{
+		"",
+		"DE88370400440532013000", // wrong check digits
+		"DE8937040044053201300",  // too short for DE
+		"DE89-3704-0044-0532-0130-00",
+		"1E89370400440532013000",
+		"DEXX370400440532013000",
+		"GB82WEST1234569876543ü",
+	} {
+		if _, err := ParseIBAN(input); !errors.Is(err, ErrInvalidIBAN) {
+			t.Errorf("ParseIBAN(%q): got %v, want ErrInvalidIBAN", input, err)
+		}
+	}
+}
+
+func TestIBANParts(t *testing.T) {
+	iban, err := NewIBAN("de", "370400440532013000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if iban != "DE89370400440532013000" {
+		t.Errorf("NewIBAN = %s, want DE89370400440532013000", iban)
+	}
+	if got := iban.PrintFormat(); got != "DE89 3704 0044 0532 0130 00" {
+		t.Errorf("PrintFormat = %q", got)
+	}
+	if iban.Country() != "DE" || iban.CheckDigits() != "89" || iban.BBAN() != "370400440532013000" || iban.BankCode() != "37040044" {
+		t.Errorf("parts = %+v", iban.Info())
+	}
+	if got := IBAN("IT60X0542811101000000123456").BankCode(); got != "05428" {
+		t.Errorf("Italian bank code = %q, want 05428", got)
+	}
+
+	if _, ok := iban.BIC(); ok {
+		t.Error("BIC of an unregistered bank")
+	}
+	if err := RegisterBIC("DE", "37040044", "cobadeffxxx"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		bics.mu.Lock()
+		delete(bics.byBank, "DE37040044")
+		bics.mu.Unlock()
+	})
+	if bic, ok := iban.BIC(); !ok || bic != "COBADEFFXXX" {
+		t.Errorf("BIC = %q, %v", bic, ok)
+	}
+	for _, bic := range []string{"COBADEF", "COBA1EFFXXX", "COBADEFF-XX"} {
+		if _, err := ParseBIC(bic); !errors.Is(err, ErrInvalidBIC) {
+			t.Errorf("ParseBIC(%q): got %v", bic, err)
+		}
+	}
+	if err := RegisterBIC("NL", "ABNA", "COBADEFF"); !errors.Is(err, ErrInvalidBIC) {
+		t.Errorf("BIC of another country: got %v", err)
+	}
+}
+
+func TestSetIBANIssuer(t *testing.T) {
+	useIBANIssuer(t, IBANIssuer{Country: "at", BankCode: "19043", BIC: "CODEATWW"})
+	if got := Issuer(); got.Country != "AT" || got.BIC != "CODEATWW" {
+		t.Errorf("issuer = %+v", got)
+	}
+
+	for _, i := range []IBANIssuer{
+		{Country: "XX", BankCode: "1234", BIC: "CODEXXWW"},
+		{Country: "IT", BankCode: "05428", BIC: "CODEITMM"},
+		{Country: "DE", BankCode: "1234", BIC: "CODEDEFF"},
+		{Country: "DE", BankCode: "12345678", BIC: "CODEATWW"},
+	} {
+		if err := SetIBANIssuer(i); err == nil {
+			t.Errorf("SetIBANIssuer(%+v) succeeded", i)
+		}
+	}
+	if Issuer().Country != "AT" {
+		t.Error("a rejected issuer replaced the issuer")
+	}
+}
+
+func TestAccountIBANs(t *testing.T) {
+	useStore(t)
+	useBooks(t)
+	useIBANIssuer(t, DefaultIBANIssuer)
+
+	numbered := &Account{Id: "002", Name: "Alice", Balance: eur(100), AccountType: Giro}
+	named := &Account{Id: "bob", Name: "Bob", AccountType: Savings}
+	openTestAccounts(t, numbered, named)
+
+	if want, _ := NewIBAN("DE", "100200300000000002"); numbered.IBAN != want {
+		t.Errorf("IBAN of account 002 = %s, want %s", numbered.IBAN, want)
+	}
+	if _, err := ParseIBAN(named.IBAN.String()); err != nil || !Issuer().Issues(named.IBAN) {
+		t.Errorf("IBAN of account bob = %q: %v", named.IBAN, err)
+	}
+	if bic, _ := named.IBAN.BIC(); bic != DefaultIBANIssuer.BIC {
+		t.Errorf("BIC = %q, want the issuer's", bic)
+	}
+
+	if err := OpenAccount(&Account{Id: "carol", Name: "Carol", IBAN: numbered.IBAN}); !errors.Is(err, ErrAccountExists) {
+		t.Errorf("opening with a taken IBAN: got %v", err)
+	}
+
+	if err := numbered.Transfer(eur(30), named.IBAN.PrintFormat(), "rent"); err != nil {
+		t.Fatal(err)
+	}
+	if got := balanceOf(t, "bob"); got != eur(30) {
+		t.Errorf("bob's balance = %v, want 30", got)
+	}
+	other, _ := NewIBAN("DE", "100200309999999999")
+	if err := numbered.Transfer(eur(1), other.String()); !errors.Is(err, ErrRecipientNotFound) {
+		t.Errorf("transfer to an unknown IBAN: got %v", err)
+	}
+
+	// An account named after someone else's IBAN must not catch their
+	// transfers.
+	for _, id := range []string{named.IBAN.String(), named.IBAN.PrintFormat()} {
+		if err := OpenAccount(&Account{Id: id, Name: "Mallory", AccountType: Giro}); !errors.Is(err, ErrInvalidAccountID) {
+			t.Errorf("opening account %q: got %v, want %v", id, err, ErrInvalidAccountID)
+		}
+	}
+	if err := Store().Save(&Account{Id: named.IBAN.String(), Name: "Mallory", AccountType: Giro}); err != nil {
+		t.Fatal(err)
+	}
+	if err := numbered.Transfer(eur(20), named.IBAN.String()); err != nil {
+		t.Fatal(err)
+	}
+	if got := balanceOf(t, "bob"); got != eur(50) {
+		t.Errorf("bob's balance = %v, want 50", got)
+	}
+	if got := balanceOf(t, named.IBAN.String()); !got.IsZero() {
+		t.Errorf("balance of the account with bob's IBAN as id = %v, want 0", got)
+	}
+}
+
+// TestIBANSurvivesRestart books through a handle that only knows the id, as
+// the server's default account does after a restart.
+func TestIBANSurvivesRestart(t *testing.T) {
+	useStore(t)
+	useBooks(t)
+	useIBANIssuer(t, DefaultIBANIssuer)
+	usePayments(t, "")
+	path := filepath.Join(t.TempDir(), "acc_db.json")
+
+	s, err := NewStore("json", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetStore(s)
+	opened := &Account{Id: "002", Name: "Alice", Balance: eur(100), AccountType: Giro}
+	openTestAccounts(t, opened)
+
+	restarted, err := NewStore("json", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetStore(restarted)
+	handle := &Account{Id: "002", Name: "Alice", AccountType: Giro}
+	if err := handle.Deposit(eur(10)); err != nil {
+		t.Fatal(err)
+	}
+	if handle.IBAN != opened.IBAN {
+		t.Errorf("IBAN of the handle = %q, want %q", handle.IBAN, opened.IBAN)
+	}
+
+	saved, err := Store().Get("002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.IBAN != opened.IBAN || saved.Balance != eur(110) {
+		t.Errorf("saved account has IBAN %q and balance %v, want %q and 110", saved.IBAN, saved.Balance, opened.IBAN)
+	}
+	if _, err := handle.BookSEPATransfer(eur(5), Party{Name: "Bob", IBAN: "NL91ABNA0417164300"}); err != nil {
+		t.Errorf("SEPA transfer after the restart: %v", err)
+	}
+}
+
+func TestAssignIBANs(t *testing.T) {
+	useStore(t, Account{Id: "003", Name: "Old"}, Account{Id: "new", Name: "New", IBAN: "DE89370400440532013000"})
+	useIBANIssuer(t, DefaultIBANIssuer)
+
+	if err := AssignIBANs(); err != nil {
+		t.Fatal(err)
+	}
+	old, err := Store().Get("003")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := NewIBAN("DE", "100200300000000003"); old.IBAN != want {
+		t.Errorf("IBAN = %s, want %s", old.IBAN, want)
+	}
+	if kept, _ := Store().Get("new"); kept.IBAN != "DE89370400440532013000" {
+		t.Errorf("existing IBAN replaced by %s", kept.IBAN)
+	}
+}
+
+func TestIBANEvents(t *testing.T) {
+	dir := t.TempDir()
+	useEventStore(t, dir)
+	useBooks(t)
+	useIBANIssuer(t, DefaultIBANIssuer)
+
+	opened := &Account{Id: "1", Name: "Alice", AccountType: Giro}
+	if err := OpenAccount(opened); err != nil {
+		t.Fatal(err)
+	}
+	legacy := &Account{Id: "2", Name: "Bob", AccountType: Savings}
+	if err := legacy.save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssignIBANs(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewEventStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[string]string{"1": "100200300000000001", "2": "100200300000000002"} {
+		account, err := reopened.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if account.IBAN.BBAN() != want {
+			t.Errorf("account %s replayed with IBAN %q, want BBAN %s", id, account.IBAN, want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package bank
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+const DefaultIdempotencyRetention = 24 * time.Hour
+
+var (
+	ErrIdempotencyKeyReused   = errors.New("idempotency key was already used with a different request")
+	ErrIdempotencyKeyInFlight = errors.New("a request with this idempotency key is still being processed")
+)
+
+// IdempotencyRecord is the stored outcome of a request made with an
+// idempotency key. Fingerprint identifies the request payload so that reuse of
+// the key for a different request can be told apart from a retry.
+type IdempotencyRecord struct {
+	Key         string
+	Fingerprint string
+	Status      int
+	Header      map[string][]string `json:",omitempty"`
+	Body        []byte
+	Created     time.Time
+}
+
+type IdempotencyStore struct {
+	mu        sync.Mutex
+	path      string
+	retention time.Duration
+	records   map[string]IdempotencyRecord
+	inFlight  map[string]string
+}
+
+var idempotency = NewMemoryIdempotencyStore(DefaultIdempotencyRetention)
+
+func SetIdempotencyStore(s *IdempotencyStore) {
+	idempotency = s
+}
+
+func Idempotency() *IdempotencyStore {
+	return idempotency
+}
+
+func NewMemoryIdempotencyStore(retention time.Duration) *IdempotencyStore {
+	return &IdempotencyStore{
+		retention: retention,
+		records:   map[string]IdempotencyRecord{},
+		inFlight:  map[string]string{},
+	}
+}
+
+func NewIdempotencyStore(path string, retention time.Duration) (*IdempotencyStore, error) {
+	s := NewMemoryIdempotencyStore(retention)
+	s.path = path
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+
+	var records []IdempotencyRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		s.records[record.Key] = record
+	}
+	s.prune(time.Now())
+	return s, nil
+}
+
+// Begin looks up the key. It returns the stored record when the request was
+// already answered, and otherwise reserves the key until Complete or Release
+// is called, returning a nil record.
+func (s *IdempotencyStore) Begin(key, fingerprint string) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(time.Now())
+
+	if record, ok := s.records[key]; ok {
+		if record.Fingerprint != fingerprint {
+			return nil, ErrIdempotencyKeyReused
+		}
+		return &record, nil
+	}
+
+	if inFlight, ok := s.inFlight[key]; ok {
+		if inFlight != fingerprint {
+			return nil, ErrIdempotencyKeyReused
+		}
+		return nil, ErrIdempotencyKeyInFlight
+	}
+
+	s.inFlight[key] = fingerprint
+	return nil, nil
+}
+
+// Complete stores the response of a reserved key and gives up the
+// reservation. A record that cannot be saved is not kept.
+func (s *IdempotencyStore) Complete(record IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.inFlight, record.Key)
+	if record.Created.IsZero() {
+		record.Created = time.Now()
+	}
+	s.records[record.Key] = record
+	if err := s.persist(); err != nil {
+		delete(s.records, record.Key)
+		return err
+	}
+	return nil
+}
+
+// Release gives up a reservation without storing a response, so the request
+// can be retried with the same key.
+func (s *IdempotencyStore) Release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.inFlight, key)
+}
+
+func (s *IdempotencyStore) prune(now time.Time) {
+	if s.retention <= 0 {
+		return
+	}
+	for key, record := range s.records {
+		if now.Sub(record.Created) > s.retention {
+			delete(s.records, key)
+		}
+	}
+}
+
+func (s *IdempotencyStore) persist() error {
+	if s.path == "" {
+		return nil
+	}
+
+	records := make([]IdempotencyRecord, 0, len(s.records))
+	for _, record := range s.records {
+		records = append(records, record)
+	}
+
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data, 0600)
+}
@@ -0,0 +1,72 @@
+package bank
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idempotency.json")
+	s, err := NewIdempotencyStore(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if record, err := s.Begin("k1", "a"); record != nil || err != nil {
+		t.Fatalf("first Begin = %v, %v, want nil, nil", record, err)
+	}
+	if _, err := s.Begin("k1", "a"); !errors.Is(err, ErrIdempotencyKeyInFlight) {
+		t.Errorf("concurrent retry: got %v, want %v", err, ErrIdempotencyKeyInFlight)
+	}
+	if _, err := s.Begin("k1", "b"); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Errorf("concurrent reuse: got %v, want %v", err, ErrIdempotencyKeyReused)
+	}
+
+	if err := s.Complete(IdempotencyRecord{Key: "k1", Fingerprint: "a", Status: 201, Body: []byte("done")}); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewIdempotencyStore(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := reloaded.Begin("k1", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record == nil || record.Status != 201 || string(record.Body) != "done" {
+		t.Errorf("replayed record = %+v, want status 201 and body done", record)
+	}
+	if _, err := reloaded.Begin("k1", "b"); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Errorf("reuse after completion: got %v, want %v", err, ErrIdempotencyKeyReused)
+	}
+
+	if _, err := reloaded.Begin("k2", "a"); err != nil {
+		t.Fatal(err)
+	}
+	reloaded.Release("k2")
+	if record, err := reloaded.Begin("k2", "b"); record != nil || err != nil {
+		t.Errorf("Begin after Release = %v, %v, want nil, nil", record, err)
+	}
+}
+
+func TestIdempotencyRetention(t *testing.T) {
+	s := NewMemoryIdempotencyStore(time.Hour)
+
+	old := IdempotencyRecord{Key: "old", Fingerprint: "a", Status: 200, Created: time.Now().Add(-2 * time.Hour)}
+	if err := s.Complete(old); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Complete(IdempotencyRecord{Key: "new", Fingerprint: "a", Status: 200}); err != nil {
+		t.Fatal(err)
+	}
+
+	if record, err := s.Begin("old", "b"); record != nil || err != nil {
+		t.Errorf("expired key: got %v, %v, want it to be free again", record, err)
+	}
+	if record, _ := s.Begin("new", "a"); record == nil {
+		t.Error("key within the retention window was dropped")
+	}
+}
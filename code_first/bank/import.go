@@ -0,0 +1,292 @@
+package bank
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrInvalidImport = errors.New("invalid statement import")
+
+// ImportFormat names a statement file format ImportStatement reads.
+type ImportFormat string
+
+const (
+	ImportCSV     ImportFormat = "csv"
+	ImportMT940   ImportFormat = "mt940"
+	ImportCAMT053 ImportFormat = "camt.053"
+)
+
+// ParseImportFormat accepts the format names case-insensitively, camt053
+// for camt.053 and sta, the usual MT940 file extension.
+func ParseImportFormat(s string) (ImportFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "csv":
+		return ImportCSV, nil
+	case "mt940", "sta":
+		return ImportMT940, nil
+	case "camt.053", "camt053":
+		return ImportCAMT053, nil
+	default:
+		return "", fmt.Errorf("%w: unknown format %q", ErrInvalidImport, s)
+	}
+}
+
+// ImportedLine is a booked line of another bank's statement. Amount is
+// signed, negative for money that left the account. Type is kept where the
+// file has one of this bank's transaction types, otherwise credits are
+// imported as deposits and debits as withdrawals.
+type ImportedLine struct {
+	Line         int
+	Time         time.Time
+	Amount       Money
+	Type         TransactionType `json:",omitempty"`
+	Counterparty string          `json:",omitempty"`
+	Reference    string          `json:",omitempty"`
+}
+
+// LineError reports why a line could not be imported. Line is the line of
+// a CSV or MT940 file and the entry of a camt.053 file.
+type LineError struct {
+	Line    int
+	Message string
+}
+
+func (e LineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+func lineError(line int, format string, args ...any) LineError {
+	return LineError{Line: line, Message: fmt.Sprintf(format, args...)}
+}
+
+// ImportOptions selects the format of the file, the column mapping for CSV
+// files and whether to only report what would be imported.
+type ImportOptions struct {
+	Format ImportFormat
+	CSV    CSVMapping
+	DryRun bool
+}
+
+// ImportResult lists the transactions booked, or that would be booked in a
+// dry run, the lines skipped as duplicates and the lines that failed.
+type ImportResult struct {
+	DryRun     bool
+	Imported   []Transactions
+	Duplicates []ImportedLine
+	Errors     []LineError
+}
+
+// ParseStatementFile reads the booked lines of a statement file. Errors
+// in single lines are returned with the lines that could be read, an error
+// is only returned if the file cannot be read at all.
+func ParseStatementFile(r io.Reader, opts ImportOptions) ([]ImportedLine, []LineError, error) {
+	switch opts.Format {
+	case ImportCSV:
+		return ParseCSVStatement(r, opts.CSV)
+	case ImportMT940:
+		return ParseMT940(r)
+	case ImportCAMT053:
+		return ParseCAMT053(r)
+	default:
+		return nil, nil, fmt.Errorf("%w: unknown format %q", ErrInvalidImport, opts.Format)
+	}
+}
+
+// ImportStatement loads the history in a statement file into the account.
+// Lines that match a transaction already on the account by day, amount and
+// reference are skipped, so a file can be imported again safely. Lines
+// that fail validation are reported and the others are still imported.
+func (account *Account) ImportStatement(r io.Reader, opts ImportOptions) (*ImportResult, error) {
+	if opts.CSV.DefaultCurrency == "" {
+		snapshot, err := account.Snapshot()
+		if err != nil {
+			return nil, err
+		}
+		opts.CSV.DefaultCurrency = snapshot.BaseCurrency()
+	}
+	lines, lineErrors, err := ParseStatementFile(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	return account.ImportLines(lines, lineErrors, opts.DryRun)
+}
+
+// ImportLines books lines onto the account as transactions at their
+// original time, against the opening balance account like the balance of a
+// migrated account. Imports are history, so they neither pay fees nor
+// respect the overdraft limit.
+func (account *Account) ImportLines(lines []ImportedLine, lineErrors []LineError, dryRun bool) (*ImportResult, error) {
+	result := &ImportResult{DryRun: dryRun, Errors: slices.Clone(lineErrors)}
+
+	if dryRun {
+		snapshot, err := account.Snapshot()
+		if err != nil {
+			return nil, err
+		}
+		if err := snapshot.checkActive(); err != nil {
+			return nil, err
+		}
+		for _, line := range snapshot.sortImport(lines, result) {
+			result.Imported = append(result.Imported, line.transaction(snapshot.BaseCurrency()))
+		}
+		return result, nil
+	}
+
+	err := update(func() error {
+		if err := account.checkActive(); err != nil {
+			return err
+		}
+
+		var entries []JournalEntry
+		for _, line := range account.sortImport(lines, result) {
+			txn := line.transaction(account.BaseCurrency())
+			txn.Id = newTransactionID()
+			credit := line.Amount.IsPositive()
+			err := account.record(Event{
+				Type:          importEventType(line.Type, credit),
+				Time:          txn.Time,
+				Amount:        txn.Amount,
+				Counterparty:  txn.Counterparty,
+				Reference:     txn.Reference,
+				TransactionID: txn.Id,
+			})
+			if err != nil {
+				return err
+			}
+
+			side := Debit
+			if credit {
+				side = Credit
+			}
+			entry := NewEntry("import "+account.Id,
+				Leg{Account: OpeningBalanceAccount, Side: opposite(side), Amount: txn.Amount},
+				Leg{Account: account.Id, Side: side, Amount: txn.Amount},
+			)
+			entry.TransactionID = txn.Id
+			entries = append(entries, entry)
+			result.Imported = append(result.Imported, account.Transactions[len(account.Transactions)-1])
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		return postAllAndSave(entries, account)
+	}, account)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// sortImport validates the lines and drops duplicates, recording both in
+// result, and returns the lines left to import in the order they were
+// booked.
+func (account *Account) sortImport(lines []ImportedLine, result *ImportResult) []ImportedLine {
+	existing := account.importKeys()
+	now := time.Now()
+
+	var valid []ImportedLine
+	for _, line := range lines {
+		line.Amount = line.Amount.WithCurrency(account.BaseCurrency())
+		switch {
+		case line.Time.IsZero():
+			result.Errors = append(result.Errors, lineError(line.Line, "no booking date"))
+			continue
+		case line.Time.After(now):
+			result.Errors = append(result.Errors, lineError(line.Line, "booked in the future on %s", line.Time.Format(time.DateOnly)))
+			continue
+		case line.Amount.IsZero():
+			result.Errors = append(result.Errors, lineError(line.Line, "amount is zero"))
+			continue
+		}
+		if err := checkCurrency(line.Amount.Currency); err != nil {
+			result.Errors = append(result.Errors, lineError(line.Line, "%v", err))
+			continue
+		}
+
+		// Each transaction on the account absorbs one matching line, so
+		// identical lines within a file are kept unless they were imported
+		// before.
+		key := line.key()
+		if existing[key] > 0 {
+			existing[key]--
+			result.Duplicates = append(result.Duplicates, line)
+			continue
+		}
+		valid = append(valid, line)
+	}
+	slices.SortFunc(result.Errors, func(a, b LineError) int { return a.Line - b.Line })
+	slices.SortStableFunc(valid, func(a, b ImportedLine) int { return a.Time.Compare(b.Time) })
+	return valid
+}
+
+// importKey is what makes two lines the same booking: the day, the signed
+// amount and the reference ignoring case and spacing.
+type importKey struct {
+	day       string
+	amount    Money
+	reference string
+}
+
+func (line ImportedLine) key() importKey {
+	return importKey{
+		day:       line.Time.UTC().Format(time.DateOnly),
+		amount:    line.Amount,
+		reference: strings.ToLower(strings.Join(strings.Fields(line.Reference), " ")),
+	}
+}
+
+// importKeys counts the transactions on the account by importKey.
+func (account *Account) importKeys() map[importKey]int {
+	deltas := map[Currency]map[string]int64{}
+	keys := map[importKey]int{}
+	for _, txn := range account.Transactions {
+		currency := txn.Amount.WithCurrency(account.BaseCurrency()).Currency
+		if deltas[currency] == nil {
+			deltas[currency] = books.transactionDeltas(account.Id, currency)
+		}
+		line := ImportedLine{Time: txn.Time, Amount: signedAmount(txn, deltas[currency], account.BaseCurrency()), Reference: txn.Reference}
+		keys[line.key()]++
+	}
+	return keys
+}
+
+// transaction is the line as it is booked, with an unsigned amount.
+func (line ImportedLine) transaction(base Currency) Transactions {
+	amount := line.Amount.WithCurrency(base)
+	credit := amount.IsPositive()
+	if !credit {
+		amount = amount.Neg()
+	}
+	_, tt, _ := eventEffect(Event{Type: importEventType(line.Type, credit)})
+	return Transactions{
+		Time:         line.Time,
+		Amount:       amount,
+		Type:         tt,
+		Counterparty: line.Counterparty,
+		Reference:    line.Reference,
+		Status:       StatusBooked,
+	}
+}
+
+// importEventType books transfers, fees and interest as such when the
+// direction fits and everything else as a deposit or withdrawal.
+func importEventType(tt TransactionType, credit bool) EventType {
+	switch {
+	case tt == Transfer && credit:
+		return TransferredIn
+	case tt == Transfer:
+		return TransferredOut
+	case tt == Fee && !credit:
+		return FeeCharged
+	case tt == Interest && credit:
+		return InterestPaid
+	case credit:
+		return Deposited
+	default:
+		return Withdrawn
+	}
+}
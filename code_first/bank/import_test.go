@@ -0,0 +1,297 @@
+package bank
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseCSVStatement(t *testing.T) {
+	mapping, err := ParseCSVMapping(url.Values{
+		"date_column":         {"Buchungstag"},
+		"debit_column":        {"Soll"},
+		"credit_column":       {"Haben"},
+		"counterparty_column": {"Empfänger"},
+		"reference_column":    {"Verwendungszweck"},
+		"date_format":         {"DD.MM.YYYY"},
+		"delimiter":           {";"},
+		"decimal":             {","},
+		"currency":            {"eur"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := "\ufeffBuchungstag;Empfänger;Verwendungszweck;Soll;Haben\n" +
+		"02.01.2025;Landlord;Rent January;1.200,00;\n" +
+		"15.01.2025;Employer;Salary;;2.500,50\n" +
+		"16.01.2025;Shop;;-19,99;\n" +
+		"2025-01-17;Shop;;5,00;\n" +
+		"18.01.2025;Shop;;5,x;\n"
+	lines, lineErrors, err := ParseCSVStatement(strings.NewReader(file), mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ImportedLine{
+		{Line: 2, Time: date(2025, 1, 2, 0, 0), Amount: NewMoney(-120000, EUR), Counterparty: "Landlord", Reference: "Rent January"},
+		{Line: 3, Time: date(2025, 1, 15, 0, 0), Amount: NewMoney(250050, EUR), Counterparty: "Employer", Reference: "Salary"},
+		{Line: 4, Time: date(2025, 1, 16, 0, 0), Amount: NewMoney(-1999, EUR), Counterparty: "Shop"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d: %+v", len(lines), len(want), lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d = %+v, want %+v", i, lines[i], want[i])
+		}
+	}
+	if len(lineErrors) != 2 || lineErrors[0].Line != 5 || lineErrors[1].Line != 6 {
+		t.Errorf("errors = %v, want the date on line 5 and the amount on line 6", lineErrors)
+	}
+
+	if _, _, err := ParseCSVStatement(strings.NewReader("Datum,Betrag\n"), DefaultCSVMapping); !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("file without the mapped columns: got %v, want %v", err, ErrInvalidImport)
+	}
+	for _, values := range []url.Values{
+		{"amount_column": {"Betrag"}},
+		{"date_column": {"Datum"}},
+		{"delimiter": {";;"}},
+		{"decimal": {"'"}},
+	} {
+		if _, err := ParseCSVMapping(values); !errors.Is(err, ErrInvalidImport) {
+			t.Errorf("mapping %v: got %v, want %v", values, err, ErrInvalidImport)
+		}
+	}
+}
+
+const mt940File = `{1:F01BANKDEFFAXXX0000000000}{2:O9400000000000BANKDEFFXXXX00000000000000000000N}{4:
+:20:STARTUMSE
+:25:10020030/1234567
+:28C:00001/001
+:60F:C241230EUR1000,00
+:61:2412301230D1200,NTRFNONREF//B4L12300001
+:86:177?00SEPA-UEBERWEISUNG?20EREF+NOTPROVIDED?21SVWZ+Rent?22 January?32Land
+?33lord GmbH
+:61:2412310102CR2500,50NTRFSALARY
+:86:Salary December
+ thank you
+:61:250102RD19,99NMSCNONREF
+:61:250103X5,00NMSCNONREF
+:62F:C250103EUR2300,51
+-}`
+
+func TestParseMT940(t *testing.T) {
+	lines, lineErrors, err := ParseMT940(strings.NewReader(mt940File))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ImportedLine{
+		{Line: 6, Time: date(2024, 12, 30, 0, 0), Amount: NewMoney(-120000, EUR), Counterparty: "Landlord GmbH", Reference: "EREF+NOTPROVIDEDSVWZ+Rent January"},
+		// Booked on 2 January for value 31 December.
+		{Line: 9, Time: date(2025, 1, 2, 0, 0), Amount: NewMoney(250050, EUR), Reference: "Salary December thank you"},
+		// The reversal of a debit brings the money back.
+		{Line: 12, Time: date(2025, 1, 2, 0, 0), Amount: NewMoney(1999, EUR)},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d: %+v", len(lines), len(want), lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d = %+v, want %+v", i, lines[i], want[i])
+		}
+	}
+	if len(lineErrors) != 1 || lineErrors[0].Line != 13 {
+		t.Errorf("errors = %v, want the statement line on line 13", lineErrors)
+	}
+
+	if _, _, err := ParseMT940(strings.NewReader("Date,Amount\n")); !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("CSV as MT940: got %v, want %v", err, ErrInvalidImport)
+	}
+}
+
+const camt053V2File = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+  <BkToCstmrStmt>
+    <GrpHdr><MsgId>M1</MsgId><CreDtTm>2025-01-31T18:00:00</CreDtTm></GrpHdr>
+    <Stmt>
+      <Id>S1</Id>
+      <Acct><Id><IBAN>DE89370400440532013000</IBAN></Id></Acct>
+      <Ntry>
+        <Amt Ccy="EUR">1200.00</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><Dt>2025-01-02</Dt></BookgDt>
+        <ValDt><Dt>2025-01-02</Dt></ValDt>
+        <NtryDtls><TxDtls>
+          <RltdPties>
+            <Cdtr><Nm>Landlord GmbH</Nm></Cdtr>
+            <CdtrAcct><Id><IBAN>DE02120300000000202051</IBAN></Id></CdtrAcct>
+          </RltdPties>
+          <RmtInf><Ustrd>Rent</Ustrd><Ustrd>January</Ustrd></RmtInf>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="USD">50.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><DtTm>2025-01-05T10:30:00</DtTm></BookgDt>
+        <NtryDtls><TxDtls>
+          <RltdPties><DbtrAcct><Id><IBAN>GB29NWBK60161331926819</IBAN></Id></DbtrAcct></RltdPties>
+        </TxDtls></NtryDtls>
+        <AddtlNtryInf>Refund</AddtlNtryInf>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">10.00</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts>PDNG</Sts>
+        <BookgDt><Dt>2025-01-06</Dt></BookgDt>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="XXY">10.00</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><Dt>2025-01-07</Dt></BookgDt>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>`
+
+func TestParseCAMT053(t *testing.T) {
+	lines, lineErrors, err := ParseCAMT053(strings.NewReader(camt053V2File))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ImportedLine{
+		{Line: 1, Time: date(2025, 1, 2, 0, 0), Amount: NewMoney(-120000, EUR), Counterparty: "Landlord GmbH", Reference: "Rent January"},
+		{Line: 2, Time: date(2025, 1, 5, 10, 30), Amount: NewMoney(5000, USD), Counterparty: "GB29NWBK60161331926819", Reference: "Refund"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d: %+v", len(lines), len(want), lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d = %+v, want %+v", i, lines[i], want[i])
+		}
+	}
+	if len(lineErrors) != 1 || lineErrors[0].Line != 4 || !strings.Contains(lineErrors[0].Message, "currency") {
+		t.Errorf("errors = %v, want the unknown currency of entry 4", lineErrors)
+	}
+
+	camt052 := strings.Replace(camt053V2File, "camt.053.001.02", "camt.052.001.02", 1)
+	if _, _, err := ParseCAMT053(strings.NewReader(camt052)); !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("camt.052: got %v, want %v", err, ErrInvalidImport)
+	}
+	if _, _, err := ParseCAMT053(strings.NewReader(mt940File)); !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("MT940 as camt.053: got %v, want %v", err, ErrInvalidImport)
+	}
+}
+
+func TestImportStatement(t *testing.T) {
+	useStore(t)
+	b := useBooks(t)
+	openTestAccounts(t, &Account{Id: "new", Name: "New", AccountType: Giro})
+	acc, err := Store().Get("new")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := "Date,Amount,Counterparty,Reference,Type\n" +
+		"2025-01-15,2500.50,Employer,Salary,\n" +
+		"2025-01-02,-1200.00,Landlord,Rent,transfer\n" +
+		"2025-01-16,-3.50,Bakery,,\n" +
+		"2025-01-16,-3.50,Bakery,,\n" +
+		"2099-01-01,-1.00,,Future,\n" +
+		"2025-01-17,0.00,,Nothing,\n"
+	opts := ImportOptions{Format: ImportCSV, CSV: DefaultCSVMapping, DryRun: true}
+
+	preview, err := acc.ImportStatement(strings.NewReader(file), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(preview.Imported) != 4 || len(preview.Errors) != 2 || !preview.DryRun {
+		t.Fatalf("dry run = %+v, want 4 lines and 2 errors", preview)
+	}
+	if got := balanceOf(t, "new"); !got.IsZero() {
+		t.Errorf("balance after a dry run = %v, want nothing booked", got)
+	}
+
+	opts.DryRun = false
+	result, err := acc.ImportStatement(strings.NewReader(file), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Imported) != 4 || len(result.Duplicates) != 0 {
+		t.Fatalf("import = %+v, want 4 lines", result)
+	}
+	// Lines are booked in date order, with this bank's types where given.
+	rent := result.Imported[0]
+	if rent.Type != Transfer || rent.Amount != eur(1200) || rent.Counterparty != "Landlord" || !rent.Time.Equal(date(2025, 1, 2, 0, 0)) || rent.Id == "" {
+		t.Errorf("first import = %+v, want the rent transfer", rent)
+	}
+	if got := result.Imported[1]; got.Type != Deposit || got.Amount != eur(2500.50) {
+		t.Errorf("second import = %+v, want the salary deposit", got)
+	}
+	if got := balanceOf(t, "new"); got != eur(1293.50) {
+		t.Errorf("balance = %v, want 1293.50", got)
+	}
+	if _, err := b.TrialBalance(); err != nil {
+		t.Errorf("books after import: %v", err)
+	}
+
+	statement, err := acc.Statement(date(2025, 1, 1, 0, 0), date(2025, 2, 1, 0, 0), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statement.Opening != eur(0) || statement.Closing != eur(1293.50) || len(statement.Lines) != 4 || statement.Lines[0].Amount != eur(-1200) {
+		t.Errorf("statement of the import = %+v", statement)
+	}
+
+	// Importing again finds every line, a new line is still imported.
+	again := file + "2025-01-16,-3.50,Bakery,,\n"
+	result, err = acc.ImportStatement(strings.NewReader(again), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Imported) != 1 || len(result.Duplicates) != 4 {
+		t.Errorf("re-import = %+v, want 4 duplicates and the third bakery line", result)
+	}
+	if got := balanceOf(t, "new"); got != eur(1290) {
+		t.Errorf("balance after re-import = %v, want 1290.00", got)
+	}
+
+	// The statements this bank exports import into another account.
+	var exported bytes.Buffer
+	if err := statement.WriteCAMT053(&exported); err != nil {
+		t.Fatal(err)
+	}
+	openTestAccounts(t, &Account{Id: "copy", Name: "Copy", AccountType: Giro})
+	copied, err := Store().Get("copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = copied.ImportStatement(&exported, ImportOptions{Format: ImportCAMT053})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Imported) != 4 || result.Imported[0].Type != Transfer || result.Imported[0].Counterparty != "Landlord" {
+		t.Errorf("import of the camt.053 export = %+v", result)
+	}
+	if got := balanceOf(t, "copy"); got != eur(1293.50) {
+		t.Errorf("balance of the copy = %v, want 1293.50", got)
+	}
+
+	if err := SetAccountStatus("copy", StatusFrozen); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := copied.ImportStatement(strings.NewReader(file), opts); !errors.Is(err, ErrAccountFrozen) {
+		t.Errorf("import into a frozen account: got %v, want %v", err, ErrAccountFrozen)
+	}
+	if _, err := acc.ImportStatement(strings.NewReader(file), ImportOptions{Format: "pdf"}); !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("unknown format: got %v, want %v", err, ErrInvalidImport)
+	}
+}
@@ -0,0 +1,397 @@
+package bank
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type DayCount string
+type Capitalization string
+
+const (
+	Act365    DayCount = "ACT/365"
+	Act360    DayCount = "ACT/360"
+	Thirty360 DayCount = "30/360"
+
+	CapitalizeMonthly Capitalization = "monthly"
+	CapitalizeYearly  Capitalization = "yearly"
+)
+
+// maxAccrualDays bounds a single run so a wrong clock cannot spin for ages.
+const maxAccrualDays = 10 * 366
+
+// InterestTier applies Rate to the part of the balance above From, so with
+// tiers at 0 and 10000 only the amount over 10000 earns the second rate.
+type InterestTier struct {
+	From Money
+	// Rate is the nominal annual rate as a decimal ("0.015") or
+	// percentage ("1.5%").
+	Rate string
+}
+
+type InterestProduct struct {
+	Name           string
+	Tiers          []InterestTier
+	DayCount       DayCount
+	Capitalization Capitalization
+}
+
+var DefaultSavingsProduct = InterestProduct{
+	Name:           "savings",
+	Tiers:          []InterestTier{{From: NewMoney(0, DefaultCurrency), Rate: "1%"}},
+	DayCount:       Act365,
+	Capitalization: CapitalizeMonthly,
+}
+
+func ParseRate(s string) (*big.Rat, error) {
+	s = strings.TrimSpace(s)
+	percent := strings.HasSuffix(s, "%")
+	s = strings.TrimSpace(strings.TrimSuffix(s, "%"))
+
+	rate, ok := new(big.Rat).SetString(s)
+	if !ok || !isDecimal(s) {
+		return nil, fmt.Errorf("invalid rate %q", s)
+	}
+	if percent {
+		rate.Quo(rate, big.NewRat(100, 1))
+	}
+	return rate, nil
+}
+
+func (p InterestProduct) Validate() error {
+	if len(p.Tiers) == 0 {
+		return fmt.Errorf("interest product %s has no rate", p.Name)
+	}
+	for i, tier := range p.Tiers {
+		if _, err := ParseRate(tier.Rate); err != nil {
+			return fmt.Errorf("interest product %s: %w", p.Name, err)
+		}
+		if tier.From.IsNegative() {
+			return fmt.Errorf("interest product %s: tier below zero", p.Name)
+		}
+		if i > 0 {
+			if cmp, err := tier.From.Cmp(p.Tiers[i-1].From); err != nil || cmp <= 0 {
+				return fmt.Errorf("interest product %s: tiers must be in ascending order", p.Name)
+			}
+		}
+	}
+	switch p.DayCount {
+	case Act365, Act360, Thirty360:
+	default:
+		return fmt.Errorf("interest product %s: unknown day count %q", p.Name, p.DayCount)
+	}
+	switch p.Capitalization {
+	case CapitalizeMonthly, CapitalizeYearly:
+	default:
+		return fmt.Errorf("interest product %s: unknown capitalization %q", p.Name, p.Capitalization)
+	}
+	return nil
+}
+
+// dailyInterest returns the interest in minor units that balance earns on
+// day under the product.
+func (p InterestProduct) dailyInterest(balance Money, day time.Time) (*big.Rat, error) {
+	interest := new(big.Rat)
+	if !balance.IsPositive() {
+		return interest, nil
+	}
+
+	for i, tier := range p.Tiers {
+		from := tier.From.WithCurrency(balance.Currency)
+		if cmp, err := balance.Cmp(from); err != nil {
+			return nil, err
+		} else if cmp <= 0 {
+			break
+		}
+
+		band := balance.Minor - from.Minor
+		if i+1 < len(p.Tiers) {
+			next := p.Tiers[i+1].From.WithCurrency(balance.Currency)
+			band = min(band, next.Minor-from.Minor)
+		}
+
+		rate, err := ParseRate(tier.Rate)
+		if err != nil {
+			return nil, err
+		}
+		interest.Add(interest, new(big.Rat).Mul(new(big.Rat).SetInt64(band), rate))
+	}
+	return interest.Mul(interest, p.dayFraction(day)), nil
+}
+
+// dayFraction is the year fraction the day convention assigns to the day
+// starting at day.
+func (p InterestProduct) dayFraction(day time.Time) *big.Rat {
+	switch p.DayCount {
+	case Act360:
+		return big.NewRat(1, 360)
+	case Thirty360:
+		return big.NewRat(int64(days360(day, day.AddDate(0, 0, 1))), 360)
+	default:
+		return big.NewRat(1, 365)
+	}
+}
+
+// days360 counts days by the 30E/360 convention where every month has 30
+// days.
+func days360(from, to time.Time) int {
+	d1, d2 := min(from.Day(), 30), min(to.Day(), 30)
+	return 360*(to.Year()-from.Year()) + 30*int(to.Month()-from.Month()) + d2 - d1
+}
+
+func (p InterestProduct) periodEnds(day time.Time) bool {
+	next := day.AddDate(0, 0, 1)
+	if p.Capitalization == CapitalizeYearly {
+		return next.Year() != day.Year()
+	}
+	return next.Month() != day.Month()
+}
+
+// InterestState is the accrual of one account that has not been paid out
+// yet. Accrued is kept in minor units at full precision so that rounding
+// only happens once per capitalization.
+type InterestState struct {
+	AccountID      string
+	AccruedThrough time.Time
+	Accrued        *big.Rat
+}
+
+// InterestEngine accrues interest daily on every account whose type has a
+// product and capitalizes it as an Interest transaction at the end of each
+// period. Each day earns on the balance it closed with, worked back from the
+// account's history, so runs may skip days. The state is saved with every
+// capitalization, and a capitalization already on the account is not booked
+// again, so a crash between the two never pays a period twice.
+type InterestEngine struct {
+	mu       sync.Mutex
+	path     string
+	Products map[AccountType]InterestProduct
+	states   map[string]InterestState
+}
+
+func NewMemoryInterestEngine(products map[AccountType]InterestProduct) *InterestEngine {
+	return &InterestEngine{Products: products, states: map[string]InterestState{}}
+}
+
+func NewInterestEngine(path string, products map[AccountType]InterestProduct) (*InterestEngine, error) {
+	for _, product := range products {
+		if err := product.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	e := NewMemoryInterestEngine(products)
+	e.path = path
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return e, nil
+		}
+		return nil, err
+	}
+
+	var states []InterestState
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, err
+	}
+	for _, state := range states {
+		if state.Accrued == nil {
+			state.Accrued = new(big.Rat)
+		}
+		e.states[state.AccountID] = state
+	}
+	return e, nil
+}
+
+// LoadInterestProducts reads a JSON object mapping account types to
+// products.
+func LoadInterestProducts(path string) (map[AccountType]InterestProduct, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var products map[AccountType]InterestProduct
+	if err := json.Unmarshal(data, &products); err != nil {
+		return nil, err
+	}
+	for _, product := range products {
+		if err := product.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return products, nil
+}
+
+func (e *InterestEngine) State(accountID string) (InterestState, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	state, ok := e.states[accountID]
+	if ok {
+		state.Accrued = new(big.Rat).Set(state.Accrued)
+	}
+	return state, ok
+}
+
+// Run accrues every day up to and including the day before asOf. Accounts
+// seen for the first time start accruing on that day. Running twice for the
+// same day does nothing.
+func (e *InterestEngine) Run(asOf time.Time) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	accounts, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	today := startOfDay(asOf)
+	var errs []error
+	for _, acc := range accounts {
+		product, ok := e.Products[acc.AccountType]
+		if !ok {
+			continue
+		}
+		if err := e.accrue(&acc, product, today); err != nil {
+			errs = append(errs, fmt.Errorf("interest for %s: %w", acc.Id, err))
+		}
+	}
+
+	if err := e.persist(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// RunEvery calls Run with the current time every interval until ctx is done.
+func (e *InterestEngine) RunEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.Run(time.Now()); err != nil {
+			fmt.Println("interest:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *InterestEngine) accrue(acc *Account, product InterestProduct, today time.Time) error {
+	state, ok := e.states[acc.Id]
+	if !ok {
+		e.states[acc.Id] = InterestState{AccountID: acc.Id, AccruedThrough: today, Accrued: new(big.Rat)}
+		return nil
+	}
+
+	signed := books.transactionDeltas(acc.Id, acc.BaseCurrency())
+	for day, n := state.AccruedThrough, 0; day.Before(today) && n < maxAccrualDays; day, n = day.AddDate(0, 0, 1), n+1 {
+		balance := acc.balanceAt(acc.BaseCurrency(), day.AddDate(0, 0, 1), signed)
+		interest, err := product.dailyInterest(balance, day)
+		if err != nil {
+			return err
+		}
+		state.Accrued.Add(state.Accrued, interest)
+		state.AccruedThrough = day.AddDate(0, 0, 1)
+
+		if product.periodEnds(day) {
+			paid, err := acc.capitalize(state.Accrued, product, state.AccruedThrough)
+			if err != nil {
+				e.states[acc.Id] = state
+				return err
+			}
+			state.Accrued.Sub(state.Accrued, paid)
+			e.states[acc.Id] = state
+			if err := e.persist(); err != nil {
+				return err
+			}
+		}
+	}
+
+	e.states[acc.Id] = state
+	return nil
+}
+
+// capitalize books the whole minor units of accrued as interest and returns
+// what it booked, the remainder carries over into the next period. Interest
+// already booked for the period is returned instead of booked again.
+func (account *Account) capitalize(accrued *big.Rat, product InterestProduct, at time.Time) (*big.Rat, error) {
+	minor, err := roundRat(accrued, RoundDown)
+	if err != nil {
+		return nil, err
+	}
+	if minor <= 0 {
+		return new(big.Rat), nil
+	}
+
+	amount := NewMoney(minor, account.Balance.Currency)
+	reference := fmt.Sprintf("%s interest %s", product.Name, at.AddDate(0, 0, -1).Format("2006-01"))
+	txID := newTransactionID()
+	err = update(func() error {
+		for _, txn := range account.Transactions {
+			if txn.Type == Interest && txn.Time.Equal(at) && txn.Reference == reference {
+				minor = txn.Amount.Minor
+				return nil
+			}
+		}
+
+		err := account.record(Event{
+			Type:          InterestPaid,
+			Time:          at,
+			Amount:        amount,
+			TransactionID: txID,
+			Reference:     reference,
+		})
+		if err != nil {
+			return err
+		}
+
+		entry := NewEntry("interest "+account.Id,
+			Leg{Account: InterestExpenseAccount, Side: Debit, Amount: amount},
+			Leg{Account: account.Id, Side: Credit, Amount: amount},
+		)
+		entry.TransactionID = txID
+		return postAndSave(entry, account)
+	}, account)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Rat).SetInt64(minor), nil
+}
+
+func (e *InterestEngine) persist() error {
+	if e.path == "" {
+		return nil
+	}
+
+	states := make([]InterestState, 0, len(e.states))
+	for _, state := range e.states {
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].AccountID < states[j].AccountID })
+
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(e.path, data, 0644)
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,222 @@
+package bank
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := map[string]*big.Rat{
+		"0.015": big.NewRat(15, 1000),
+		"1.5%":  big.NewRat(15, 1000),
+		" 2 % ": big.NewRat(2, 100),
+	}
+	for input, want := range tests {
+		got, err := ParseRate(input)
+		if err != nil {
+			t.Errorf("ParseRate(%q) error = %v", input, err)
+			continue
+		}
+		if got.Cmp(want) != 0 {
+			t.Errorf("ParseRate(%q) = %v, want %v", input, got, want)
+		}
+	}
+
+	for _, input := range []string{"", "abc", "1/3", "%"} {
+		if _, err := ParseRate(input); err == nil {
+			t.Errorf("ParseRate(%q) accepted an invalid rate", input)
+		}
+	}
+}
+
+func TestDayCountConventions(t *testing.T) {
+	monthFraction := func(dc DayCount, year int, month time.Month) *big.Rat {
+		p := InterestProduct{DayCount: dc}
+		total := new(big.Rat)
+		for day := date(year, month, 1, 0, 0); day.Month() == month; day = day.AddDate(0, 0, 1) {
+			total.Add(total, p.dayFraction(day))
+		}
+		return total
+	}
+
+	tests := []struct {
+		dayCount DayCount
+		month    time.Month
+		want     *big.Rat
+	}{
+		{Act365, time.January, big.NewRat(31, 365)},
+		{Act365, time.February, big.NewRat(28, 365)},
+		{Act360, time.February, big.NewRat(28, 360)},
+		{Thirty360, time.January, big.NewRat(30, 360)},
+		{Thirty360, time.February, big.NewRat(30, 360)},
+		{Thirty360, time.April, big.NewRat(30, 360)},
+	}
+	for _, tt := range tests {
+		if got := monthFraction(tt.dayCount, 2026, tt.month); got.Cmp(tt.want) != 0 {
+			t.Errorf("%s %s = %v, want %v", tt.dayCount, tt.month, got, tt.want)
+		}
+	}
+}
+
+func TestTieredInterest(t *testing.T) {
+	product := InterestProduct{
+		Tiers: []InterestTier{
+			{From: eur(0), Rate: "1%"},
+			{From: eur(10000), Rate: "2%"},
+		},
+		DayCount: Act365,
+	}
+
+	tests := []struct {
+		balance Money
+		want    *big.Rat
+	}{
+		{eur(5000), big.NewRat(5000, 365)},
+		{eur(15000), big.NewRat(10000+10000, 365)},
+		{eur(-100), new(big.Rat)},
+	}
+	for _, tt := range tests {
+		got, err := product.dailyInterest(tt.balance, date(2026, 1, 1, 0, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Cmp(tt.want) != 0 {
+			t.Errorf("dailyInterest(%v) = %v minor units, want %v", tt.balance, got, tt.want)
+		}
+	}
+}
+
+func TestInterestEngine(t *testing.T) {
+	useStore(t)
+	b := useBooks(t)
+
+	openTestAccounts(t,
+		&Account{Id: "saver", Name: "Saver", Balance: eur(10000), AccountType: Savings},
+		&Account{Id: "small", Name: "Small", Balance: eur(100), AccountType: Savings},
+		&Account{Id: "giro", Name: "Giro", Balance: eur(10000), AccountType: Giro},
+	)
+
+	engine := NewMemoryInterestEngine(map[AccountType]InterestProduct{
+		Savings: {
+			Name:           "test",
+			Tiers:          []InterestTier{{From: eur(0), Rate: "3.65%"}},
+			DayCount:       Act365,
+			Capitalization: CapitalizeMonthly,
+		},
+	})
+
+	for _, asOf := range []time.Time{
+		date(2026, 1, 1, 8, 0),
+		date(2026, 1, 15, 8, 0),
+		date(2026, 2, 1, 8, 0),
+		date(2026, 2, 1, 20, 0),
+	} {
+		if err := engine.Run(asOf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 10000 at 3.65% earns exactly 1.00 a day, January has 31 days.
+	if got := balanceOf(t, "saver"); got != eur(10031) {
+		t.Errorf("saver balance = %v, want 10031", got)
+	}
+	if got := balanceOf(t, "giro"); got != eur(10000) {
+		t.Errorf("giro earned interest: %v", got)
+	}
+
+	// 100 earns 100*0.0365/365 = 0.01 a day, 0.31 for January.
+	if got := balanceOf(t, "small"); got != eur(100.31) {
+		t.Errorf("small balance = %v, want 100.31", got)
+	}
+
+	saver, err := Store().Get("saver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paid := saver.Transactions[len(saver.Transactions)-1]
+	if paid.Type != Interest || !paid.Time.Equal(date(2026, 2, 1, 0, 0)) || paid.Reference != "test interest 2026-01" {
+		t.Errorf("interest transaction = %+v", paid)
+	}
+	if got := b.Balance(InterestExpenseAccount, EUR); got != eur(-31.31) {
+		t.Errorf("interest expense = %v, want -31.31", got)
+	}
+
+	state, _ := engine.State("saver")
+	if !state.AccruedThrough.Equal(date(2026, 2, 1, 0, 0)) || state.Accrued.Sign() != 0 {
+		t.Errorf("state after capitalization = %+v", state)
+	}
+}
+
+func TestInterestCarriesRemainder(t *testing.T) {
+	useStore(t)
+	useBooks(t)
+	openTestAccounts(t, &Account{Id: "tiny", Name: "Tiny", Balance: eur(100), AccountType: Savings})
+
+	products := map[AccountType]InterestProduct{Savings: DefaultSavingsProduct}
+	path := filepath.Join(t.TempDir(), "interest.json")
+	engine, err := NewInterestEngine(path, products)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.Run(date(2026, 1, 1, 0, 0))
+	engine.Run(date(2026, 2, 1, 0, 0))
+
+	// 100 at 1% earns 31 * 10000 * 0.01 / 365 = 8.49 cents in January.
+	if got := balanceOf(t, "tiny"); got != eur(100.08) {
+		t.Errorf("balance = %v, want 100.08", got)
+	}
+	if engine, err = NewInterestEngine(path, products); err != nil {
+		t.Fatal(err)
+	}
+	state, _ := engine.State("tiny")
+	want := new(big.Rat).Sub(big.NewRat(3100, 365), big.NewRat(8, 1))
+	if state.Accrued.Cmp(want) != 0 {
+		t.Errorf("carried remainder = %v, want %v", state.Accrued, want)
+	}
+}
+
+func TestInterestPaidOnceWhenStateIsLost(t *testing.T) {
+	useStore(t)
+	useBooks(t)
+	openTestAccounts(t, &Account{Id: "saver", Name: "Saver", Balance: eur(10000), AccountType: Savings})
+
+	products := map[AccountType]InterestProduct{Savings: {
+		Name:           "test",
+		Tiers:          []InterestTier{{From: eur(0), Rate: "3.65%"}},
+		DayCount:       Act365,
+		Capitalization: CapitalizeMonthly,
+	}}
+	path := filepath.Join(t.TempDir(), "interest.json")
+	engine, err := NewInterestEngine(path, products)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Run(date(2026, 1, 1, 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The interest is booked but its state cannot be saved, as if the
+	// process died in between.
+	engine.path = filepath.Join(t.TempDir(), "missing", "interest.json")
+	if err := engine.Run(date(2026, 2, 1, 0, 0)); err == nil {
+		t.Fatal("run saved its state into a missing directory")
+	}
+	if got := balanceOf(t, "saver"); got != eur(10031) {
+		t.Fatalf("balance = %v, want 10031", got)
+	}
+
+	if engine, err = NewInterestEngine(path, products); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Run(date(2026, 2, 1, 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if got := balanceOf(t, "saver"); got != eur(10031) {
+		t.Errorf("balance after the rerun = %v, want January's interest paid once", got)
+	}
+	if state, _ := engine.State("saver"); !state.AccruedThrough.Equal(date(2026, 2, 1, 0, 0)) || state.Accrued.Sign() != 0 {
+		t.Errorf("state after the rerun = %+v", state)
+	}
+}
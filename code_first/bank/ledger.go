@@ -0,0 +1,200 @@
+package bank
+
+import (
+	"fmt"
+	"maps"
+	"time"
+)
+
+type EventType string
+
+const (
+	AccountOpened  EventType = "AccountOpened"
+	AccountClosed  EventType = "AccountClosed"
+	Deposited      EventType = "Deposited"
+	Withdrawn      EventType = "Withdrawn"
+	TransferredOut EventType = "TransferredOut"
+	TransferredIn  EventType = "TransferredIn"
+	FeeCharged     EventType = "FeeCharged"
+	OwnerChanged   EventType = "OwnerChanged"
+	InterestPaid   EventType = "InterestPaid"
+	StatusChanged  EventType = "StatusChanged"
+	IBANAssigned   EventType = "IBANAssigned"
+	// ExchangedOut and ExchangedIn are the two sides of a currency exchange
+	// on one account, they share the transaction id and the rate.
+	ExchangedOut EventType = "ExchangedOut"
+	ExchangedIn  EventType = "ExchangedIn"
+	// TransactionReversed books the signed amount that undoes an earlier
+	// transaction and marks that transaction as reversed.
+	TransactionReversed EventType = "TransactionReversed"
+)
+
+type Event struct {
+	Seq          uint64
+	Time         time.Time
+	Type         EventType
+	AccountID    string
+	Amount       Money
+	Counterparty string        `json:",omitempty"`
+	Owner        string        `json:",omitempty"`
+	Status       AccountStatus `json:",omitempty"`
+	IBAN         IBAN          `json:",omitempty"`
+
+	TransactionID string    `json:",omitempty"`
+	Reference     string    `json:",omitempty"`
+	Reverses      string    `json:",omitempty"`
+	Rate          string    `json:",omitempty"`
+	RateDate      time.Time `json:",omitzero"`
+
+	// Only set on AccountOpened.
+	Name        string             `json:",omitempty"`
+	AccountType AccountType        `json:",omitempty"`
+	Overdraw    *Money             `json:",omitempty"`
+	SubBalances map[Currency]Money `json:",omitempty"`
+	History     []Transactions     `json:",omitempty"`
+}
+
+// isAdministrative reports whether the event changes the account without
+// booking money, so it carries no transaction id.
+func (t EventType) isAdministrative() bool {
+	return t == AccountOpened || t == OwnerChanged || t == StatusChanged || t == IBANAssigned
+}
+
+func (account *Account) record(e Event) error {
+	e.AccountID = account.Id
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.TransactionID == "" && !e.Type.isAdministrative() {
+		e.TransactionID = newTransactionID()
+	}
+
+	if err := account.apply(e); err != nil {
+		return err
+	}
+	account.pending = append(account.pending, e)
+	return nil
+}
+
+func (account *Account) apply(e Event) error {
+	if e.Type == AccountOpened {
+		*account = Account{
+			Id:           e.AccountID,
+			Name:         e.Name,
+			Balance:      e.Amount,
+			AccountType:  e.AccountType,
+			Owner:        e.Owner,
+			Status:       e.Status,
+			IBAN:         e.IBAN,
+			SubBalances:  maps.Clone(e.SubBalances),
+			Transactions: append([]Transactions(nil), e.History...),
+		}
+		if e.Overdraw != nil {
+			account.Overdraw = *e.Overdraw
+		}
+		return nil
+	}
+	if e.Type == OwnerChanged {
+		account.Owner = e.Owner
+		return nil
+	}
+	if e.Type == StatusChanged {
+		account.Status = e.Status
+		return nil
+	}
+	if e.Type == IBANAssigned {
+		account.IBAN = e.IBAN
+		return nil
+	}
+
+	delta, tt, err := eventEffect(e)
+	if err != nil {
+		return err
+	}
+
+	if err := account.adjust(delta); err != nil {
+		return err
+	}
+
+	account.Transactions = append(account.Transactions, Transactions{
+		Id:           e.TransactionID,
+		Time:         e.Time,
+		Amount:       e.Amount,
+		Type:         tt,
+		Counterparty: e.Counterparty,
+		Reference:    e.Reference,
+		Status:       StatusBooked,
+		Reverses:     e.Reverses,
+		Rate:         e.Rate,
+		RateDate:     e.RateDate,
+	})
+
+	if e.Type == TransactionReversed {
+		for i := range account.Transactions {
+			if account.Transactions[i].Id == e.Reverses {
+				account.Transactions[i].Status = StatusReversed
+				account.Transactions[i].ReversedBy = e.TransactionID
+			}
+		}
+	}
+	return nil
+}
+
+func eventEffect(e Event) (Money, TransactionType, error) {
+	switch e.Type {
+	case Deposited:
+		return e.Amount, Deposit, nil
+	case Withdrawn:
+		return e.Amount.Neg(), Withdraw, nil
+	case TransferredOut:
+		return e.Amount.Neg(), Transfer, nil
+	case TransferredIn:
+		return e.Amount, Transfer, nil
+	case FeeCharged:
+		return e.Amount.Neg(), Fee, nil
+	case InterestPaid:
+		return e.Amount, Interest, nil
+	case TransactionReversed:
+		return e.Amount, Reversal, nil
+	case ExchangedOut:
+		return e.Amount.Neg(), Exchange, nil
+	case ExchangedIn:
+		return e.Amount, Exchange, nil
+	default:
+		return Money{}, "", fmt.Errorf("unknown event type: %s", e.Type)
+	}
+}
+
+func openingEvent(account *Account) (Event, error) {
+	opening := account.clone()
+	history := account.Transactions
+	for _, e := range account.pending {
+		if e.Type.isAdministrative() {
+			continue
+		}
+		delta, _, err := eventEffect(e)
+		if err != nil {
+			return Event{}, err
+		}
+		if err := opening.adjust(delta.Neg()); err != nil {
+			return Event{}, err
+		}
+		history = history[:len(history)-1]
+	}
+
+	overdraw := account.Overdraw
+	return Event{
+		Time:        time.Now(),
+		Type:        AccountOpened,
+		AccountID:   account.Id,
+		Amount:      opening.Balance,
+		Name:        account.Name,
+		AccountType: account.AccountType,
+		Owner:       account.Owner,
+		Status:      account.Status,
+		IBAN:        account.IBAN,
+		Overdraw:    &overdraw,
+		SubBalances: opening.SubBalances,
+		History:     history,
+	}, nil
+}
@@ -0,0 +1,272 @@
+package bank
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func useEventStore(t *testing.T, dir string) *EventStore {
+	t.Helper()
+
+	es, err := NewEventStore(dir)
+	if err != nil {
+		t.Fatalf("NewEventStore() error = %v", err)
+	}
+
+	original := store
+	SetStore(es)
+	t.Cleanup(func() { SetStore(original) })
+	return es
+}
+
+func TestEventStoreReplay(t *testing.T) {
+	dir := t.TempDir()
+	es := useEventStore(t, dir)
+
+	alice := &Account{Id: "1", Name: "Alice", Balance: eur(100), AccountType: Giro}
+	bob := &Account{Id: "2", Name: "Bob", Balance: eur(50), AccountType: Savings}
+	for _, acc := range []*Account{alice, bob} {
+		if err := acc.save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := alice.Deposit(eur(25)); err != nil {
+		t.Fatal(err)
+	}
+	if err := alice.Withdraw(eur(5)); err != nil {
+		t.Fatal(err)
+	}
+	if err := alice.Transfer(eur(20), "Bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := es.Events()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTypes := []EventType{AccountOpened, AccountOpened, Deposited, Withdrawn, TransferredOut, TransferredIn}
+	if len(events) != len(wantTypes) {
+		t.Fatalf("got %d events, want %d", len(events), len(wantTypes))
+	}
+	for i, e := range events {
+		if e.Type != wantTypes[i] || e.Seq != uint64(i+1) {
+			t.Errorf("event %d = %s (seq %d), want %s", i, e.Type, e.Seq, wantTypes[i])
+		}
+	}
+
+	reopened, err := NewEventStore(dir)
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+
+	tests := map[string]struct {
+		id          string
+		wantBalance Money
+		wantTxns    int
+	}{
+		"Alice": {id: "1", wantBalance: eur(100), wantTxns: 3},
+		"Bob":   {id: "2", wantBalance: eur(70), wantTxns: 1},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			acc, err := reopened.Get(tc.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if acc.Balance != tc.wantBalance || len(acc.Transactions) != tc.wantTxns {
+				t.Errorf("balance = %v (%d txns), want %v (%d txns)",
+					acc.Balance, len(acc.Transactions), tc.wantBalance, tc.wantTxns)
+			}
+		})
+	}
+}
+
+func TestEventStoreOpensAccountWithPendingEvents(t *testing.T) {
+	es := useEventStore(t, t.TempDir())
+
+	acc := &Account{Id: "9", Name: "New", AccountType: Giro}
+	if err := acc.Deposit(eur(10)); err != nil {
+		t.Fatal(err)
+	}
+
+	events, _ := es.Events()
+	if len(events) != 2 || events[0].Type != AccountOpened || !events[0].Amount.IsZero() {
+		t.Fatalf("events = %+v, want zero-balance AccountOpened followed by Deposited", events)
+	}
+
+	got, err := es.Get("9")
+	if err != nil || got.Balance != eur(10) {
+		t.Errorf("Get(9) = %v, %v, want balance 10", got, err)
+	}
+}
+
+func TestEventStoreSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	es := useEventStore(t, dir)
+	es.SnapshotEvery = 3
+
+	acc := &Account{Id: "1", Name: "Alice", AccountType: Giro}
+	for range 4 {
+		if err := acc.Deposit(eur(1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, snapshotFile)); err != nil {
+		t.Fatalf("snapshot not written: %v", err)
+	}
+	if es.snapshotSeq != 3 {
+		t.Errorf("snapshot seq = %d, want 3", es.snapshotSeq)
+	}
+
+	reopened, err := NewEventStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.seq != 5 {
+		t.Errorf("replayed up to seq %d, want 5", reopened.seq)
+	}
+
+	got, err := reopened.Get("1")
+	if err != nil || got.Balance != eur(4) || len(got.Transactions) != 4 {
+		t.Errorf("Get(1) = %+v, %v, want balance 4 with 4 txns", got, err)
+	}
+}
+
+func TestEventStoreSnapshotFailure(t *testing.T) {
+	dir := t.TempDir()
+	es := useEventStore(t, dir)
+	es.SnapshotEvery = 1
+	var failures []error
+	es.OnSnapshotError = func(err error) { failures = append(failures, err) }
+
+	// A directory in the way of the snapshot file fails every snapshot.
+	if err := os.MkdirAll(filepath.Join(dir, snapshotFile, "in-the-way"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	acc := &Account{Id: "1", Name: "Alice", AccountType: Giro}
+	if err := acc.Deposit(eur(1)); err != nil {
+		t.Fatalf("deposit failed with its snapshot: %v", err)
+	}
+	if len(failures) != 1 {
+		t.Errorf("reported %d snapshot failures, want 1", len(failures))
+	}
+
+	if err := os.RemoveAll(filepath.Join(dir, snapshotFile)); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewEventStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := reopened.Get("1"); err != nil || got.Balance != eur(1) {
+		t.Errorf("Get(1) = %+v, %v, want the deposit from the journal", got, err)
+	}
+}
+
+func TestEventStoreRejectsUnrecordedChanges(t *testing.T) {
+	es := useEventStore(t, t.TempDir())
+
+	acc := &Account{Id: "1", Name: "Alice", AccountType: Giro}
+	if err := es.Save(acc); err != nil {
+		t.Fatal(err)
+	}
+	if err := es.Save(acc); err != nil {
+		t.Errorf("saving an unchanged account: %v", err)
+	}
+
+	acc.Name = "Mallory"
+	if err := es.Save(acc); !errors.Is(err, ErrUnrecordedChange) {
+		t.Errorf("saving a renamed account: got %v, want %v", err, ErrUnrecordedChange)
+	}
+	if got, err := es.Get("1"); err != nil || got.Name != "Alice" {
+		t.Errorf("Get(1) = %+v, %v, want Alice unchanged", got, err)
+	}
+}
+
+func TestEventStoreDelete(t *testing.T) {
+	dir := t.TempDir()
+	es := useEventStore(t, dir)
+
+	if err := es.Save(&Account{Id: "1", Name: "Alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := es.Delete("1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := es.Delete("1"); !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("second Delete error = %v, want ErrAccountNotFound", err)
+	}
+
+	reopened, err := NewEventStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reopened.Get("1"); !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("Get after replay error = %v, want ErrAccountNotFound", err)
+	}
+}
+
+func TestEventStoreIgnoresTornTail(t *testing.T) {
+	dir := t.TempDir()
+	es, err := NewEventStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := es.Save(&Account{Id: "1", Name: "Alice", Balance: eur(10)}); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, journalFile), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"Seq":2,"Type":"Depos`)
+	f.Close()
+
+	reopened, err := NewEventStore(dir)
+	if err != nil {
+		t.Fatalf("reopen with torn tail error = %v", err)
+	}
+
+	acc, _ := reopened.Get("1")
+	SetStore(reopened)
+	t.Cleanup(func() { SetStore(NewMemoryStore()) })
+	if err := acc.Deposit(eur(5)); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := reopened.Events()
+	if err != nil || len(events) != 2 || events[1].Type != Deposited {
+		t.Errorf("events = %+v, %v, want torn write replaced by the deposit", events, err)
+	}
+}
+
+func TestEventStoresShareJournal(t *testing.T) {
+	dir := t.TempDir()
+	first, err := NewEventStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewEventStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := first.Save(&Account{Id: "1", Name: "Alice", Balance: eur(10)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Save(&Account{Id: "2", Name: "Bob", Balance: eur(20)}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, es := range []*EventStore{first, second} {
+		accounts, err := es.List()
+		if err != nil || len(accounts) != 2 {
+			t.Errorf("List() = %v, %v, want both accounts", accounts, err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package bank
+
+import (
+	"errors"
+	"slices"
+	"sync"
+)
+
+var accountLocks sync.Map
+
+func lockAccounts(ids ...string) func() {
+	sorted := slices.Compact(slices.Sorted(slices.Values(ids)))
+
+	mutexes := make([]*sync.Mutex, len(sorted))
+	for i, id := range sorted {
+		mu, _ := accountLocks.LoadOrStore(id, &sync.Mutex{})
+		mutexes[i] = mu.(*sync.Mutex)
+		mutexes[i].Lock()
+	}
+
+	return func() {
+		for i := len(mutexes) - 1; i >= 0; i-- {
+			mutexes[i].Unlock()
+		}
+	}
+}
+
+// update runs fn while holding the locks of all given accounts, after
+// reloading them from the store so that callers holding an older copy do not
+// overwrite bookings made through another copy. If fn fails the accounts are
+// reset to their state before the call.
+func update(fn func() error, accounts ...*Account) error {
+	ids := make([]string, len(accounts))
+	for i, acc := range accounts {
+		ids[i] = acc.Id
+	}
+
+	unlock := lockAccounts(ids...)
+	defer unlock()
+
+	before := make([]Account, len(accounts))
+	for i, acc := range accounts {
+		before[i] = acc.clone()
+	}
+
+	err := refresh(accounts...)
+	if err == nil {
+		err = fn()
+	}
+	if err != nil {
+		for i, acc := range accounts {
+			acc.assign(&before[i])
+		}
+	}
+	return err
+}
+
+func refresh(accounts ...*Account) error {
+	for _, acc := range accounts {
+		fresh, err := store.Get(acc.Id)
+		if errors.Is(err, ErrAccountNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		acc.assign(fresh)
+	}
+	return nil
+}
+
+// assign copies every field except Id, which is read without holding the
+// account lock to find out which lock to take.
+func (account *Account) assign(other *Account) {
+	account.Name = other.Name
+	account.IBAN = other.IBAN
+	account.Balance = other.Balance
+	account.SubBalances = other.SubBalances
+	account.Overdraw = other.Overdraw
+	account.AccountType = other.AccountType
+	account.Owner = other.Owner
+	account.Status = other.Status
+	account.Transactions = other.Transactions
+	account.pending = nil
+}
@@ -0,0 +1,100 @@
+package bank
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestConcurrentDepositsThroughSeparateCopies(t *testing.T) {
+	useBooks(t)
+	ms := useStore(t, Account{Id: "1", Name: "Alice", Balance: eur(0), AccountType: Giro})
+
+	const workers = 50
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			acc, err := ms.Get("1")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if err := acc.Deposit(eur(1)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, _ := ms.Get("1")
+	if got.Balance != eur(workers) || len(got.Transactions) != workers {
+		t.Errorf("balance = %v with %d txns, want %d with %d txns",
+			got.Balance, len(got.Transactions), workers, workers)
+	}
+}
+
+func TestConcurrentWithdrawalsRespectLimit(t *testing.T) {
+	useBooks(t)
+	ms := useStore(t, Account{Id: "1", Name: "Alice", Balance: eur(10), AccountType: Savings})
+	shared, _ := ms.Get("1")
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for range 30 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if shared.Withdraw(eur(1)) == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, _ := ms.Get("1")
+	if succeeded != 10 || !got.Balance.IsZero() {
+		t.Errorf("%d withdrawals succeeded, balance = %v, want 10 and 0", succeeded, got.Balance)
+	}
+}
+
+func TestConcurrentOpposingTransfers(t *testing.T) {
+	b := useBooks(t)
+	ms := useStore(t,
+		Account{Id: "1", Name: "Alice", Balance: eur(1000), AccountType: Giro},
+		Account{Id: "2", Name: "Bob", Balance: eur(1000), AccountType: Giro},
+	)
+
+	var wg sync.WaitGroup
+	for i := range 40 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			from, to := "1", "Bob"
+			if i%2 == 1 {
+				from, to = "2", "Alice"
+			}
+			acc, err := ms.Get(from)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if err := acc.Transfer(eur(5), to); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	alice, _ := ms.Get("1")
+	bob, _ := ms.Get("2")
+	if alice.Balance != eur(1000) || bob.Balance != eur(1000) {
+		t.Errorf("balances = %v / %v, want 1000 / 1000", alice.Balance, bob.Balance)
+	}
+	if _, err := b.TrialBalance(); err != nil {
+		t.Errorf("TrialBalance() error = %v", err)
+	}
+}
@@ -0,0 +1,319 @@
+package bank
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+type RoundingMode int
+
+const (
+	RoundHalfEven RoundingMode = iota
+	RoundHalfUp
+	RoundDown
+	RoundUp
+)
+
+const DefaultCurrency = EUR
+
+var (
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrMoneyOverflow    = errors.New("amount out of range")
+	ErrInvalidAmount    = errors.New("invalid amount")
+)
+
+type Money struct {
+	Minor    int64
+	Currency Currency
+}
+
+func NewMoney(minor int64, currency Currency) Money {
+	return Money{Minor: minor, Currency: currency}
+}
+
+func ParseMoney(s string, currency Currency) (Money, error) {
+	return parseMoney(s, currency, nil)
+}
+
+func ParseMoneyRounded(s string, currency Currency, mode RoundingMode) (Money, error) {
+	return parseMoney(s, currency, &mode)
+}
+
+func MustParseMoney(s string, currency Currency) Money {
+	m, err := ParseMoney(s, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func MoneyFromRat(r *big.Rat, currency Currency, mode RoundingMode) (Money, error) {
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(scaleOf(currency)))
+	minor, err := roundRat(scaled, mode)
+	if err != nil {
+		return Money{}, err
+	}
+	return NewMoney(minor, currency), nil
+}
+
+func parseMoney(s string, currency Currency, mode *RoundingMode) (Money, error) {
+	s = strings.TrimSpace(s)
+	if value, code, found := strings.Cut(s, " "); found {
+		parsed, err := ParseCurrency(code)
+		if err != nil {
+			return Money{}, err
+		}
+		if currency != "" && parsed != currency {
+			return Money{}, fmt.Errorf("%w: %s != %s", ErrCurrencyMismatch, parsed.Code(), currency.Code())
+		}
+		s, currency = value, parsed
+	}
+
+	if !isDecimal(s) {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(scaleOf(currency)))
+	if mode == nil && !scaled.IsInt() {
+		return Money{}, fmt.Errorf("%w: %q has more than %d decimals", ErrInvalidAmount, s, currency.MinorUnits())
+	}
+
+	roundWith := RoundHalfEven
+	if mode != nil {
+		roundWith = *mode
+	}
+	minor, err := roundRat(scaled, roundWith)
+	if err != nil {
+		return Money{}, err
+	}
+	return NewMoney(minor, currency), nil
+}
+
+func isDecimal(s string) bool {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	digits, dots := 0, 0
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case r == '.':
+			dots++
+		default:
+			return false
+		}
+	}
+	return digits > 0 && dots <= 1
+}
+
+func roundRat(r *big.Rat, mode RoundingMode) (int64, error) {
+	den := r.Denom()
+	q, rem := new(big.Int).QuoRem(r.Num(), den, new(big.Int))
+
+	if rem.Sign() != 0 {
+		step := big.NewInt(int64(r.Sign()))
+		switch mode {
+		case RoundDown:
+		case RoundUp:
+			q.Add(q, step)
+		case RoundHalfUp, RoundHalfEven:
+			twice := new(big.Int).Abs(rem)
+			twice.Lsh(twice, 1)
+			cmp := twice.Cmp(den)
+			if cmp > 0 || (cmp == 0 && (mode == RoundHalfUp || q.Bit(0) == 1)) {
+				q.Add(q, step)
+			}
+		default:
+			return 0, fmt.Errorf("unknown rounding mode: %d", mode)
+		}
+	}
+
+	if !q.IsInt64() {
+		return 0, ErrMoneyOverflow
+	}
+	return q.Int64(), nil
+}
+
+func scaleOf(currency Currency) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(currency.MinorUnits())), nil)
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	currency, err := m.commonCurrency(other)
+	if err != nil {
+		return Money{}, err
+	}
+	if (other.Minor > 0 && m.Minor > math.MaxInt64-other.Minor) ||
+		(other.Minor < 0 && m.Minor < math.MinInt64-other.Minor) {
+		return Money{}, ErrMoneyOverflow
+	}
+	return NewMoney(m.Minor+other.Minor, currency), nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	if other.Minor == math.MinInt64 {
+		return Money{}, ErrMoneyOverflow
+	}
+	return m.Add(other.Neg())
+}
+
+func (m Money) Neg() Money {
+	return NewMoney(-m.Minor, m.Currency)
+}
+
+func (m Money) Cmp(other Money) (int, error) {
+	if _, err := m.commonCurrency(other); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Minor < other.Minor:
+		return -1, nil
+	case m.Minor > other.Minor:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+func (m Money) Mul(factor *big.Rat, mode RoundingMode) (Money, error) {
+	r := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Minor), factor)
+	minor, err := roundRat(r, mode)
+	if err != nil {
+		return Money{}, err
+	}
+	return NewMoney(minor, m.Currency), nil
+}
+
+func (m Money) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(big.NewInt(m.Minor), scaleOf(m.Currency))
+}
+
+func (m Money) IsZero() bool {
+	return m.Minor == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Minor > 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Minor < 0
+}
+
+// WithCurrency gives an amount without currency the currency. Such amounts
+// are parsed in hundredths, so they are rescaled to the currency's minor
+// units, rounding half to even for currencies with fewer decimals. The rare
+// amount too large to rescale keeps its minor units; InCurrency reports
+// both cases.
+func (m Money) WithCurrency(currency Currency) Money {
+	if m.Currency != "" {
+		return m
+	}
+	converted, err := MoneyFromRat(m.Rat(), currency, RoundHalfEven)
+	if err != nil {
+		return NewMoney(m.Minor, currency)
+	}
+	return converted
+}
+
+// InCurrency is WithCurrency for amounts given by customers, which are
+// rejected if they have more decimals than the currency or do not fit.
+func (m Money) InCurrency(currency Currency) (Money, error) {
+	if m.Currency != "" {
+		return m, nil
+	}
+	scaled := new(big.Rat).Mul(m.Rat(), new(big.Rat).SetInt(scaleOf(currency)))
+	if !scaled.IsInt() {
+		return Money{}, fmt.Errorf("%w: %s has more than %d decimals", ErrInvalidAmount, m.Decimal(), currency.MinorUnits())
+	}
+	return MoneyFromRat(m.Rat(), currency, RoundHalfEven)
+}
+
+func (m Money) Decimal() string {
+	return m.Rat().FloatString(m.Currency.MinorUnits())
+}
+
+func (m Money) String() string {
+	if m.Currency == "" {
+		return m.Decimal()
+	}
+	return m.Decimal() + " " + m.Currency.Code()
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*m = Money{}
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '"' {
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		parsed, err := ParseMoney(text, "")
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	}
+
+	// Bare JSON numbers come from float64 balances written before Money
+	// existed, so they are rounded to the nearest minor unit.
+	r, ok := new(big.Rat).SetString(string(data))
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrInvalidAmount, data)
+	}
+	parsed, err := MoneyFromRat(r, "", RoundHalfEven)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func (m Money) commonCurrency(other Money) (Currency, error) {
+	switch {
+	case m.Currency == other.Currency || other.Currency == "":
+		return m.Currency, nil
+	case m.Currency == "":
+		return other.Currency, nil
+	default:
+		return "", fmt.Errorf("%w: %s != %s", ErrCurrencyMismatch, m.Currency.Code(), other.Currency.Code())
+	}
+}
+
+func Migrate(s AccountStore) error {
+	accounts, err := s.List()
+	if err != nil {
+		return err
+	}
+
+	for i := range accounts {
+		acc := &accounts[i]
+		acc.Balance = acc.Balance.WithCurrency(DefaultCurrency)
+		acc.Overdraw = acc.Overdraw.WithCurrency(acc.Balance.Currency)
+		for j := range acc.Transactions {
+			acc.Transactions[j].Amount = acc.Transactions[j].Amount.WithCurrency(acc.Balance.Currency)
+		}
+		if err := s.Save(acc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
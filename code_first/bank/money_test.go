@@ -0,0 +1,169 @@
+package bank
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := map[string]struct {
+		input     string
+		currency  Currency
+		want      Money
+		wantError bool
+	}{
+		"Happy Path: decimal":             {input: "10.50", currency: EUR, want: NewMoney(1050, EUR)},
+		"Happy Path: integer":             {input: "7", currency: EUR, want: NewMoney(700, EUR)},
+		"Happy Path: negative":            {input: "-0.01", currency: EUR, want: NewMoney(-1, EUR)},
+		"Happy Path: with currency code":  {input: "1.25 USD", currency: "", want: NewMoney(125, USD)},
+		"Happy Path: zero decimals":       {input: "120", currency: JPY, want: NewMoney(120, JPY)},
+		"Unhappy Path: too many decimals": {input: "0.105", currency: EUR, wantError: true},
+		"Unhappy Path: exponent":          {input: "1e3", currency: EUR, wantError: true},
+		"Unhappy Path: fraction":          {input: "1/3", currency: EUR, wantError: true},
+		"Unhappy Path: empty":             {input: "", currency: EUR, wantError: true},
+		"Unhappy Path: other currency":    {input: "1.00 USD", currency: EUR, wantError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseMoney(tc.input, tc.currency)
+			if tc.wantError {
+				if err == nil {
+					t.Errorf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Errorf("ParseMoney(%q) = %v, %v, want %v", tc.input, got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestRoundingModes(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		mode  RoundingMode
+		want  int64
+	}{
+		"half even down":     {input: "0.125", mode: RoundHalfEven, want: 12},
+		"half even up":       {input: "0.135", mode: RoundHalfEven, want: 14},
+		"half up":            {input: "0.125", mode: RoundHalfUp, want: 13},
+		"half up negative":   {input: "-0.125", mode: RoundHalfUp, want: -13},
+		"down":               {input: "0.129", mode: RoundDown, want: 12},
+		"down negative":      {input: "-0.129", mode: RoundDown, want: -12},
+		"up":                 {input: "0.121", mode: RoundUp, want: 13},
+		"up negative":        {input: "-0.121", mode: RoundUp, want: -13},
+		"exact is unchanged": {input: "0.12", mode: RoundUp, want: 12},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseMoneyRounded(tc.input, EUR, tc.mode)
+			if err != nil || got.Minor != tc.want {
+				t.Errorf("ParseMoneyRounded(%q) = %v, %v, want %d", tc.input, got.Minor, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	sum := NewMoney(0, EUR)
+	for range 10 {
+		var err error
+		sum, err = sum.Add(MustParseMoney("0.10", EUR))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sum != NewMoney(100, EUR) {
+		t.Errorf("10 x 0.10 = %v, want 1.00 EUR", sum)
+	}
+
+	if _, err := sum.Add(NewMoney(1, USD)); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("EUR + USD error = %v, want ErrCurrencyMismatch", err)
+	}
+
+	diff, err := NewMoney(100, EUR).Sub(NewMoney(250, ""))
+	if err != nil || diff != NewMoney(-150, EUR) {
+		t.Errorf("1.00 - 2.50 = %v, %v", diff, err)
+	}
+
+	if _, err := NewMoney(1<<62, EUR).Add(NewMoney(1<<62, EUR)); !errors.Is(err, ErrMoneyOverflow) {
+		t.Errorf("overflow error = %v, want ErrMoneyOverflow", err)
+	}
+
+	cmp, err := NewMoney(1, EUR).Cmp(NewMoney(2, EUR))
+	if err != nil || cmp != -1 {
+		t.Errorf("Cmp = %d, %v, want -1", cmp, err)
+	}
+
+	product, err := NewMoney(1000, EUR).Mul(big.NewRat(1, 3), RoundHalfEven)
+	if err != nil || product != NewMoney(333, EUR) {
+		t.Errorf("10.00 / 3 = %v, %v", product, err)
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	data, err := json.Marshal(NewMoney(-1050, EUR))
+	if err != nil || string(data) != `"-10.50 EUR"` {
+		t.Errorf("Marshal = %s, %v", data, err)
+	}
+
+	tests := map[string]struct {
+		input     string
+		want      Money
+		wantError bool
+	}{
+		"string with currency":     {input: `"10.50 EUR"`, want: NewMoney(1050, EUR)},
+		"string without currency":  {input: `"0.10"`, want: NewMoney(10, "")},
+		"legacy float":             {input: `0.30000000000000004`, want: NewMoney(30, "")},
+		"legacy exponent":          {input: `5.551115123125783e-17`, want: NewMoney(0, "")},
+		"null":                     {input: `null`, want: Money{}},
+		"Unhappy Path: bad string": {input: `"ten"`, wantError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got Money
+			err := json.Unmarshal([]byte(tc.input), &got)
+			if tc.wantError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Errorf("Unmarshal(%s) = %v, %v, want %v", tc.input, got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), dbFile)
+	legacy := NewJSONStore(path)
+
+	data := `[{"Id":"1","Name":"Alice","Balance":1000.1,"Overdraw":100,"AccountType":"giro",
+		"Transactions":[{"Time":"2025-09-26T08:49:41Z","Amount":0.1,"Type":"deposit"}]}]`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Migrate(legacy); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	acc, err := legacy.Get("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc.Balance != NewMoney(100010, EUR) || acc.Overdraw != NewMoney(10000, EUR) ||
+		acc.Transactions[0].Amount != NewMoney(10, EUR) {
+		t.Errorf("migrated account = %+v", acc)
+	}
+}
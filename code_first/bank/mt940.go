@@ -0,0 +1,194 @@
+package bank
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// mt940Field is a tag of an MT940 message with its content, continuation
+// lines joined with newlines.
+type mt940Field struct {
+	tag     string
+	content string
+	line    int
+}
+
+// mt940Entry matches the statement line :61:, the value date, optional
+// entry date, debit/credit mark with an optional funds code, amount, transaction
+// type and references.
+var mt940Entry = regexp.MustCompile(`^(\d{6})(\d{4})?(RC|RD|C|D)([A-Z])?(\d+,\d*)([NSF][A-Z0-9]{3})([^/\n]*)(?://([^\n]*))?`)
+
+// mt940Balance matches the balances :60F:, :60M:, :62F: and :62M:.
+var mt940Balance = regexp.MustCompile(`^([CD])(\d{6})([A-Z]{3})(\d+,\d*)`)
+
+// ParseMT940 reads the statement lines of SWIFT MT940 messages. The
+// currency comes from the opening balance of each statement, the reference
+// and counterparty from the :86: field that follows a line, structured
+// with ?20 to ?29 for the purpose and ?32 and ?33 for the name as German
+// banks do, or as free text.
+func ParseMT940(r io.Reader) ([]ImportedLine, []LineError, error) {
+	fields, err := readMT940Fields(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(fields) == 0 {
+		return nil, nil, fmt.Errorf("%w: no MT940 fields", ErrInvalidImport)
+	}
+
+	var lines []ImportedLine
+	var lineErrors []LineError
+	var currency Currency
+	var current *ImportedLine
+	flush := func() {
+		if current != nil {
+			lines = append(lines, *current)
+			current = nil
+		}
+	}
+
+	for _, field := range fields {
+		switch field.tag {
+		case "20":
+			flush()
+			currency = ""
+		case "60F", "60M":
+			flush()
+			m := mt940Balance.FindStringSubmatch(field.content)
+			if m == nil {
+				lineErrors = append(lineErrors, lineError(field.line, "invalid opening balance %q", field.content))
+				continue
+			}
+			if currency, err = ParseCurrency(m[3]); err != nil {
+				lineErrors = append(lineErrors, lineError(field.line, "%v", err))
+			}
+		case "61":
+			flush()
+			line, err := parseMT940Entry(field, currency)
+			if err != nil {
+				lineErrors = append(lineErrors, lineError(field.line, "%v", err))
+				continue
+			}
+			current = &line
+		case "86":
+			if current != nil {
+				counterparty, reference := parseMT940Details(field.content)
+				current.Counterparty = counterparty
+				if reference != "" {
+					current.Reference = reference
+				}
+				flush()
+			}
+		default:
+			flush()
+		}
+	}
+	flush()
+	return lines, lineErrors, nil
+}
+
+// readMT940Fields splits messages into their fields. SWIFT block headers and
+// the - that ends a message are skipped.
+func readMT940Fields(r io.Reader) ([]mt940Field, error) {
+	var fields []mt940Field
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		text := strings.TrimRight(scanner.Text(), "\r ")
+		switch {
+		case text == "" || text == "-" || text == "-}" || strings.HasPrefix(text, "{"):
+			continue
+		case strings.HasPrefix(text, ":"):
+			tag, content, ok := strings.Cut(text[1:], ":")
+			if !ok {
+				return nil, fmt.Errorf("%w: line %d: unterminated tag %q", ErrInvalidImport, n, text)
+			}
+			fields = append(fields, mt940Field{tag: tag, content: content, line: n})
+		case len(fields) > 0:
+			fields[len(fields)-1].content += "\n" + text
+		default:
+			return nil, fmt.Errorf("%w: line %d: expected a field tag", ErrInvalidImport, n)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+	}
+	return fields, nil
+}
+
+func parseMT940Entry(field mt940Field, currency Currency) (ImportedLine, error) {
+	m := mt940Entry.FindStringSubmatch(field.content)
+	if m == nil {
+		return ImportedLine{}, fmt.Errorf("invalid statement line %q", strings.SplitN(field.content, "\n", 2)[0])
+	}
+	if currency == "" {
+		return ImportedLine{}, fmt.Errorf("statement line before an opening balance")
+	}
+
+	valueDate, err := time.Parse("060102", m[1])
+	if err != nil {
+		return ImportedLine{}, fmt.Errorf("invalid value date %q", m[1])
+	}
+	booked := valueDate
+	// The entry date has no year, it is the value date's year unless that
+	// puts it more than half a year away, as around new year.
+	if m[2] != "" {
+		entry, err := time.Parse("0102", m[2])
+		if err != nil {
+			return ImportedLine{}, fmt.Errorf("invalid entry date %q", m[2])
+		}
+		booked = time.Date(valueDate.Year(), entry.Month(), entry.Day(), 0, 0, 0, 0, time.UTC)
+		switch {
+		case booked.Sub(valueDate) > 183*24*time.Hour:
+			booked = booked.AddDate(-1, 0, 0)
+		case valueDate.Sub(booked) > 183*24*time.Hour:
+			booked = booked.AddDate(1, 0, 0)
+		}
+	}
+
+	amount, err := ParseMoney(strings.Replace(strings.TrimSuffix(m[5], ","), ",", ".", 1), currency)
+	if err != nil {
+		return ImportedLine{}, err
+	}
+	// RC reverses a credit and takes money out, RD reverses a debit.
+	if m[3] == "D" || m[3] == "RC" {
+		amount = amount.Neg()
+	}
+
+	line := ImportedLine{Line: field.line, Time: booked, Amount: amount}
+	if ref := strings.TrimSpace(m[7]); ref != "" && ref != "NONREF" {
+		line.Reference = ref
+	}
+	return line, nil
+}
+
+// mt940Subfield matches the ?nn separators of a structured :86: field.
+var mt940Subfield = regexp.MustCompile(`\?(\d{2})`)
+
+// parseMT940Details returns the counterparty and reference of a :86:
+// field.
+func parseMT940Details(content string) (counterparty, reference string) {
+	if len(content) < 4 || content[3] != '?' {
+		return "", strings.Join(strings.Fields(content), " ")
+	}
+
+	content = strings.ReplaceAll(content, "\n", "")
+	locs := mt940Subfield.FindAllStringSubmatchIndex(content, -1)
+	var purpose, name strings.Builder
+	for i, loc := range locs {
+		end := len(content)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+		value := content[loc[1]:end]
+		switch code := content[loc[2]:loc[3]]; {
+		case code >= "20" && code <= "29", code >= "60" && code <= "63":
+			purpose.WriteString(value)
+		case code == "32" || code == "33":
+			name.WriteString(value)
+		}
+	}
+	return strings.TrimSpace(name.String()), strings.TrimSpace(purpose.String())
+}
@@ -0,0 +1,140 @@
+package bank
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// StatementBankID identifies this bank in OFX and camt.053 statements.
+const StatementBankID = "CODEFIRST"
+
+const ofxHeader = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>` + "\n" +
+	`<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>` + "\n"
+
+type ofxDocument struct {
+	XMLName xml.Name `xml:"OFX"`
+	SignOn  struct {
+		Response struct {
+			Status   ofxStatus `xml:"STATUS"`
+			Server   string    `xml:"DTSERVER"`
+			Language string    `xml:"LANGUAGE"`
+		} `xml:"SONRS"`
+	} `xml:"SIGNONMSGSRSV1"`
+	Bank struct {
+		Statement struct {
+			TransactionUID string    `xml:"TRNUID"`
+			Status         ofxStatus `xml:"STATUS"`
+			Response       struct {
+				Currency string `xml:"CURDEF"`
+				Account  struct {
+					BankID string `xml:"BANKID"`
+					ID     string `xml:"ACCTID"`
+					Type   string `xml:"ACCTTYPE"`
+				} `xml:"BANKACCTFROM"`
+				List struct {
+					Start        string           `xml:"DTSTART"`
+					End          string           `xml:"DTEND"`
+					Transactions []ofxTransaction `xml:"STMTTRN"`
+				} `xml:"BANKTRANLIST"`
+				Ledger ofxBalance `xml:"LEDGERBAL"`
+			} `xml:"STMTRS"`
+		} `xml:"STMTTRNRS"`
+	} `xml:"BANKMSGSRSV1"`
+}
+
+type ofxStatus struct {
+	Code     int    `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type ofxTransaction struct {
+	Type   string `xml:"TRNTYPE"`
+	Posted string `xml:"DTPOSTED"`
+	Amount string `xml:"TRNAMT"`
+	FITID  string `xml:"FITID"`
+	Name   string `xml:"NAME,omitempty"`
+	Memo   string `xml:"MEMO,omitempty"`
+}
+
+type ofxBalance struct {
+	Amount string `xml:"BALAMT"`
+	AsOf   string `xml:"DTASOF"`
+}
+
+// ofxTransactionTypes maps transaction types to OFX TRNTYPE values, others
+// are CREDIT or DEBIT by sign.
+var ofxTransactionTypes = map[TransactionType]string{
+	Deposit:  "DEP",
+	Transfer: "XFER",
+	Fee:      "FEE",
+	Interest: "INT",
+}
+
+func ofxTime(t time.Time) string {
+	return t.UTC().Format("20060102150405.000") + "[0:GMT]"
+}
+
+// WriteOFX writes the statement as an OFX 2.2 bank statement response.
+// OFX has no opening balance, the closing balance is the ledger balance.
+func (s *Statement) WriteOFX(w io.Writer) error {
+	var doc ofxDocument
+	doc.SignOn.Response.Status = ofxStatus{Severity: "INFO"}
+	doc.SignOn.Response.Server = ofxTime(s.Created)
+	doc.SignOn.Response.Language = "ENG"
+
+	statement := &doc.Bank.Statement
+	statement.TransactionUID = "0"
+	statement.Status = ofxStatus{Severity: "INFO"}
+
+	response := &statement.Response
+	response.Currency = s.Currency.Code()
+	response.Account.BankID = StatementBankID
+	response.Account.ID = s.AccountID
+	response.Account.Type = "CHECKING"
+	if s.AccountType == Savings {
+		response.Account.Type = "SAVINGS"
+	}
+	response.List.Start = ofxTime(s.From)
+	response.List.End = ofxTime(s.To)
+	for _, line := range s.Lines {
+		kind, ok := ofxTransactionTypes[line.Type]
+		switch {
+		case ok:
+		case line.Amount.IsNegative():
+			kind = "DEBIT"
+		default:
+			kind = "CREDIT"
+		}
+		response.List.Transactions = append(response.List.Transactions, ofxTransaction{
+			Type:   kind,
+			Posted: ofxTime(line.Time),
+			Amount: line.Amount.Decimal(),
+			FITID:  line.Id,
+			Name:   truncate(line.Counterparty, 32),
+			Memo:   truncate(line.Reference, 255),
+		})
+	}
+	response.Ledger = ofxBalance{Amount: s.Closing.Decimal(), AsOf: ofxTime(s.To)}
+
+	if _, err := io.WriteString(w, ofxHeader); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// truncate shortens s to at most n characters, as the formats limit the
+// length of free text.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
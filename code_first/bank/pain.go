@@ -0,0 +1,414 @@
+package bank
+
+import (
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const pain001Namespace = "urn:iso:std:iso:20022:tech:xsd:pain.001.001.09"
+
+var ErrInvalidStatusReport = errors.New("invalid payment status report")
+
+// pain001Document is an ISO 20022 pain.001 customer credit transfer
+// initiation with one payment information block per debtor account.
+type pain001Document struct {
+	XMLName   xml.Name         `xml:"Document"`
+	Namespace string           `xml:"xmlns,attr"`
+	Header    pain001Header    `xml:"CstmrCdtTrfInitn>GrpHdr"`
+	Payments  []pain001Payment `xml:"CstmrCdtTrfInitn>PmtInf"`
+}
+
+type pain001Header struct {
+	MessageID    string `xml:"MsgId"`
+	Created      string `xml:"CreDtTm"`
+	Transactions int    `xml:"NbOfTxs"`
+	ControlSum   string `xml:"CtrlSum"`
+	Initiator    string `xml:"InitgPty>Nm"`
+}
+
+type pain001Payment struct {
+	ID           string               `xml:"PmtInfId"`
+	Method       string               `xml:"PmtMtd"`
+	Transactions int                  `xml:"NbOfTxs"`
+	ControlSum   string               `xml:"CtrlSum"`
+	ServiceLevel string               `xml:"PmtTpInf>SvcLvl>Cd"`
+	Execution    string               `xml:"ReqdExctnDt>Dt"`
+	Debtor       string               `xml:"Dbtr>Nm"`
+	DebtorIBAN   string               `xml:"DbtrAcct>Id>IBAN"`
+	DebtorAgent  painAgent            `xml:"DbtrAgt"`
+	ChargeBearer string               `xml:"ChrgBr"`
+	Transfers    []pain001Transaction `xml:"CdtTrfTxInf"`
+}
+
+type painAgent struct {
+	BIC string `xml:"FinInstnId>BICFI"`
+}
+
+type pain001Transaction struct {
+	EndToEndID    string          `xml:"PmtId>EndToEndId"`
+	Amount        camtAmount      `xml:"Amt>InstdAmt"`
+	CreditorAgent *painAgent      `xml:"CdtrAgt,omitempty"`
+	Creditor      string          `xml:"Cdtr>Nm"`
+	CreditorIBAN  string          `xml:"CdtrAcct>Id>IBAN"`
+	Remittance    *camtRemittance `xml:"RmtInf,omitempty"`
+}
+
+// ExportPain001 submits every queued payment in one pain.001.001.09
+// message written to w and returns the submitted payments. The payments
+// are marked submitted before the message is written and queued again if
+// writing fails, so a payment is never handed over twice.
+func (s *PaymentStore) ExportPain001(w io.Writer) ([]Payment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var queued []Payment
+	for _, payment := range s.payments {
+		if payment.Status == PaymentQueued {
+			queued = append(queued, payment)
+		}
+	}
+	if len(queued) == 0 {
+		return nil, ErrNoPayments
+	}
+	sortPayments(queued)
+
+	now := s.Clock()
+	doc := pain001Document{
+		Namespace: pain001Namespace,
+		Header: pain001Header{
+			MessageID:    newID("pain"),
+			Created:      now.UTC().Format(time.RFC3339),
+			Transactions: len(queued),
+			Initiator:    StatementBankID,
+		},
+	}
+
+	total := Money{Currency: EUR}
+	sums := []Money{}
+	blocks := map[string]int{}
+	submitted := make([]Payment, len(queued))
+	for i, payment := range queued {
+		n, ok := blocks[payment.AccountID]
+		if !ok {
+			n = len(doc.Payments)
+			blocks[payment.AccountID] = n
+			doc.Payments = append(doc.Payments, pain001Payment{
+				ID:           doc.Header.MessageID + "-" + strconv.Itoa(n+1),
+				Method:       "TRF",
+				ServiceLevel: "SEPA",
+				Execution:    now.UTC().Format(time.DateOnly),
+				Debtor:       truncate(payment.Debtor.Name, 70),
+				DebtorIBAN:   payment.Debtor.IBAN.String(),
+				DebtorAgent:  painAgent{BIC: payment.Debtor.BIC},
+				ChargeBearer: "SLEV",
+			})
+		}
+		block := &doc.Payments[n]
+		if n == len(sums) {
+			sums = append(sums, Money{Currency: EUR})
+		}
+
+		transfer := pain001Transaction{
+			EndToEndID:   payment.Id,
+			Amount:       camtAmount{Currency: payment.Amount.Currency.Code(), Value: payment.Amount.Decimal()},
+			Creditor:     truncate(cmp.Or(payment.Creditor.Name, "NOTPROVIDED"), 70),
+			CreditorIBAN: payment.Creditor.IBAN.String(),
+		}
+		if payment.Creditor.BIC != "" {
+			transfer.CreditorAgent = &painAgent{BIC: payment.Creditor.BIC}
+		}
+		if payment.Reference != "" {
+			transfer.Remittance = &camtRemittance{Unstructured: []string{truncate(payment.Reference, 140)}}
+		}
+		block.Transfers = append(block.Transfers, transfer)
+		block.Transactions++
+
+		var err error
+		if total, err = total.Add(payment.Amount); err != nil {
+			return nil, err
+		}
+		if sums[n], err = sums[n].Add(payment.Amount); err != nil {
+			return nil, err
+		}
+		payment.Status = PaymentSubmitted
+		payment.MessageID = doc.Header.MessageID
+		payment.PaymentInfoID = block.ID
+		submitted[i] = payment
+	}
+
+	doc.Header.ControlSum = total.Decimal()
+	for i := range doc.Payments {
+		doc.Payments[i].ControlSum = sums[i].Decimal()
+	}
+
+	if err := s.putAll(submitted); err != nil {
+		return nil, err
+	}
+	if err := writePain001(w, doc); err != nil {
+		return nil, errors.Join(err, s.putAll(queued))
+	}
+	return submitted, nil
+}
+
+func writePain001(w io.Writer, doc pain001Document) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// pain002Document is the part of an ISO 20022 pain.002 payment status
+// report this bank reads. The layout is the same in every version.
+type pain002Document struct {
+	XMLName  xml.Name             `xml:"Document"`
+	Group    pain002Status        `xml:"CstmrPmtStsRpt>OrgnlGrpInfAndSts"`
+	Payments []pain002PaymentInfo `xml:"CstmrPmtStsRpt>OrgnlPmtInfAndSts"`
+}
+
+type pain002Status struct {
+	MessageID string       `xml:"OrgnlMsgId"`
+	Status    string       `xml:"GrpSts"`
+	Reasons   []painReason `xml:"StsRsnInf"`
+}
+
+type pain002PaymentInfo struct {
+	ID           string               `xml:"OrgnlPmtInfId"`
+	Status       string               `xml:"PmtInfSts"`
+	Reasons      []painReason         `xml:"StsRsnInf"`
+	Transactions []pain002Transaction `xml:"TxInfAndSts"`
+}
+
+type pain002Transaction struct {
+	EndToEndID string       `xml:"OrgnlEndToEndId"`
+	Status     string       `xml:"TxSts"`
+	Reasons    []painReason `xml:"StsRsnInf"`
+}
+
+type painReason struct {
+	Code        string `xml:"Rsn>Cd"`
+	Proprietary string `xml:"Rsn>Prtry"`
+}
+
+// painStatus is a status with its reason code, reported for a group,
+// payment information block or transaction.
+type painStatus struct {
+	Code   string
+	Reason string
+}
+
+func newPainStatus(code string, reasons []painReason) painStatus {
+	status := painStatus{Code: strings.ToUpper(strings.TrimSpace(code))}
+	for _, reason := range reasons {
+		if status.Reason = strings.TrimSpace(cmp.Or(reason.Code, reason.Proprietary)); status.Reason != "" {
+			break
+		}
+	}
+	return status
+}
+
+// or prefers the more specific status s to the one it is part of.
+func (s painStatus) or(outer painStatus) painStatus {
+	if s.Code == "" {
+		return outer
+	}
+	return s
+}
+
+// paymentStatus maps the ISO 20022 status code onto the payment. Pending,
+// received and partially accepted statuses leave the payment submitted.
+func (s painStatus) paymentStatus() (PaymentStatus, bool) {
+	switch s.Code {
+	case "RJCT":
+		return PaymentRejected, true
+	case "ACCP", "ACSP", "ACSC", "ACTC", "ACWC", "ACCC":
+		return PaymentAccepted, true
+	}
+	return "", false
+}
+
+// StatusReport is the outcome of a pain.002 status report. Unknown lists
+// the end-to-end ids the report names that were not submitted with the
+// message.
+type StatusReport struct {
+	MessageID string
+	Accepted  []Payment
+	Rejected  []Payment
+	Unknown   []string
+}
+
+// parsePain002 reads the statuses of a pain.002 message of any version.
+// Transactions are keyed "tx:" plus their end-to-end id, payment
+// information blocks "pmtinf:" plus their id and the message itself by the
+// empty string. A transaction or block without a status of its own takes
+// the one it is part of.
+func parsePain002(r io.Reader) (string, map[string]painStatus, error) {
+	var doc pain002Document
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return "", nil, fmt.Errorf("%w: %w", ErrInvalidStatusReport, err)
+	}
+	if !strings.HasPrefix(doc.XMLName.Space, "urn:iso:std:iso:20022:tech:xsd:pain.002.") {
+		return "", nil, fmt.Errorf("%w: not a pain.002 message but %q", ErrInvalidStatusReport, doc.XMLName.Space)
+	}
+	messageID := strings.TrimSpace(doc.Group.MessageID)
+	if messageID == "" {
+		return "", nil, fmt.Errorf("%w: no original message id", ErrInvalidStatusReport)
+	}
+
+	group := newPainStatus(doc.Group.Status, doc.Group.Reasons)
+	statuses := map[string]painStatus{"": group}
+	for _, info := range doc.Payments {
+		block := newPainStatus(info.Status, info.Reasons).or(group)
+		if id := strings.TrimSpace(info.ID); id != "" {
+			statuses["pmtinf:"+id] = block
+		}
+		for _, tx := range info.Transactions {
+			id := strings.TrimSpace(tx.EndToEndID)
+			if id == "" {
+				return "", nil, fmt.Errorf("%w: transaction status without end-to-end id", ErrInvalidStatusReport)
+			}
+			statuses["tx:"+id] = newPainStatus(tx.Status, tx.Reasons).or(block)
+		}
+	}
+	return messageID, statuses, nil
+}
+
+// ApplyStatusReport settles the payments of the pain.001 message a pain.002
+// status report answers. Accepted payments stay in the clearing account,
+// rejected ones are paid back to the debtor account by reversing their
+// booking; the transfer fee is kept. Reading a report again is harmless,
+// and retries refunds that failed before.
+func (s *PaymentStore) ApplyStatusReport(r io.Reader) (*StatusReport, error) {
+	messageID, statuses, err := parsePain002(r)
+	if err != nil {
+		return nil, err
+	}
+
+	report, refunds, err := s.applyStatuses(messageID, statuses)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refunds book on the accounts, so they run without the store lock.
+	var errs []error
+	for i, payment := range refunds {
+		refundID, err := refund(payment)
+		if err == nil {
+			payment.RefundID = refundID
+			err = s.add(payment)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("refunding payment %s: %w", payment.Id, err))
+			continue
+		}
+		refunds[i] = payment
+	}
+	for i, payment := range report.Rejected {
+		for _, refunded := range refunds {
+			if refunded.Id == payment.Id {
+				report.Rejected[i] = refunded
+			}
+		}
+	}
+	return report, errors.Join(errs...)
+}
+
+func (s *PaymentStore) applyStatuses(messageID string, statuses map[string]painStatus) (*StatusReport, []Payment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := &StatusReport{MessageID: messageID, Accepted: []Payment{}, Rejected: []Payment{}, Unknown: []string{}}
+	var message []Payment
+	known := map[string]bool{}
+	for _, payment := range s.payments {
+		if payment.MessageID == messageID {
+			message = append(message, payment)
+			known["tx:"+payment.Id] = true
+		}
+	}
+	if len(message) == 0 {
+		return nil, nil, fmt.Errorf("%w: no payments were submitted with message %s", ErrPaymentNotFound, messageID)
+	}
+	sortPayments(message)
+	for key := range statuses {
+		if id, ok := strings.CutPrefix(key, "tx:"); ok && !known[key] {
+			report.Unknown = append(report.Unknown, id)
+		}
+	}
+	slices.Sort(report.Unknown)
+
+	var changed, refunds []Payment
+	for _, payment := range message {
+		status, ok := statuses["tx:"+payment.Id]
+		if !ok {
+			status, ok = statuses["pmtinf:"+payment.PaymentInfoID]
+		}
+		if !ok {
+			status = statuses[""]
+		}
+
+		switch payment.Status {
+		case PaymentSubmitted:
+			next, ok := status.paymentStatus()
+			if !ok {
+				continue
+			}
+			payment.Status = next
+			if next == PaymentRejected {
+				payment.Reason = status.Reason
+			}
+			changed = append(changed, payment)
+		case PaymentRejected:
+			// A refund that failed before is retried.
+			if payment.RefundID != "" {
+				continue
+			}
+		default:
+			continue
+		}
+
+		if payment.Status == PaymentAccepted {
+			report.Accepted = append(report.Accepted, payment)
+		} else {
+			report.Rejected = append(report.Rejected, payment)
+			refunds = append(refunds, payment)
+		}
+	}
+
+	if err := s.putAll(changed); err != nil {
+		return nil, nil, err
+	}
+	return report, refunds, nil
+}
+
+// refund reverses the booking of a rejected payment. A booking reversed
+// before, for example by hand after the rejection, counts as refunded by
+// that reversal.
+func refund(payment Payment) (string, error) {
+	reference := "SEPA payment rejected"
+	if payment.Reason != "" {
+		reference += ": " + payment.Reason
+	}
+	refundID, err := Reverse(payment.Id, reference)
+	if !errors.Is(err, ErrAlreadyReversed) {
+		return refundID, err
+	}
+
+	bookings, findErr := FindTransaction(payment.Id)
+	if findErr != nil {
+		return "", errors.Join(err, findErr)
+	}
+	return bookings[0].ReversedBy, nil
+}
@@ -0,0 +1,172 @@
+package bank
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type AccountStatus string
+
+const (
+	StatusActive AccountStatus = "active"
+	StatusFrozen AccountStatus = "frozen"
+	StatusClosed AccountStatus = "closed"
+)
+
+var (
+	ErrNonPositiveAmount = errors.New("amount should be larger than 0")
+	ErrSelfTransfer      = errors.New("cannot transfer to the same account")
+	ErrRecipientNotFound = errors.New("could not find recipient")
+	ErrAccountFrozen     = errors.New("account is frozen")
+	ErrAccountClosed     = errors.New("account is closed")
+)
+
+// status returns the status of the account. Accounts stored before statuses
+// existed are active.
+func (account *Account) status() AccountStatus {
+	if account.Status == "" {
+		return StatusActive
+	}
+	return account.Status
+}
+
+// SetAccountStatus freezes, closes or reactivates the account. Frozen and
+// closed accounts can neither send nor receive money, bank fees and
+// reversals still book on them.
+func SetAccountStatus(accountID string, status AccountStatus) error {
+	switch status {
+	case StatusActive, StatusFrozen, StatusClosed:
+	default:
+		return fmt.Errorf("unknown account status %q", status)
+	}
+
+	account, err := store.Get(accountID)
+	if err != nil {
+		return err
+	}
+
+	return update(func() error {
+		if account.status() == StatusClosed && status != StatusClosed {
+			return fmt.Errorf("%w: %s cannot be reopened", ErrAccountClosed, account.Id)
+		}
+		if err := account.record(Event{Type: StatusChanged, Status: status}); err != nil {
+			return err
+		}
+		return account.save()
+	}, account)
+}
+
+// checkActive is the rule every customer booking has to pass on each account
+// it touches.
+func (account *Account) checkActive() error {
+	switch account.status() {
+	case StatusFrozen:
+		return fmt.Errorf("%w: %s", ErrAccountFrozen, account.Id)
+	case StatusClosed:
+		return fmt.Errorf("%w: %s", ErrAccountClosed, account.Id)
+	}
+	return nil
+}
+
+// checkDebit decides whether amount plus fee may leave the account. Giro
+// accounts may take their base currency down to the overdraft limit, all
+// other balances only down to zero. It returns the amount with its currency
+// filled in.
+func (account *Account) checkDebit(amount, fee Money) (Money, error) {
+	if err := account.checkActive(); err != nil {
+		return Money{}, err
+	}
+
+	amount, err := amount.InCurrency(account.BaseCurrency())
+	if err != nil {
+		return Money{}, err
+	}
+	if err := account.covers(amount, fee.WithCurrency(account.BaseCurrency())); err != nil {
+		return Money{}, err
+	}
+	return amount, nil
+}
+
+// covers reports ErrInsufficientFunds if the account cannot pay all debits.
+func (account *Account) covers(debits ...Money) error {
+	totals := map[Currency]Money{}
+	for _, debit := range debits {
+		if debit.IsZero() {
+			continue
+		}
+		total, err := totals[debit.Currency].WithCurrency(debit.Currency).Add(debit)
+		if err != nil {
+			return err
+		}
+		totals[debit.Currency] = total
+	}
+
+	for currency, total := range totals {
+		balance, err := account.BalanceIn(currency).Sub(total)
+		if err != nil {
+			return err
+		}
+		if balance.Minor < account.limitIn(currency) {
+			return fmt.Errorf("%w on %s", ErrInsufficientFunds, account.Id)
+		}
+	}
+	return nil
+}
+
+// checkCredit decides whether amount may be booked onto the account and
+// returns it with its currency filled in.
+func (account *Account) checkCredit(amount Money) (Money, error) {
+	if err := account.checkActive(); err != nil {
+		return Money{}, err
+	}
+
+	amount, err := amount.InCurrency(account.BaseCurrency())
+	if err != nil {
+		return Money{}, err
+	}
+	if _, err := account.BalanceIn(amount.Currency).Add(amount); err != nil {
+		return Money{}, err
+	}
+	return amount, nil
+}
+
+// checkAmount rejects amounts that are zero or negative.
+func checkAmount(amount Money) error {
+	if !amount.IsPositive() {
+		return ErrNonPositiveAmount
+	}
+	return checkCurrency(amount.Currency)
+}
+
+// findRecipient looks up the account a transfer goes to, by IBAN, or else
+// by id and then by name, as customers know their payees by any of them.
+// Anything that parses as an IBAN is only ever resolved as one.
+func findRecipient(to string) (*Account, error) {
+	to = strings.TrimSpace(to)
+	if to == "" {
+		return nil, fmt.Errorf("%w: no recipient given", ErrRecipientNotFound)
+	}
+
+	if iban, err := ParseIBAN(to); err == nil {
+		account, err := findByIBAN(iban)
+		if errors.Is(err, ErrAccountNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrRecipientNotFound, iban)
+		}
+		return account, err
+	}
+
+	account, err := store.Get(to)
+	if err == nil {
+		return account, nil
+	}
+	if !errors.Is(err, ErrAccountNotFound) {
+		return nil, err
+	}
+
+	account, err = store.FindByName(to)
+	if errors.Is(err, ErrAccountNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrRecipientNotFound, to)
+	}
+	return account, err
+}
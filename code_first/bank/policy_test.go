@@ -0,0 +1,137 @@
+package bank
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTransferPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		from      string
+		to        string
+		amount    float64
+		status    map[string]AccountStatus
+		wantErr   error
+		wantFrom  float64
+		wantTo    float64
+		recipient string
+	}{
+		{name: "giro uses overdraft", from: "giro", to: "Savings", amount: 150, wantFrom: -50, wantTo: 150, recipient: "savings"},
+		{name: "giro beyond overdraft", from: "giro", to: "Savings", amount: 250, wantErr: ErrInsufficientFunds, wantFrom: 100, recipient: "savings"},
+		{name: "savings cannot go negative", from: "savings", to: "Giro", amount: 1, wantErr: ErrInsufficientFunds, wantFrom: 0, wantTo: 100, recipient: "giro"},
+		{name: "recipient by id", from: "giro", to: "savings", amount: 10, wantFrom: 90, wantTo: 10, recipient: "savings"},
+		{name: "recipient by name ignores case", from: "giro", to: "sAvInGs", amount: 10, wantFrom: 90, wantTo: 10, recipient: "savings"},
+		{name: "unknown recipient", from: "giro", to: "Nobody", amount: 10, wantErr: ErrRecipientNotFound, wantFrom: 100},
+		{name: "empty recipient", from: "giro", to: " ", amount: 10, wantErr: ErrRecipientNotFound, wantFrom: 100},
+		{name: "self transfer by id", from: "giro", to: "giro", amount: 10, wantErr: ErrSelfTransfer, wantFrom: 100},
+		{name: "self transfer by name", from: "giro", to: "Giro", amount: 10, wantErr: ErrSelfTransfer, wantFrom: 100},
+		{name: "zero amount", from: "giro", to: "Savings", amount: 0, wantErr: ErrNonPositiveAmount, wantFrom: 100, recipient: "savings"},
+		{name: "frozen recipient", from: "giro", to: "Savings", amount: 10, status: map[string]AccountStatus{"savings": StatusFrozen}, wantErr: ErrAccountFrozen, wantFrom: 100, recipient: "savings"},
+		{name: "closed recipient", from: "giro", to: "Savings", amount: 10, status: map[string]AccountStatus{"savings": StatusClosed}, wantErr: ErrAccountClosed, wantFrom: 100, recipient: "savings"},
+		{name: "frozen sender", from: "giro", to: "Savings", amount: 10, status: map[string]AccountStatus{"giro": StatusFrozen}, wantErr: ErrAccountFrozen, wantFrom: 100, recipient: "savings"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			useStore(t)
+			useBooks(t)
+			openTestAccounts(t,
+				&Account{Id: "giro", Name: "Giro", Balance: eur(100), Overdraw: eur(100), AccountType: Giro},
+				&Account{Id: "savings", Name: "Savings", Balance: eur(0), AccountType: Savings},
+			)
+			for id, status := range tc.status {
+				if err := SetAccountStatus(id, status); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			from, err := Store().Get(tc.from)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = from.Transfer(eur(tc.amount), tc.to)
+			if tc.wantErr == nil && err != nil {
+				t.Fatalf("Transfer() error = %v", err)
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Fatalf("Transfer() error = %v, want %v", err, tc.wantErr)
+			}
+
+			if got := balanceOf(t, tc.from); got != eur(tc.wantFrom) {
+				t.Errorf("sender balance = %v, want %.2f", got, tc.wantFrom)
+			}
+			if tc.recipient != "" {
+				if got := balanceOf(t, tc.recipient); got != eur(tc.wantTo) {
+					t.Errorf("recipient balance = %v, want %.2f", got, tc.wantTo)
+				}
+			}
+		})
+	}
+}
+
+func TestWithdrawPolicy(t *testing.T) {
+	useStore(t)
+	useBooks(t)
+	openTestAccounts(t, &Account{Id: "giro", Name: "Giro", Balance: eur(100), Overdraw: eur(100), AccountType: Giro})
+
+	acc, err := Store().Get("giro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := acc.Withdraw(eur(-1)); !errors.Is(err, ErrNonPositiveAmount) {
+		t.Errorf("negative withdrawal: got %v, want %v", err, ErrNonPositiveAmount)
+	}
+	if err := acc.Withdraw(eur(201)); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("withdrawal beyond overdraft: got %v, want %v", err, ErrInsufficientFunds)
+	}
+
+	if err := SetAccountStatus("giro", StatusFrozen); err != nil {
+		t.Fatal(err)
+	}
+	if err := acc.Withdraw(eur(10)); !errors.Is(err, ErrAccountFrozen) {
+		t.Errorf("withdrawal from frozen account: got %v, want %v", err, ErrAccountFrozen)
+	}
+	if err := acc.Deposit(eur(10)); !errors.Is(err, ErrAccountFrozen) {
+		t.Errorf("deposit to frozen account: got %v, want %v", err, ErrAccountFrozen)
+	}
+	if err := acc.ChargeFee(eur(1), "fee", date(2026, 1, 1, 0, 0)); err != nil {
+		t.Errorf("fee on frozen account: %v", err)
+	}
+
+	if err := SetAccountStatus("giro", StatusActive); err != nil {
+		t.Fatal(err)
+	}
+	if err := acc.Withdraw(eur(199)); err != nil {
+		t.Errorf("withdrawal after unfreezing: %v", err)
+	}
+}
+
+func TestAccountStatus(t *testing.T) {
+	dir := t.TempDir()
+	useEventStore(t, dir)
+	useBooks(t)
+	openTestAccounts(t, &Account{Id: "acc", Name: "Acc", Balance: eur(10), AccountType: Savings})
+
+	if err := SetAccountStatus("acc", "dormant"); err == nil {
+		t.Error("unknown status was accepted")
+	}
+	if err := SetAccountStatus("acc", StatusClosed); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetAccountStatus("acc", StatusActive); !errors.Is(err, ErrAccountClosed) {
+		t.Errorf("reopening: got %v, want %v", err, ErrAccountClosed)
+	}
+
+	replayed, err := NewEventStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc, err := replayed.Get("acc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc.Status != StatusClosed || acc.Balance != eur(10) {
+		t.Errorf("replayed account = %+v, want closed with balance 10", acc)
+	}
+}
@@ -0,0 +1,415 @@
+package bank
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidQuery = errors.New("invalid transaction query")
+
+// TransactionFilter is a node of a parsed transaction query.
+type TransactionFilter interface {
+	Match(txn *Transactions) bool
+}
+
+// AllOf matches transactions that match every filter, an empty AllOf
+// matches everything.
+type AllOf []TransactionFilter
+
+func (f AllOf) Match(txn *Transactions) bool {
+	for _, filter := range f {
+		if !filter.Match(txn) {
+			return false
+		}
+	}
+	return true
+}
+
+// TimeRange matches transactions booked from From up to but excluding To.
+// A zero bound is open.
+type TimeRange struct {
+	From time.Time
+	To   time.Time
+}
+
+func (f TimeRange) Match(txn *Transactions) bool {
+	return (f.From.IsZero() || !txn.Time.Before(f.From)) && (f.To.IsZero() || txn.Time.Before(f.To))
+}
+
+// AmountRange matches amounts between Min and Max inclusive. A nil bound
+// is open. Bounds without a currency compare by value in any currency,
+// bounds with one only match amounts in that currency.
+type AmountRange struct {
+	Min *Money
+	Max *Money
+}
+
+func (f AmountRange) Match(txn *Transactions) bool {
+	if f.Min != nil {
+		if c, ok := compareAmount(txn.Amount, *f.Min); !ok || c < 0 {
+			return false
+		}
+	}
+	if f.Max != nil {
+		if c, ok := compareAmount(txn.Amount, *f.Max); !ok || c > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// compareAmount compares two amounts by value if either has no currency,
+// amounts in different currencies do not compare.
+func compareAmount(a, b Money) (int, bool) {
+	switch {
+	case a.Currency == b.Currency:
+		return cmp.Compare(a.Minor, b.Minor), true
+	case a.Currency == "" || b.Currency == "":
+		return compareValue(a, b), true
+	default:
+		return 0, false
+	}
+}
+
+// compareValue compares amounts with different minor units by scaling the
+// coarser one, falling back to big.Rat if that overflows.
+func compareValue(a, b Money) int {
+	x, y := a.Minor, b.Minor
+	ua, ub := a.Currency.MinorUnits(), b.Currency.MinorUnits()
+	for ; ua < ub; ua++ {
+		if x > math.MaxInt64/10 || x < math.MinInt64/10 {
+			return a.Rat().Cmp(b.Rat())
+		}
+		x *= 10
+	}
+	for ; ub < ua; ub++ {
+		if y > math.MaxInt64/10 || y < math.MinInt64/10 {
+			return a.Rat().Cmp(b.Rat())
+		}
+		y *= 10
+	}
+	return cmp.Compare(x, y)
+}
+
+// CurrencyIs matches amounts in one currency.
+type CurrencyIs Currency
+
+func (f CurrencyIs) Match(txn *Transactions) bool {
+	return txn.Amount.Currency == Currency(f)
+}
+
+// TypeIn matches any of the listed transaction types.
+type TypeIn []TransactionType
+
+func (f TypeIn) Match(txn *Transactions) bool {
+	return slices.Contains(f, txn.Type)
+}
+
+// TextField names the text a TextContains searches.
+type TextField string
+
+const (
+	CounterpartyField TextField = "counterparty"
+	ReferenceField    TextField = "reference"
+	AnyTextField      TextField = "q"
+)
+
+// TextContains matches transactions whose Field contains Text, ignoring
+// case. Text must already be lower case.
+type TextContains struct {
+	Field TextField
+	Text  string
+}
+
+func (f TextContains) Match(txn *Transactions) bool {
+	switch f.Field {
+	case CounterpartyField:
+		return containsFold(txn.Counterparty, f.Text)
+	case ReferenceField:
+		return containsFold(txn.Reference, f.Text)
+	default:
+		return containsFold(txn.Counterparty, f.Text) || containsFold(txn.Reference, f.Text)
+	}
+}
+
+func containsFold(s, lower string) bool {
+	return strings.Contains(strings.ToLower(s), lower)
+}
+
+// DatePart matches transactions booked on a day of the month, in a month
+// or in a year, the equality filters of the criteria parameter.
+type DatePart struct {
+	Part  string
+	Value int
+}
+
+func (f DatePart) Match(txn *Transactions) bool {
+	switch f.Part {
+	case "day":
+		return txn.Time.Day() == f.Value
+	case "month":
+		return int(txn.Time.Month()) == f.Value
+	default:
+		return txn.Time.Year() == f.Value
+	}
+}
+
+// MatchNone stands for a criteria filter that cannot match anything.
+type MatchNone struct{}
+
+func (MatchNone) Match(*Transactions) bool {
+	return false
+}
+
+// SortKey orders results by Field, one of time, amount, type, counterparty
+// or reference. Amounts sort by currency first.
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// MaxQueryLimit is the largest page ParseTransactionQuery accepts.
+const MaxQueryLimit = 1000
+
+// TransactionQuery selects, orders and pages transactions. A zero Limit
+// returns everything after Offset.
+type TransactionQuery struct {
+	Filter TransactionFilter
+	Sort   []SortKey
+	Offset int
+	Limit  int
+}
+
+var sortFields = map[string]func(a, b *Transactions) int{
+	"time": func(a, b *Transactions) int { return a.Time.Compare(b.Time) },
+	"amount": func(a, b *Transactions) int {
+		return cmp.Or(strings.Compare(string(a.Amount.Currency), string(b.Amount.Currency)), cmp.Compare(a.Amount.Minor, b.Amount.Minor))
+	},
+	"type":         func(a, b *Transactions) int { return strings.Compare(string(a.Type), string(b.Type)) },
+	"counterparty": func(a, b *Transactions) int { return strings.Compare(a.Counterparty, b.Counterparty) },
+	"reference":    func(a, b *Transactions) int { return strings.Compare(a.Reference, b.Reference) },
+}
+
+// ParseTransactionQuery reads a query from URL parameters:
+//
+//	from, to                   date (YYYY-MM-DD, to is inclusive) or RFC 3339 time
+//	min_amount, max_amount     amount, optionally with currency code
+//	currency                   currency of the amount
+//	type                       comma separated or repeated transaction types
+//	counterparty, reference, q case-insensitive text search, q searches both
+//	criteria, filter           a single equality filter on type, amount, day, month or year
+//	sort                       comma separated fields, - in front sorts descending
+//	limit, offset              paging, limit at most MaxQueryLimit
+//
+// Parameters combine with AND, other parameters are ignored.
+func ParseTransactionQuery(values url.Values) (TransactionQuery, error) {
+	var query TransactionQuery
+	var filters AllOf
+
+	var span TimeRange
+	var err error
+	if span.From, err = parseQueryTime(values.Get("from"), false); err != nil {
+		return query, err
+	}
+	if span.To, err = parseQueryTime(values.Get("to"), true); err != nil {
+		return query, err
+	}
+	if !span.From.IsZero() && !span.To.IsZero() && !span.From.Before(span.To) {
+		return query, fmt.Errorf("%w: from is after to", ErrInvalidQuery)
+	}
+	if span != (TimeRange{}) {
+		filters = append(filters, span)
+	}
+
+	var amounts AmountRange
+	if amounts.Min, err = parseQueryAmount(values.Get("min_amount")); err != nil {
+		return query, err
+	}
+	if amounts.Max, err = parseQueryAmount(values.Get("max_amount")); err != nil {
+		return query, err
+	}
+	if amounts.Min != nil && amounts.Max != nil {
+		if c, ok := compareAmount(*amounts.Min, *amounts.Max); !ok || c > 0 {
+			return query, fmt.Errorf("%w: min_amount is above max_amount", ErrInvalidQuery)
+		}
+	}
+	if amounts != (AmountRange{}) {
+		filters = append(filters, amounts)
+	}
+
+	if code := values.Get("currency"); code != "" {
+		currency, err := ParseCurrency(code)
+		if err != nil {
+			return query, fmt.Errorf("%w: %w", ErrInvalidQuery, err)
+		}
+		filters = append(filters, CurrencyIs(currency))
+	}
+
+	var types TypeIn
+	for _, value := range values["type"] {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				types = append(types, TransactionType(name))
+			}
+		}
+	}
+	if len(types) > 0 {
+		filters = append(filters, types)
+	}
+
+	for _, field := range []TextField{CounterpartyField, ReferenceField, AnyTextField} {
+		if text := strings.TrimSpace(values.Get(string(field))); text != "" {
+			filters = append(filters, TextContains{Field: field, Text: strings.ToLower(text)})
+		}
+	}
+
+	if criteria := values.Get("criteria"); criteria != "" {
+		filters = append(filters, criteriaFilter(criteria, values.Get("filter")))
+	}
+
+	if query.Sort, err = parseSort(values.Get("sort")); err != nil {
+		return query, err
+	}
+	if query.Limit, err = parseQueryInt(values, "limit"); err != nil {
+		return query, err
+	}
+	if query.Limit > MaxQueryLimit {
+		return query, fmt.Errorf("%w: limit must be at most %d", ErrInvalidQuery, MaxQueryLimit)
+	}
+	if query.Offset, err = parseQueryInt(values, "offset"); err != nil {
+		return query, err
+	}
+
+	query.Filter = filters
+	return query, nil
+}
+
+// criteriaFilter keeps the single criteria and filter pair of the first
+// version of the API, a filter that does not parse matches nothing.
+func criteriaFilter(criteria, filter string) TransactionFilter {
+	switch criteria = strings.ToLower(criteria); criteria {
+	case "type":
+		return TypeIn{TransactionType(filter)}
+	case "amount":
+		amount, err := ParseMoney(filter, "")
+		if err != nil {
+			return MatchNone{}
+		}
+		return AmountRange{Min: &amount, Max: &amount}
+	case "day", "month", "year":
+		value, err := strconv.Atoi(filter)
+		if err != nil {
+			return MatchNone{}
+		}
+		return DatePart{Part: criteria, Value: value}
+	default:
+		return MatchNone{}
+	}
+}
+
+func parseQueryTime(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if day, err := time.Parse(time.DateOnly, value); err == nil {
+		if end {
+			day = day.AddDate(0, 0, 1)
+		}
+		return day, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q is neither YYYY-MM-DD nor an RFC 3339 time", ErrInvalidQuery, value)
+	}
+	return t, nil
+}
+
+func parseQueryAmount(value string) (*Money, error) {
+	if value == "" {
+		return nil, nil
+	}
+	amount, err := ParseMoney(value, "")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidQuery, err)
+	}
+	return &amount, nil
+}
+
+func parseQueryInt(values url.Values, name string) (int, error) {
+	value := values.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w: %s must be a non-negative number", ErrInvalidQuery, name)
+	}
+	return n, nil
+}
+
+func parseSort(value string) ([]SortKey, error) {
+	var keys []SortKey
+	for _, field := range strings.Split(value, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if field == "" {
+			continue
+		}
+		key := SortKey{Field: strings.TrimPrefix(field, "-"), Desc: strings.HasPrefix(field, "-")}
+		if _, ok := sortFields[key.Field]; !ok {
+			return nil, fmt.Errorf("%w: cannot sort by %q", ErrInvalidQuery, key.Field)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Run filters transactions in one pass, then sorts and pages the matches.
+// It returns the page and the number of matches before paging.
+func (q TransactionQuery) Run(transactions []Transactions) ([]Transactions, int) {
+	matches := []Transactions{}
+	for i := range transactions {
+		if q.Filter == nil || q.Filter.Match(&transactions[i]) {
+			matches = append(matches, transactions[i])
+		}
+	}
+	total := len(matches)
+
+	if len(q.Sort) > 0 {
+		slices.SortStableFunc(matches, func(a, b Transactions) int {
+			for _, key := range q.Sort {
+				c := sortFields[key.Field](&a, &b)
+				if key.Desc {
+					c = -c
+				}
+				if c != 0 {
+					return c
+				}
+			}
+			return 0
+		})
+	}
+
+	start := min(q.Offset, total)
+	end := total
+	if q.Limit > 0 {
+		end = start + min(q.Limit, total-start)
+	}
+	return matches[start:end], total
+}
+
+// QueryTransactions runs query over the account's history.
+func (account *Account) QueryTransactions(query TransactionQuery) ([]Transactions, int, error) {
+	acc, err := account.Snapshot()
+	if err != nil {
+		return nil, 0, err
+	}
+	page, total := query.Run(acc.Transactions)
+	return page, total, nil
+}
@@ -0,0 +1,140 @@
+package bank
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+)
+
+func queryHistory() []Transactions {
+	return []Transactions{
+		{Id: "t1", Time: date(2026, 1, 5, 9, 0), Amount: eur(100), Type: Deposit, Reference: "Salary January"},
+		{Id: "t2", Time: date(2026, 1, 20, 12, 0), Amount: eur(25.5), Type: Transfer, Counterparty: "bob", Reference: "Dinner"},
+		{Id: "t3", Time: date(2026, 2, 1, 8, 30), Amount: eur(40), Type: Withdraw},
+		{Id: "t4", Time: date(2026, 2, 5, 9, 0), Amount: NewMoney(5000, USD), Type: Exchange, Reference: "trip"},
+		{Id: "t5", Time: date(2026, 2, 28, 23, 59), Amount: eur(2), Type: Fee, Reference: "maintenance"},
+		{Id: "t6", Time: date(2026, 3, 1, 0, 0), Amount: eur(25.5), Type: Transfer, Counterparty: "Bobby Tables", Reference: "rent share"},
+	}
+}
+
+func TestTransactionQuery(t *testing.T) {
+	tests := []struct {
+		query     string
+		want      []string
+		wantTotal int
+	}{
+		{"", []string{"t1", "t2", "t3", "t4", "t5", "t6"}, 6},
+		{"from=2026-02-01&to=2026-02-28", []string{"t3", "t4", "t5"}, 3},
+		{"from=2026-01-20T12:00:00Z&to=2026-02-01T08:30:00Z", []string{"t2"}, 1},
+		{"min_amount=25.50&max_amount=50", []string{"t2", "t3", "t4", "t6"}, 4},
+		{"min_amount=30 EUR", []string{"t1", "t3"}, 2},
+		{"currency=usd", []string{"t4"}, 1},
+		{"type=transfer,fee", []string{"t2", "t5", "t6"}, 3},
+		{"type=withdraw&type=deposit", []string{"t1", "t3"}, 2},
+		{"counterparty=BOB", []string{"t2", "t6"}, 2},
+		{"reference=share", []string{"t6"}, 1},
+		{"q=bob&type=transfer&from=2026-02-01", []string{"t6"}, 1},
+		{"q=TRIP", []string{"t4"}, 1},
+		{"sort=-amount,time", []string{"t4", "t1", "t3", "t2", "t6", "t5"}, 6},
+		{"currency=eur&sort=amount,-time", []string{"t5", "t6", "t2", "t3", "t1"}, 5},
+		{"sort=-time&limit=2", []string{"t6", "t5"}, 6},
+		{"type=transfer&offset=1&limit=5", []string{"t6"}, 2},
+		{"offset=10", []string{}, 6},
+		{"limit=1000&offset=4", []string{"t5", "t6"}, 6},
+		{"criteria=month&filter=2", []string{"t3", "t4", "t5"}, 3},
+		{"criteria=day&filter=5", []string{"t1", "t4"}, 2},
+		{"criteria=amount&filter=25.5", []string{"t2", "t6"}, 2},
+		{"criteria=month&filter=feb", []string{}, 0},
+		{"criteria=colour&filter=red", []string{}, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.query, func(t *testing.T) {
+			values, err := url.ParseQuery(tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			query, err := ParseTransactionQuery(values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			page, total := query.Run(queryHistory())
+			got := []string{}
+			for _, txn := range page {
+				got = append(got, txn.Id)
+			}
+			if !slices.Equal(got, tc.want) || total != tc.wantTotal {
+				t.Errorf("got %v of %d, want %v of %d", got, total, tc.want, tc.wantTotal)
+			}
+		})
+	}
+	// Queries built in code are not capped.
+	if page, _ := (TransactionQuery{Offset: 5, Limit: math.MaxInt}).Run(queryHistory()); len(page) != 1 || page[0].Id != "t6" {
+		t.Errorf("page with the largest limit = %+v, want t6", page)
+	}
+}
+
+func TestCompareValue(t *testing.T) {
+	tests := []struct {
+		a, b Money
+		want int
+	}{
+		{NewMoney(100, JPY), NewMoney(10000, ""), 0},
+		{NewMoney(1001, "kwd"), NewMoney(100, ""), 1},
+		{NewMoney(-5, JPY), NewMoney(-499, ""), -1},
+		{NewMoney(math.MaxInt64, JPY), NewMoney(math.MaxInt64, ""), 1},
+	}
+	for _, tc := range tests {
+		if got := compareValue(tc.a, tc.b); got != tc.want {
+			t.Errorf("compareValue(%v, %v) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestInvalidTransactionQuery(t *testing.T) {
+	for _, query := range []string{
+		"from=yesterday",
+		"from=2026-03-01&to=2026-02-01",
+		"min_amount=ten",
+		"min_amount=5&max_amount=1",
+		"min_amount=1 EUR&max_amount=5 USD",
+		"currency=xyz",
+		"sort=balance",
+		"limit=-1",
+		"limit=1001",
+		"limit=9223372036854775807",
+		"offset=two",
+	} {
+		values, _ := url.ParseQuery(query)
+		if _, err := ParseTransactionQuery(values); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("%s: got %v, want %v", query, err, ErrInvalidQuery)
+		}
+	}
+}
+
+func BenchmarkTransactionQuery(b *testing.B) {
+	history := make([]Transactions, 100_000)
+	for i := range history {
+		history[i] = Transactions{
+			Id:           fmt.Sprint(i),
+			Time:         date(2020, 1, 1, 0, 0).Add(time.Duration(i) * time.Hour),
+			Amount:       NewMoney(int64(i%10_000), EUR),
+			Type:         []TransactionType{Deposit, Withdraw, Transfer}[i%3],
+			Counterparty: fmt.Sprint("payee ", i%50),
+		}
+	}
+	values, _ := url.ParseQuery("from=2021-01-01&to=2021-12-31&type=transfer&min_amount=10&counterparty=payee 7&sort=-amount&limit=50")
+	query, err := ParseTransactionQuery(values)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for b.Loop() {
+		query.Run(history)
+	}
+}
@@ -0,0 +1,265 @@
+package bank
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultQuoteTTL       = 30 * time.Second
+	DefaultQuoteRetention = 24 * time.Hour
+)
+
+// DefaultQuoteSpread is the margin taken off the mid-market rate, 0.5%.
+var DefaultQuoteSpread = big.NewRat(5, 1000)
+
+type QuoteStatus string
+
+const (
+	QuoteOpen     QuoteStatus = "open"
+	QuoteExecuted QuoteStatus = "executed"
+)
+
+var (
+	ErrQuoteNotFound = errors.New("could not find quote")
+	ErrQuoteExpired  = errors.New("quote has expired")
+	ErrQuoteUsed     = errors.New("quote was already executed")
+)
+
+// FXQuote offers to sell Sell from AccountID for Buy until Expires. Rate is
+// the rate the customer gets, MidRate less Spread, and Fee is charged on
+// top when the quote is executed.
+type FXQuote struct {
+	Id            string
+	AccountID     string
+	Sell          Money
+	Buy           Money
+	Rate          string
+	MidRate       string
+	Spread        string
+	RateDate      time.Time
+	Fee           Money
+	Reference     string `json:",omitempty"`
+	Created       time.Time
+	Expires       time.Time
+	Status        QuoteStatus
+	TransactionID string `json:",omitempty"`
+}
+
+// QuoteStore prices and keeps FX quotes. Clock is injectable so tests can
+// let quotes expire.
+type QuoteStore struct {
+	mu     sync.Mutex
+	path   string
+	quotes map[string]FXQuote
+
+	TTL    time.Duration
+	Spread *big.Rat
+	Clock  func() time.Time
+}
+
+var quotes = NewMemoryQuoteStore()
+
+func SetQuotes(s *QuoteStore) {
+	quotes = s
+}
+
+func Quotes() *QuoteStore {
+	return quotes
+}
+
+func NewMemoryQuoteStore() *QuoteStore {
+	return &QuoteStore{
+		quotes: map[string]FXQuote{},
+		TTL:    DefaultQuoteTTL,
+		Spread: DefaultQuoteSpread,
+		Clock:  time.Now,
+	}
+}
+
+func NewQuoteStore(path string) (*QuoteStore, error) {
+	s := NewMemoryQuoteStore()
+	s.path = path
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+
+	var saved []FXQuote
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+	for _, quote := range saved {
+		s.quotes[quote.Id] = quote
+	}
+	s.prune(s.Clock())
+	return s, nil
+}
+
+// Create quotes selling amount out of account for target at the current
+// rate. Nothing is booked until the quote is executed.
+func (s *QuoteStore) Create(account *Account, amount Money, target Currency, reference ...string) (*FXQuote, error) {
+	snapshot, err := account.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	amount, err = snapshot.sellAmount(amount, target)
+	if err != nil {
+		return nil, err
+	}
+
+	mid, err := ExchangeRate(amount.Currency, target, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	rate := s.customerRate(mid.Rate)
+	bought, err := buyAmount(amount, rate, target)
+	if err != nil {
+		return nil, err
+	}
+
+	fee := fees.transactionFee(snapshot, Exchange)
+	if _, err := snapshot.checkDebit(amount, fee); err != nil {
+		return nil, err
+	}
+
+	now := s.Clock()
+	quote := FXQuote{
+		Id:        newID("q"),
+		AccountID: account.Id,
+		Sell:      amount,
+		Buy:       bought,
+		Rate:      formatRate(rate),
+		MidRate:   formatRate(mid.Rate),
+		Spread:    formatRate(s.Spread),
+		RateDate:  mid.Date,
+		Fee:       fee,
+		Reference: referenceText(reference),
+		Created:   now,
+		Expires:   now.Add(s.TTL),
+		Status:    QuoteOpen,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(now)
+	if err := s.put(quote); err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
+
+func (s *QuoteStore) Get(id string) (*FXQuote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	quote, ok := s.quotes[id]
+	if !ok {
+		return nil, ErrQuoteNotFound
+	}
+	return &quote, nil
+}
+
+// Execute books the quote at its locked rate and fee. A quote can be
+// executed once and only before it expires. The quote is marked executed
+// before booking, so a crash in between can lose a quote but never book it
+// twice.
+func (s *QuoteStore) Execute(id string) (*FXQuote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	quote, ok := s.quotes[id]
+	if !ok {
+		return nil, ErrQuoteNotFound
+	}
+	if quote.Status != QuoteOpen {
+		return nil, fmt.Errorf("%w: %s", ErrQuoteUsed, id)
+	}
+	if !s.Clock().Before(quote.Expires) {
+		return nil, fmt.Errorf("%w: %s", ErrQuoteExpired, id)
+	}
+
+	rate, ok := new(big.Rat).SetString(quote.Rate)
+	if !ok {
+		return nil, fmt.Errorf("quote %s has an invalid rate %q", id, quote.Rate)
+	}
+	account, err := store.Get(quote.AccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	open := quote
+	quote.Status = QuoteExecuted
+	if err := s.put(quote); err != nil {
+		return nil, err
+	}
+
+	dated := DatedRate{Date: quote.RateDate, Rate: rate}
+	receipt, err := account.bookExchange(quote.Sell, quote.Buy, quote.Fee, dated, quote.Reference)
+	if err != nil {
+		return nil, errors.Join(err, s.put(open))
+	}
+	quote.TransactionID = receipt.Transactions[0].Id
+	if err := s.put(quote); err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
+
+// customerRate is the mid-market rate less Spread.
+func (s *QuoteStore) customerRate(mid *big.Rat) *big.Rat {
+	rate := new(big.Rat).Sub(big.NewRat(1, 1), s.Spread)
+	return rate.Mul(rate, mid)
+}
+
+// prune forgets quotes that expired longer than DefaultQuoteRetention ago.
+func (s *QuoteStore) prune(now time.Time) {
+	for id, quote := range s.quotes {
+		if now.Sub(quote.Expires) > DefaultQuoteRetention {
+			delete(s.quotes, id)
+		}
+	}
+}
+
+func (s *QuoteStore) put(quote FXQuote) error {
+	previous, existed := s.quotes[quote.Id]
+	s.quotes[quote.Id] = quote
+	if err := s.persist(); err != nil {
+		if existed {
+			s.quotes[quote.Id] = previous
+		} else {
+			delete(s.quotes, quote.Id)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *QuoteStore) persist() error {
+	if s.path == "" {
+		return nil
+	}
+
+	saved := make([]FXQuote, 0, len(s.quotes))
+	for _, quote := range s.quotes {
+		saved = append(saved, quote)
+	}
+	sort.Slice(saved, func(i, j int) bool { return saved[i].Id < saved[j].Id })
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data, 0644)
+}
@@ -0,0 +1,139 @@
+package bank
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQuotes(t *testing.T) {
+	useStore(t)
+	b := useBooks(t)
+	useRates(t, map[Currency]string{USD: "1.0832"})
+	useFees(t, map[AccountType]FeeSchedule{
+		Savings: {PerTransaction: map[TransactionType]Money{Exchange: eur(1)}},
+	})
+	openTestAccounts(t, &Account{Id: "acc", Name: "Acc", Balance: eur(100), AccountType: Savings})
+
+	path := filepath.Join(t.TempDir(), "quotes.json")
+	desk, err := NewQuoteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := &fakeClock{now: time.Now()}
+	desk.Clock = clock.Now
+
+	acc, err := Store().Get("acc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	quote, err := desk.Create(acc, eur(50), USD, "trip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1.0832 less 0.5% is 1.077784, 50 EUR buy 53.8892 USD rounded down.
+	if quote.Rate != "1.077784" || quote.MidRate != "1.0832" || quote.Spread != "0.005" || quote.Buy != NewMoney(5388, USD) || quote.Fee != eur(1) {
+		t.Errorf("quote = %+v, want 53.88 USD at 1.077784 with a 1.00 EUR fee", quote)
+	}
+	if !quote.Expires.Equal(clock.now.Add(DefaultQuoteTTL)) || quote.Status != QuoteOpen {
+		t.Errorf("quote expires %v with status %s, want open for %v", quote.Expires, quote.Status, DefaultQuoteTTL)
+	}
+	if got := balanceOf(t, "acc"); got != eur(100) {
+		t.Errorf("balance after quoting = %v, want nothing booked", got)
+	}
+
+	if _, err := desk.Create(acc, eur(100), USD); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("quote beyond the balance with fee: got %v, want %v", err, ErrInsufficientFunds)
+	}
+	if _, err := desk.Create(acc, eur(10), EUR); !errors.Is(err, ErrSameCurrency) {
+		t.Errorf("quote into the same currency: got %v, want %v", err, ErrSameCurrency)
+	}
+
+	// The rate moves, the quote keeps its price and survives a restart.
+	useRates(t, map[Currency]string{USD: "2"})
+	reloaded, err := NewQuoteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded.Clock = clock.Now
+	clock.now = clock.now.Add(DefaultQuoteTTL - time.Second)
+
+	executed, err := reloaded.Execute(quote.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if executed.Status != QuoteExecuted || executed.TransactionID == "" {
+		t.Errorf("executed quote = %+v", executed)
+	}
+	if acc, err = Store().Get("acc"); err != nil {
+		t.Fatal(err)
+	}
+	if acc.Balance != eur(49) || acc.BalanceIn(USD) != NewMoney(5388, USD) {
+		t.Errorf("balances = %v and %v, want 49.00 EUR and 53.88 USD", acc.Balance, acc.SubBalances)
+	}
+	if txn := acc.Transactions[0]; txn.Id != executed.TransactionID || txn.Rate != "1.077784" || txn.Reference != "trip" {
+		t.Errorf("transaction = %+v, want the quoted rate", txn)
+	}
+	if _, err := b.TrialBalance(); err != nil {
+		t.Errorf("books after executing: %v", err)
+	}
+
+	if _, err := reloaded.Execute(quote.Id); !errors.Is(err, ErrQuoteUsed) {
+		t.Errorf("second execution: got %v, want %v", err, ErrQuoteUsed)
+	}
+	if reloaded, err = NewQuoteStore(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reloaded.Execute(quote.Id); !errors.Is(err, ErrQuoteUsed) {
+		t.Errorf("execution after a restart: got %v, want %v", err, ErrQuoteUsed)
+	}
+
+	expiring, err := desk.Create(acc, eur(10), USD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.now = clock.now.Add(DefaultQuoteTTL)
+	if _, err := desk.Execute(expiring.Id); !errors.Is(err, ErrQuoteExpired) {
+		t.Errorf("expired quote: got %v, want %v", err, ErrQuoteExpired)
+	}
+	if _, err := desk.Execute("q_missing"); !errors.Is(err, ErrQuoteNotFound) {
+		t.Errorf("unknown quote: got %v, want %v", err, ErrQuoteNotFound)
+	}
+	if got := balanceOf(t, "acc"); got != eur(49) {
+		t.Errorf("balance after failed executions = %v, want 49.00 EUR", got)
+	}
+}
+
+func TestQuoteFailedBooking(t *testing.T) {
+	useStore(t)
+	useBooks(t)
+	useRates(t, map[Currency]string{USD: "1.1"})
+	openTestAccounts(t, &Account{Id: "acc", Name: "Acc", Balance: eur(20), AccountType: Savings})
+
+	desk := NewMemoryQuoteStore()
+	acc, err := Store().Get("acc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	quote, err := desk.Create(acc, eur(20), USD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := acc.Withdraw(eur(5)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := desk.Execute(quote.Id); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("executing without funds: got %v, want %v", err, ErrInsufficientFunds)
+	}
+	if got, err := desk.Get(quote.Id); err != nil || got.Status != QuoteOpen {
+		t.Errorf("quote after failed booking = %+v, %v, want it open again", got, err)
+	}
+	if err := acc.Deposit(eur(5)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := desk.Execute(quote.Id); err != nil {
+		t.Errorf("retry after topping up: %v", err)
+	}
+}
@@ -0,0 +1,452 @@
+package bank
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultRatesTimeout = 5 * time.Second
+	DefaultRatesTTL     = time.Hour
+
+	// maxRateLookback is how far back a dated lookup searches for the last
+	// published rate, reference rates are not published on weekends and
+	// holidays.
+	maxRateLookback = 7 * 24 * time.Hour
+)
+
+// DatedRate is the rate of one unit of the base currency on Date, the day
+// the rate was published for.
+type DatedRate struct {
+	Date time.Time
+	Rate *big.Rat
+}
+
+func (r DatedRate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Date string `json:"date"`
+		Rate string `json:"rate"`
+	}{r.Date.Format(time.DateOnly), formatRate(r.Rate)})
+}
+
+// ExchangeRateProvider returns how many units of target one unit of base
+// buys. Failures wrap ErrRateUnavailable.
+type ExchangeRateProvider interface {
+	// Rate returns the rate that applied on day, the latest one for the
+	// zero time.
+	Rate(base, target Currency, day time.Time) (DatedRate, error)
+	// Series returns the published rates between from and to, inclusive,
+	// oldest first.
+	Series(base, target Currency, from, to time.Time) ([]DatedRate, error)
+}
+
+var rates ExchangeRateProvider = &FrankfurterProvider{}
+
+func SetRates(p ExchangeRateProvider) {
+	rates = p
+}
+
+func Rates() ExchangeRateProvider {
+	return rates
+}
+
+// FrankfurterProvider asks the Frankfurter API for ECB rates.
+type FrankfurterProvider struct {
+	// BaseURL defaults to the public API.
+	BaseURL string
+	Client  *http.Client
+}
+
+func NewFrankfurterProvider(baseURL string, timeout time.Duration) *FrankfurterProvider {
+	return &FrankfurterProvider{BaseURL: baseURL, Client: &http.Client{Timeout: timeout}}
+}
+
+func (p *FrankfurterProvider) Rate(base, target Currency, day time.Time) (DatedRate, error) {
+	path := "latest"
+	if !day.IsZero() {
+		path = day.Format(time.DateOnly)
+	}
+
+	var body struct {
+		RatesResponse
+		Date string `json:"date"`
+	}
+	if err := p.get(path, base, target, &body); err != nil {
+		return DatedRate{}, err
+	}
+
+	rate, err := quoteFor(body.Rates, target)
+	if err != nil {
+		return DatedRate{}, fmt.Errorf("%w for %s/%s", err, base.Code(), target.Code())
+	}
+	date := startOfDay(day)
+	if day.IsZero() {
+		date = startOfDay(time.Now())
+	}
+	if body.Date != "" {
+		if date, err = time.Parse(time.DateOnly, body.Date); err != nil {
+			return DatedRate{}, fmt.Errorf("%w: %v", ErrRateUnavailable, err)
+		}
+	}
+	if !day.IsZero() && startOfDay(day).Sub(date) > maxRateLookback {
+		return DatedRate{}, fmt.Errorf("%w: no rate for %s/%s on %s", ErrRateUnavailable, base.Code(), target.Code(), day.Format(time.DateOnly))
+	}
+	return DatedRate{Date: date, Rate: rate}, nil
+}
+
+func (p *FrankfurterProvider) Series(base, target Currency, from, to time.Time) ([]DatedRate, error) {
+	var body struct {
+		Rates map[string]map[string]json.Number `json:"rates"`
+	}
+	path := from.Format(time.DateOnly) + ".." + to.Format(time.DateOnly)
+	if err := p.get(path, base, target, &body); err != nil {
+		return nil, err
+	}
+
+	series := make([]DatedRate, 0, len(body.Rates))
+	for day, quotes := range body.Rates {
+		date, err := time.Parse(time.DateOnly, day)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrRateUnavailable, err)
+		}
+		rate, err := quoteFor(quotes, target)
+		if err != nil {
+			return nil, fmt.Errorf("%w for %s/%s on %s", err, base.Code(), target.Code(), day)
+		}
+		series = append(series, DatedRate{Date: date, Rate: rate})
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].Date.Before(series[j].Date) })
+	return series, nil
+}
+
+func (p *FrankfurterProvider) get(path string, base, target Currency, body any) error {
+	baseURL, client := p.BaseURL, p.Client
+	if baseURL == "" {
+		baseURL = frankfurterAPI
+	}
+	if client == nil {
+		client = &http.Client{Timeout: DefaultRatesTimeout}
+	}
+
+	response, err := client.Get(fmt.Sprintf("%s/%s?from=%s&to=%s", baseURL, path, base.Code(), target.Code()))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRateUnavailable, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s", ErrRateUnavailable, response.Status)
+	}
+	if err := json.NewDecoder(response.Body).Decode(body); err != nil {
+		return fmt.Errorf("%w: %v", ErrRateUnavailable, err)
+	}
+	return nil
+}
+
+func quoteFor(quotes map[string]json.Number, target Currency) (*big.Rat, error) {
+	for code, value := range quotes {
+		if strings.EqualFold(code, string(target)) {
+			return parseExchangeRate(value.String())
+		}
+	}
+	return nil, fmt.Errorf("%w: no rate", ErrRateUnavailable)
+}
+
+// StaticRateProvider serves reference rates loaded from disk, all quoted
+// against one base currency. Cross rates go through that base. A file with
+// many days works as a local rate archive.
+type StaticRateProvider struct {
+	Base Currency
+	// Date is the most recent day in the file.
+	Date time.Time
+	days []rateDay
+}
+
+type rateDay struct {
+	date   time.Time
+	quotes map[Currency]*big.Rat
+}
+
+// LoadStaticRates reads either ECB reference rates XML (eurofxref-daily.xml
+// or eurofxref-hist.xml) or JSON in the Frankfurter format, a single day
+// {"base": "EUR", "date": "2024-01-05", "rates": {"USD": 1.0921}} or a series
+// {"base": "EUR", "rates": {"2024-01-05": {"USD": 1.0921}}}.
+func LoadStaticRates(path string) (*StaticRateProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var p *StaticRateProvider
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "<") {
+		p, err = parseECBRates(data)
+	} else {
+		p, err = parseJSONRates(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rates in %s: %w", path, err)
+	}
+
+	sort.Slice(p.days, func(i, j int) bool { return p.days[i].date.Before(p.days[j].date) })
+	p.Date = p.days[len(p.days)-1].date
+	return p, nil
+}
+
+type ecbEnvelope struct {
+	Days []struct {
+		Time  string `xml:"time,attr"`
+		Rates []struct {
+			Currency string `xml:"currency,attr"`
+			Rate     string `xml:"rate,attr"`
+		} `xml:"Cube"`
+	} `xml:"Cube>Cube"`
+}
+
+// parseECBRates reads ECB files, where rates are quoted against EUR.
+func parseECBRates(data []byte) (*StaticRateProvider, error) {
+	var envelope ecbEnvelope
+	if err := xml.Unmarshal(data, &envelope); err != nil {
+		return nil, err
+	}
+	if len(envelope.Days) == 0 {
+		return nil, errors.New("no rates found")
+	}
+
+	p := &StaticRateProvider{Base: EUR}
+	for _, day := range envelope.Days {
+		date, err := time.Parse(time.DateOnly, day.Time)
+		if err != nil {
+			return nil, err
+		}
+		quotes := map[Currency]*big.Rat{}
+		for _, quote := range day.Rates {
+			rate, err := parseExchangeRate(quote.Rate)
+			if err != nil {
+				return nil, err
+			}
+			quotes[Currency(strings.ToLower(quote.Currency))] = rate
+		}
+		p.days = append(p.days, rateDay{date: date, quotes: quotes})
+	}
+	return p, nil
+}
+
+func parseJSONRates(data []byte) (*StaticRateProvider, error) {
+	var body struct {
+		Base  string                     `json:"base"`
+		Date  string                     `json:"date"`
+		Rates map[string]json.RawMessage `json:"rates"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, err
+	}
+	if body.Base == "" || len(body.Rates) == 0 {
+		return nil, errors.New("need a base and rates")
+	}
+
+	// A single day maps currencies to numbers, a series maps dates to
+	// such objects.
+	series := map[string]map[string]json.Number{}
+	for key, value := range body.Rates {
+		if bytes.HasPrefix(bytes.TrimSpace(value), []byte("{")) {
+			var quotes map[string]json.Number
+			if err := json.Unmarshal(value, &quotes); err != nil {
+				return nil, err
+			}
+			series[key] = quotes
+			continue
+		}
+
+		if body.Date == "" {
+			return nil, errors.New("need the date of the rates")
+		}
+		var quote json.Number
+		if err := json.Unmarshal(value, &quote); err != nil {
+			return nil, err
+		}
+		if series[body.Date] == nil {
+			series[body.Date] = map[string]json.Number{}
+		}
+		series[body.Date][key] = quote
+	}
+
+	p := &StaticRateProvider{Base: Currency(strings.ToLower(body.Base))}
+	for day, values := range series {
+		date, err := time.Parse(time.DateOnly, day)
+		if err != nil {
+			return nil, err
+		}
+		quotes := map[Currency]*big.Rat{}
+		for code, value := range values {
+			rate, err := parseExchangeRate(value.String())
+			if err != nil {
+				return nil, err
+			}
+			quotes[Currency(strings.ToLower(code))] = rate
+		}
+		p.days = append(p.days, rateDay{date: date, quotes: quotes})
+	}
+	return p, nil
+}
+
+func (p *StaticRateProvider) Rate(base, target Currency, day time.Time) (DatedRate, error) {
+	i := len(p.days) - 1
+	if !day.IsZero() {
+		day = startOfDay(day)
+		i = sort.Search(len(p.days), func(i int) bool { return p.days[i].date.After(day) }) - 1
+		if i < 0 || day.Sub(p.days[i].date) > maxRateLookback {
+			return DatedRate{}, fmt.Errorf("%w: no reference rates for %s", ErrRateUnavailable, day.Format(time.DateOnly))
+		}
+	}
+	return p.rateOn(p.days[i], base, target)
+}
+
+func (p *StaticRateProvider) Series(base, target Currency, from, to time.Time) ([]DatedRate, error) {
+	from, to = startOfDay(from), startOfDay(to)
+
+	var series []DatedRate
+	for _, day := range p.days {
+		if day.date.Before(from) || day.date.After(to) {
+			continue
+		}
+		rate, err := p.rateOn(day, base, target)
+		if err != nil {
+			return nil, err
+		}
+		series = append(series, rate)
+	}
+	return series, nil
+}
+
+func (p *StaticRateProvider) rateOn(day rateDay, base, target Currency) (DatedRate, error) {
+	from, ok := p.quote(day, base)
+	if !ok {
+		return DatedRate{}, fmt.Errorf("%w: no reference rate for %s on %s", ErrRateUnavailable, base.Code(), day.date.Format(time.DateOnly))
+	}
+	to, ok := p.quote(day, target)
+	if !ok {
+		return DatedRate{}, fmt.Errorf("%w: no reference rate for %s on %s", ErrRateUnavailable, target.Code(), day.date.Format(time.DateOnly))
+	}
+	return DatedRate{Date: day.date, Rate: new(big.Rat).Quo(to, from)}, nil
+}
+
+func (p *StaticRateProvider) quote(day rateDay, currency Currency) (*big.Rat, bool) {
+	if currency == p.Base {
+		return big.NewRat(1, 1), true
+	}
+	rate, ok := day.quotes[currency]
+	return rate, ok
+}
+
+// CachedRateProvider remembers the rates of Provider for TTL. Failures and
+// series are not cached.
+type CachedRateProvider struct {
+	Provider ExchangeRateProvider
+	TTL      time.Duration
+	Clock    func() time.Time
+
+	mu      sync.Mutex
+	entries map[rateKey]cachedRate
+}
+
+type rateKey struct {
+	base, target Currency
+	day          time.Time
+}
+
+type cachedRate struct {
+	rate    DatedRate
+	fetched time.Time
+}
+
+func NewCachedRateProvider(p ExchangeRateProvider, ttl time.Duration) *CachedRateProvider {
+	return &CachedRateProvider{Provider: p, TTL: ttl, Clock: time.Now, entries: map[rateKey]cachedRate{}}
+}
+
+func (c *CachedRateProvider) Rate(base, target Currency, day time.Time) (DatedRate, error) {
+	key := rateKey{base, target, day}
+	if !day.IsZero() {
+		key.day = startOfDay(day)
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && c.Clock().Sub(entry.fetched) < c.TTL {
+		return entry.rate.copy(), nil
+	}
+
+	// The lock is not held while fetching so one slow pair does not block
+	// the others, concurrent misses may fetch the same pair twice.
+	rate, err := c.Provider.Rate(base, target, day)
+	if err != nil {
+		return DatedRate{}, err
+	}
+
+	c.mu.Lock()
+	c.entries[key] = cachedRate{rate: rate.copy(), fetched: c.Clock()}
+	c.mu.Unlock()
+	return rate, nil
+}
+
+func (c *CachedRateProvider) Series(base, target Currency, from, to time.Time) ([]DatedRate, error) {
+	return c.Provider.Series(base, target, from, to)
+}
+
+func (r DatedRate) copy() DatedRate {
+	r.Rate = new(big.Rat).Set(r.Rate)
+	return r
+}
+
+// ChainRateProvider asks each provider in turn and returns the first answer
+// it gets, e.g. the live API before a file on disk.
+type ChainRateProvider []ExchangeRateProvider
+
+func (c ChainRateProvider) Rate(base, target Currency, day time.Time) (DatedRate, error) {
+	var errs []error
+	for _, p := range c {
+		rate, err := p.Rate(base, target, day)
+		if err == nil {
+			return rate, nil
+		}
+		errs = append(errs, err)
+	}
+	return DatedRate{}, c.failed(errs)
+}
+
+func (c ChainRateProvider) Series(base, target Currency, from, to time.Time) ([]DatedRate, error) {
+	var errs []error
+	for _, p := range c {
+		series, err := p.Series(base, target, from, to)
+		if err == nil {
+			return series, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, c.failed(errs)
+}
+
+func (c ChainRateProvider) failed(errs []error) error {
+	if len(errs) == 0 {
+		return fmt.Errorf("%w: no providers", ErrRateUnavailable)
+	}
+	return errors.Join(errs...)
+}
+
+func parseExchangeRate(text string) (*big.Rat, error) {
+	rate, ok := new(big.Rat).SetString(strings.TrimSpace(text))
+	if !ok || rate.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: invalid rate %q", ErrRateUnavailable, text)
+	}
+	return rate, nil
+}
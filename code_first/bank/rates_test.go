@@ -0,0 +1,259 @@
+package bank
+
+import (
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+const ecbDaily = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<Cube>
+		<Cube time="2024-01-04">
+			<Cube currency="USD" rate="1.0900"/>
+		</Cube>
+		<Cube time="2024-01-05">
+			<Cube currency="USD" rate="1.0921"/>
+			<Cube currency="GBP" rate="0.86"/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+// countingRates returns a fixed rate or error and counts the calls.
+type countingRates struct {
+	rate  *big.Rat
+	err   error
+	calls int
+}
+
+func (c *countingRates) Rate(base, target Currency, day time.Time) (DatedRate, error) {
+	c.calls++
+	return DatedRate{Date: startOfDay(day), Rate: c.rate}, c.err
+}
+
+func (c *countingRates) Series(base, target Currency, from, to time.Time) ([]DatedRate, error) {
+	c.calls++
+	return []DatedRate{{Date: from, Rate: c.rate}}, c.err
+}
+
+func writeRatesFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestStaticRates(t *testing.T) {
+	tests := map[string]struct {
+		file     string
+		content  string
+		base     Currency
+		target   Currency
+		wantRate string
+		wantDate string
+		wantErr  bool
+	}{
+		"ECB XML":            {file: "eurofxref.xml", content: ecbDaily, base: EUR, target: USD, wantRate: "1.0921", wantDate: "2024-01-05"},
+		"ECB XML inverse":    {file: "eurofxref.xml", content: ecbDaily, base: GBP, target: EUR, wantRate: "50/43", wantDate: "2024-01-05"},
+		"ECB XML cross rate": {file: "eurofxref.xml", content: ecbDaily, base: GBP, target: USD, wantRate: "10921/8600", wantDate: "2024-01-05"},
+		"JSON":               {file: "rates.json", content: `{"base": "USD", "date": "2024-02-01", "rates": {"EUR": 0.92}}`, base: USD, target: EUR, wantRate: "23/25", wantDate: "2024-02-01"},
+		"unknown currency":   {file: "eurofxref.xml", content: ecbDaily, base: EUR, target: JPY, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, err := LoadStaticRates(writeRatesFile(t, tc.file, tc.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.Date.Format("2006-01-02"); got != tc.wantDate && !tc.wantErr {
+				t.Errorf("date = %s, want %s", got, tc.wantDate)
+			}
+
+			rate, err := p.Rate(tc.base, tc.target, time.Time{})
+			if tc.wantErr {
+				if !errors.Is(err, ErrRateUnavailable) {
+					t.Errorf("got %v, want %v", err, ErrRateUnavailable)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := new(big.Rat).SetString(tc.wantRate)
+			if rate.Rate.Cmp(want) != 0 {
+				t.Errorf("rate = %s, want %s", rate.Rate.RatString(), tc.wantRate)
+			}
+		})
+	}
+
+	if _, err := LoadStaticRates(writeRatesFile(t, "empty.json", `{"base": "EUR"}`)); err == nil {
+		t.Error("rates without quotes were accepted")
+	}
+}
+
+func TestCachedRates(t *testing.T) {
+	upstream := &countingRates{rate: big.NewRat(11, 10)}
+	clock := &fakeClock{now: date(2026, 1, 1, 9, 0)}
+	cache := NewCachedRateProvider(upstream, time.Hour)
+	cache.Clock = clock.Now
+
+	for range 3 {
+		if _, err := cache.Rate(EUR, USD, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if upstream.calls != 1 {
+		t.Errorf("upstream calls = %d, want 1 within the TTL", upstream.calls)
+	}
+
+	if _, err := cache.Rate(USD, EUR, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if upstream.calls != 2 {
+		t.Errorf("upstream calls = %d, want 2 for another pair", upstream.calls)
+	}
+
+	clock.now = clock.now.Add(time.Hour)
+	upstream.err = ErrRateUnavailable
+	if _, err := cache.Rate(EUR, USD, time.Time{}); !errors.Is(err, ErrRateUnavailable) {
+		t.Errorf("expired entry with failing upstream: got %v, want %v", err, ErrRateUnavailable)
+	}
+
+	upstream.err = nil
+	if _, err := cache.Rate(EUR, USD, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if upstream.calls != 4 {
+		t.Errorf("upstream calls = %d, want a refetch after the TTL and the failure", upstream.calls)
+	}
+}
+
+func TestChainRates(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	fallback := &countingRates{rate: big.NewRat(3, 2)}
+	chain := ChainRateProvider{NewFrankfurterProvider(slow.URL, 20*time.Millisecond), fallback}
+
+	rate, err := chain.Rate(EUR, USD, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate.Rate.Cmp(big.NewRat(3, 2)) != 0 || fallback.calls != 1 {
+		t.Errorf("rate = %s after %d fallback calls, want 3/2 from the fallback", rate.Rate.RatString(), fallback.calls)
+	}
+
+	fallback.err = errors.New("offline")
+	if _, err := chain.Rate(EUR, USD, time.Time{}); !errors.Is(err, ErrRateUnavailable) {
+		t.Errorf("all providers failing: got %v, want %v", err, ErrRateUnavailable)
+	}
+	if _, err := (ChainRateProvider{}).Rate(EUR, USD, time.Time{}); !errors.Is(err, ErrRateUnavailable) {
+		t.Errorf("empty chain: got %v, want %v", err, ErrRateUnavailable)
+	}
+}
+
+func TestHistoricalStaticRates(t *testing.T) {
+	p, err := LoadStaticRates(writeRatesFile(t, "eurofxref-hist.xml", ecbDaily))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		day      time.Time
+		wantDate string
+		wantRate string
+		wantErr  bool
+	}{
+		{"published day", date(2024, 1, 4, 15, 0), "2024-01-04", "1.09", false},
+		{"weekend uses friday", date(2024, 1, 7, 0, 0), "2024-01-05", "1.0921", false},
+		{"latest", time.Time{}, "2024-01-05", "1.0921", false},
+		{"before the archive", date(2024, 1, 3, 0, 0), "", "", true},
+		{"long after the archive", date(2024, 2, 1, 0, 0), "", "", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rate, err := p.Rate(EUR, USD, tc.day)
+			if tc.wantErr {
+				if !errors.Is(err, ErrRateUnavailable) {
+					t.Errorf("got %v, want %v", err, ErrRateUnavailable)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := new(big.Rat).SetString(tc.wantRate)
+			if got := rate.Date.Format(time.DateOnly); got != tc.wantDate || rate.Rate.Cmp(want) != 0 {
+				t.Errorf("rate = %s on %s, want %s on %s", formatRate(rate.Rate), got, tc.wantRate, tc.wantDate)
+			}
+		})
+	}
+
+	series, err := p.Series(EUR, USD, date(2024, 1, 1, 0, 0), date(2024, 1, 31, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 2 || formatRate(series[0].Rate) != "1.09" || formatRate(series[1].Rate) != "1.0921" {
+		t.Errorf("series = %+v, want 1.09 and 1.0921", series)
+	}
+	if _, err := p.Series(EUR, JPY, date(2024, 1, 1, 0, 0), date(2024, 1, 31, 0, 0)); !errors.Is(err, ErrRateUnavailable) {
+		t.Errorf("series for an unknown currency: got %v, want %v", err, ErrRateUnavailable)
+	}
+
+	p, err = LoadStaticRates(writeRatesFile(t, "series.json", `{"base": "EUR", "rates": {"2024-01-05": {"USD": 1.0921}, "2024-01-04": {"USD": 1.09}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate, err := p.Rate(USD, EUR, date(2024, 1, 4, 0, 0)); err != nil || rate.Rate.Cmp(big.NewRat(100, 109)) != 0 {
+		t.Errorf("rate from a JSON series = %v, %v, want 100/109", rate.Rate, err)
+	}
+}
+
+func TestFrankfurterHistory(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/2024-01-06":
+			w.Write([]byte(`{"base": "EUR", "date": "2024-01-05", "rates": {"USD": 1.0921}}`))
+		case "/2024-01-04..2024-01-07":
+			w.Write([]byte(`{"base": "EUR", "rates": {"2024-01-05": {"USD": 1.0921}, "2024-01-04": {"USD": 1.09}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	p := NewFrankfurterProvider(server.URL, time.Second)
+
+	rate, err := p.Rate(EUR, USD, date(2024, 1, 6, 12, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate.Date != date(2024, 1, 5, 0, 0) || formatRate(rate.Rate) != "1.0921" {
+		t.Errorf("rate = %s on %v, want 1.0921 on 2024-01-05", formatRate(rate.Rate), rate.Date)
+	}
+
+	series, err := p.Series(EUR, USD, date(2024, 1, 4, 0, 0), date(2024, 1, 7, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 2 || series[0].Date != date(2024, 1, 4, 0, 0) || formatRate(series[1].Rate) != "1.0921" {
+		t.Errorf("series = %+v, want 2024-01-04 and 2024-01-05", series)
+	}
+	if !slices.Equal(paths, []string{"/2024-01-06", "/2024-01-04..2024-01-07"}) {
+		t.Errorf("requested %v", paths)
+	}
+}
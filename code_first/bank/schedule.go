@@ -0,0 +1,175 @@
+package bank
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule yields the run times of a standing order.
+type Schedule interface {
+	// Next returns the first run strictly after t, or the zero time if
+	// there is none.
+	Next(t time.Time) time.Time
+}
+
+// ParseSchedule understands
+//
+//	monthly <day>          runs at midnight on that day, or on the last day of shorter months
+//	@daily, @weekly, @monthly, @yearly
+//	<min> <hour> <day of month> <month> <day of week>
+//
+// where the cron fields accept *, numbers, ranges (1-5), lists (1,15) and
+// steps (*/15, 1-10/2). Run times are computed in UTC.
+func ParseSchedule(spec string) (Schedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty schedule")
+	}
+
+	if strings.EqualFold(fields[0], "monthly") {
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid schedule %q: want \"monthly <day>\"", spec)
+		}
+		day, err := strconv.Atoi(fields[1])
+		if err != nil || day < 1 || day > 31 {
+			return nil, fmt.Errorf("invalid schedule %q: day must be between 1 and 31", spec)
+		}
+		return monthlySchedule{day: day}, nil
+	}
+
+	switch strings.ToLower(spec) {
+	case "@daily":
+		fields = strings.Fields("0 0 * * *")
+	case "@weekly":
+		fields = strings.Fields("0 0 * * 0")
+	case "@monthly":
+		fields = strings.Fields("0 0 1 * *")
+	case "@yearly":
+		fields = strings.Fields("0 0 1 1 *")
+	}
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: want five cron fields", spec)
+	}
+
+	var c cronSchedule
+	var err error
+	if c.minute, _, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: minute: %w", spec, err)
+	}
+	if c.hour, _, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: hour: %w", spec, err)
+	}
+	if c.dom, c.domAny, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: day of month: %w", spec, err)
+	}
+	if c.month, _, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: month: %w", spec, err)
+	}
+	if c.dow, c.dowAny, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: day of week: %w", spec, err)
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1 // 7 is Sunday as well
+	}
+	return c, nil
+}
+
+type monthlySchedule struct {
+	day int
+}
+
+func (m monthlySchedule) Next(t time.Time) time.Time {
+	t = t.UTC()
+	for i := 0; i < 2; i++ {
+		first := time.Date(t.Year(), t.Month()+time.Month(i), 1, 0, 0, 0, 0, time.UTC)
+		last := first.AddDate(0, 1, -1).Day()
+		run := first.AddDate(0, 0, min(m.day, last)-1)
+		if run.After(t) {
+			return run
+		}
+	}
+	return time.Time{}
+}
+
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+func (c cronSchedule) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, time.UTC)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches follows cron: if both day fields are restricted either may
+// match, otherwise the restricted one has to.
+func (c cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if !c.domAny && !c.dowAny {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+func parseCronField(field string, lo, hi int) (uint64, bool, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step < 1 {
+				return 0, false, fmt.Errorf("invalid step %q", stepText)
+			}
+		}
+
+		start, end := lo, hi
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+			var err error
+			if start, err = strconv.Atoi(from); err != nil {
+				return 0, false, fmt.Errorf("invalid value %q", from)
+			}
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(to); err != nil {
+					return 0, false, fmt.Errorf("invalid value %q", to)
+				}
+			} else if hasStep {
+				end = hi
+			}
+		}
+		if start < lo || end > hi || start > end {
+			return 0, false, fmt.Errorf("%q out of range %d-%d", part, lo, hi)
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, field == "*", nil
+}
@@ -0,0 +1,300 @@
+package bank
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotSEPA         = errors.New("not a SEPA credit transfer")
+	ErrPaymentNotFound = errors.New("could not find payment")
+	ErrNoPayments      = errors.New("no payments are queued")
+)
+
+type PaymentStatus string
+
+const (
+	PaymentQueued    PaymentStatus = "queued"
+	PaymentSubmitted PaymentStatus = "submitted"
+	PaymentAccepted  PaymentStatus = "accepted"
+	PaymentRejected  PaymentStatus = "rejected"
+)
+
+// Party is the debtor or creditor of a SEPA credit transfer. BIC is
+// optional within SEPA.
+type Party struct {
+	Name string
+	IBAN IBAN
+	BIC  string `json:",omitempty"`
+}
+
+// Payment is a transfer to an account at another bank. The money leaves
+// the account when the payment is queued and waits in the clearing account
+// until the clearing partner accepts or rejects it. Id is the id of the
+// booking transaction and the end-to-end id of the credit transfer.
+type Payment struct {
+	Id        string
+	AccountID string
+	Debtor    Party
+	Creditor  Party
+	Amount    Money
+	Reference string `json:",omitempty"`
+	Status    PaymentStatus
+	Created   time.Time
+	// MessageID and PaymentInfoID locate the payment in the pain.001
+	// message it was submitted with.
+	MessageID     string `json:",omitempty"`
+	PaymentInfoID string `json:",omitempty"`
+	// Reason is the ISO 20022 reason code of a rejection, RefundID the
+	// reversal that paid the money back.
+	Reason   string `json:",omitempty"`
+	RefundID string `json:",omitempty"`
+}
+
+// PaymentStore keeps the outgoing SEPA payments. Clock is injectable so
+// tests control creation and execution dates.
+type PaymentStore struct {
+	mu       sync.Mutex
+	path     string
+	payments map[string]Payment
+
+	Clock func() time.Time
+}
+
+var payments = NewMemoryPaymentStore()
+
+func SetPayments(s *PaymentStore) {
+	payments = s
+}
+
+func Payments() *PaymentStore {
+	return payments
+}
+
+func NewMemoryPaymentStore() *PaymentStore {
+	return &PaymentStore{payments: map[string]Payment{}, Clock: time.Now}
+}
+
+func NewPaymentStore(path string) (*PaymentStore, error) {
+	s := NewMemoryPaymentStore()
+	s.path = path
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+
+	var saved []Payment
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+	for _, payment := range saved {
+		s.payments[payment.Id] = payment
+	}
+	return s, nil
+}
+
+func (s *PaymentStore) Get(id string) (*Payment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payment, ok := s.payments[id]
+	if !ok {
+		return nil, ErrPaymentNotFound
+	}
+	return &payment, nil
+}
+
+// List returns the payments of the account, oldest first.
+func (s *PaymentStore) List(accountID string) []Payment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := []Payment{}
+	for _, payment := range s.payments {
+		if payment.AccountID == accountID {
+			list = append(list, payment)
+		}
+	}
+	sortPayments(list)
+	return list
+}
+
+func sortPayments(list []Payment) {
+	slices.SortFunc(list, func(a, b Payment) int {
+		if c := a.Created.Compare(b.Created); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Id, b.Id)
+	})
+}
+
+// BookSEPATransfer queues a credit transfer to an account at another bank
+// in the SEPA zone. The amount and the transfer fee are booked at once, the
+// amount against the clearing account it is paid out of. Transfers to
+// IBANs of this bank are booked as internal transfers.
+func (account *Account) BookSEPATransfer(amount Money, creditor Party, reference ...string) (*Receipt, error) {
+	if creditor.IBAN == "" {
+		return nil, fmt.Errorf("%w: no creditor IBAN", ErrNotSEPA)
+	}
+	if issuer.Issues(creditor.IBAN) {
+		return account.BookTransfer(amount, creditor.IBAN.String(), reference...)
+	}
+	if _, ok := ibanFormats[creditor.IBAN.Country()]; !ok {
+		return nil, fmt.Errorf("%w: %s is not in the SEPA zone", ErrNotSEPA, creditor.IBAN.Country())
+	}
+	if creditor.BIC != "" {
+		bic, err := ParseBIC(creditor.BIC)
+		if err != nil {
+			return nil, err
+		}
+		creditor.BIC = bic
+	} else {
+		creditor.BIC, _ = creditor.IBAN.BIC()
+	}
+	creditor.Name = strings.TrimSpace(creditor.Name)
+	if err := checkAmount(amount); err != nil {
+		return nil, err
+	}
+
+	var receipt *Receipt
+	err := update(func() error {
+		booked := len(account.Transactions)
+		if account.IBAN == "" {
+			return fmt.Errorf("%w: account %s has no IBAN", ErrNotSEPA, account.Id)
+		}
+
+		fee := fees.transactionFee(account, Transfer)
+		amount, err := account.checkDebit(amount, fee)
+		if err != nil {
+			return err
+		}
+		if amount.Currency != EUR {
+			return fmt.Errorf("%w: SEPA transfers are in EUR, not %s", ErrNotSEPA, amount.Currency.Code())
+		}
+
+		txID, ref := newTransactionID(), referenceText(reference)
+		err = account.record(Event{Type: TransferredOut, Amount: amount, Counterparty: creditor.IBAN.String(), TransactionID: txID, Reference: ref})
+		if err != nil {
+			return err
+		}
+
+		entry := NewEntry("sepa transfer "+account.Id+" -> "+creditor.IBAN.String(),
+			Leg{Account: account.Id, Side: Debit, Amount: amount},
+			Leg{Account: SEPAClearingAccount, Side: Credit, Amount: amount},
+		)
+		entry.TransactionID = txID
+
+		entries, err := account.addTransactionFee([]JournalEntry{entry}, fee, Transfer)
+		if err != nil {
+			return err
+		}
+
+		// The payment is queued first and dropped again if the booking
+		// fails, so no payment is ever sent without its booking.
+		payment := Payment{
+			Id:        txID,
+			AccountID: account.Id,
+			Debtor:    Party{Name: account.Name, IBAN: account.IBAN, BIC: issuer.BIC},
+			Creditor:  creditor,
+			Amount:    amount,
+			Reference: ref,
+			Status:    PaymentQueued,
+			Created:   payments.Clock(),
+		}
+		if err := payments.add(payment); err != nil {
+			return err
+		}
+		if err := postAllAndSave(entries, account); err != nil {
+			return errors.Join(err, payments.remove(payment.Id))
+		}
+		receipt = account.receipt(booked)
+		return nil
+	}, account)
+	return receipt, err
+}
+
+func (s *PaymentStore) add(payment Payment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.put(payment)
+}
+
+func (s *PaymentStore) remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payment, ok := s.payments[id]
+	if !ok {
+		return nil
+	}
+	delete(s.payments, id)
+	if err := s.persist(); err != nil {
+		s.payments[id] = payment
+		return err
+	}
+	return nil
+}
+
+func (s *PaymentStore) put(payment Payment) error {
+	previous, existed := s.payments[payment.Id]
+	s.payments[payment.Id] = payment
+	if err := s.persist(); err != nil {
+		if existed {
+			s.payments[payment.Id] = previous
+		} else {
+			delete(s.payments, payment.Id)
+		}
+		return err
+	}
+	return nil
+}
+
+// putAll stores every payment or none of them.
+func (s *PaymentStore) putAll(list []Payment) error {
+	previous := make(map[string]Payment, len(list))
+	for _, payment := range list {
+		if old, ok := s.payments[payment.Id]; ok {
+			previous[payment.Id] = old
+		}
+		s.payments[payment.Id] = payment
+	}
+	if err := s.persist(); err != nil {
+		for _, payment := range list {
+			if old, ok := previous[payment.Id]; ok {
+				s.payments[payment.Id] = old
+			} else {
+				delete(s.payments, payment.Id)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *PaymentStore) persist() error {
+	if s.path == "" {
+		return nil
+	}
+
+	saved := make([]Payment, 0, len(s.payments))
+	for _, payment := range s.payments {
+		saved = append(saved, payment)
+	}
+	sortPayments(saved)
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data, 0644)
+}
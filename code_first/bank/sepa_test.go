@@ -0,0 +1,294 @@
+package bank
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func usePayments(t *testing.T, path string) *PaymentStore {
+	t.Helper()
+
+	original := payments
+	s, err := NewPaymentStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetPayments(s)
+	t.Cleanup(func() { SetPayments(original) })
+	return s
+}
+
+// pain002 is a status report for message with the statuses of the given
+// end-to-end ids.
+func pain002(message, group string, transactions map[string]string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.002.001.03">
+  <CstmrPmtStsRpt>
+    <GrpHdr><MsgId>report</MsgId></GrpHdr>
+    <OrgnlGrpInfAndSts><OrgnlMsgId>` + message + `</OrgnlMsgId><OrgnlMsgNmId>pain.001.001.09</OrgnlMsgNmId><GrpSts>` + group + `</GrpSts></OrgnlGrpInfAndSts>
+    <OrgnlPmtInfAndSts>
+      <OrgnlPmtInfId>` + message + `-1</OrgnlPmtInfId>`)
+	for id, status := range transactions {
+		b.WriteString(`
+      <TxInfAndSts><OrgnlEndToEndId>` + id + `</OrgnlEndToEndId><TxSts>` + status + `</TxSts>`)
+		if status == "RJCT" {
+			b.WriteString(`<StsRsnInf><Rsn><Cd>AC04</Cd></Rsn></StsRsnInf>`)
+		}
+		b.WriteString(`</TxInfAndSts>`)
+	}
+	b.WriteString(`
+    </OrgnlPmtInfAndSts>
+  </CstmrPmtStsRpt>
+</Document>`)
+	return b.String()
+}
+
+func TestSEPATransfers(t *testing.T) {
+	useStore(t)
+	b := useBooks(t)
+	useIBANIssuer(t, DefaultIBANIssuer)
+	useFees(t, map[AccountType]FeeSchedule{
+		Giro: {PerTransaction: map[TransactionType]Money{Transfer: eur(0.5)}},
+	})
+	path := filepath.Join(t.TempDir(), "payments.json")
+	s := usePayments(t, path)
+	clock := &fakeClock{now: date(2026, time.March, 2, 9, 0)}
+	s.Clock = clock.Now
+	openTestAccounts(t, &Account{Id: "alice", Name: "Alice", Balance: eur(100), AccountType: Giro})
+
+	alice, err := Store().Get("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	creditor := Party{Name: "Bob", IBAN: "NL91ABNA0417164300", BIC: "abnanl2a"}
+	receipt, err := alice.BookSEPATransfer(eur(40), creditor, "invoice 7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(receipt.Transactions) != 2 || receipt.Transactions[0].Counterparty != "NL91ABNA0417164300" || receipt.Account.Balance != eur(59.5) {
+		t.Errorf("receipt = %+v, want the transfer and its fee booked", receipt)
+	}
+	bobID := receipt.Transactions[0].Id
+
+	// Transfer routes IBANs of other banks to SEPA.
+	clock.now = clock.now.Add(time.Minute)
+	if err := alice.Transfer(eur(10), "FR14 2004 1010 0505 0001 3M02 606"); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Balance(SEPAClearingAccount, EUR); got != eur(50) {
+		t.Errorf("clearing balance = %v, want 50", got)
+	}
+
+	if _, err := alice.BookSEPATransfer(eur(1), Party{IBAN: "BR1800360305000010009795493C1"}); !errors.Is(err, ErrNotSEPA) {
+		t.Errorf("transfer outside the SEPA zone: got %v, want %v", err, ErrNotSEPA)
+	}
+	if _, err := alice.BookSEPATransfer(eur(1), Party{IBAN: creditor.IBAN, BIC: "nope"}); !errors.Is(err, ErrInvalidBIC) {
+		t.Errorf("transfer with an invalid BIC: got %v, want %v", err, ErrInvalidBIC)
+	}
+	if _, err := alice.BookSEPATransfer(eur(100), creditor); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("transfer beyond the balance: got %v, want %v", err, ErrInsufficientFunds)
+	}
+
+	list := s.List("alice")
+	if len(list) != 2 || list[0].Id != bobID || list[0].Status != PaymentQueued || list[0].Creditor.BIC != "ABNANL2A" || list[0].Debtor.IBAN != alice.IBAN {
+		t.Fatalf("payments = %+v, want two queued payments, Bob's first", list)
+	}
+	frID := list[1].Id
+	if list[1].Creditor.Name != "" || list[1].Amount != eur(10) {
+		t.Errorf("payment to the French IBAN = %+v", list[1])
+	}
+
+	var out bytes.Buffer
+	submitted, err := s.ExportPain001(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := submitted[0].MessageID
+	xml := out.String()
+	for _, want := range []string{
+		`<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.001.001.09">`,
+		"<MsgId>" + message + "</MsgId>",
+		"<NbOfTxs>2</NbOfTxs>",
+		"<CtrlSum>50.00</CtrlSum>",
+		"<PmtInfId>" + message + "-1</PmtInfId>",
+		"<ReqdExctnDt>\n        <Dt>2026-03-02</Dt>",
+		"<IBAN>" + alice.IBAN.String() + "</IBAN>",
+		"<BICFI>" + DefaultIBANIssuer.BIC + "</BICFI>",
+		"<EndToEndId>" + bobID + "</EndToEndId>",
+		`<InstdAmt Ccy="EUR">40.00</InstdAmt>`,
+		"<BICFI>ABNANL2A</BICFI>",
+		"<Nm>Bob</Nm>",
+		"<Nm>NOTPROVIDED</Nm>",
+		"<Ustrd>invoice 7</Ustrd>",
+	} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("pain.001 lacks %q:\n%s", want, xml)
+		}
+	}
+	if strings.Count(xml, "<CdtrAgt>") != 1 {
+		t.Errorf("pain.001 names a creditor agent without a BIC:\n%s", xml)
+	}
+	if _, err := s.ExportPain001(&out); !errors.Is(err, ErrNoPayments) {
+		t.Errorf("second export: got %v, want %v", err, ErrNoPayments)
+	}
+
+	report, err := s.ApplyStatusReport(strings.NewReader(pain002(message, "PART", map[string]string{
+		bobID: "ACSC", frID: "RJCT", "tx_elsewhere": "ACSC",
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Accepted) != 1 || report.Accepted[0].Id != bobID {
+		t.Errorf("accepted = %+v, want Bob's payment", report.Accepted)
+	}
+	if len(report.Rejected) != 1 || report.Rejected[0].Id != frID || report.Rejected[0].Reason != "AC04" || report.Rejected[0].RefundID == "" {
+		t.Errorf("rejected = %+v, want the French payment refunded", report.Rejected)
+	}
+	if len(report.Unknown) != 1 || report.Unknown[0] != "tx_elsewhere" {
+		t.Errorf("unknown = %v, want tx_elsewhere", report.Unknown)
+	}
+	// The rejected amount comes back, its fee does not.
+	if got := balanceOf(t, "alice"); got != eur(59) {
+		t.Errorf("balance after the refund = %v, want 59", got)
+	}
+	if got := b.Balance(SEPAClearingAccount, EUR); got != eur(40) {
+		t.Errorf("clearing balance after the refund = %v, want 40", got)
+	}
+
+	// Reading the report again changes nothing.
+	report, err = s.ApplyStatusReport(strings.NewReader(pain002(message, "PART", map[string]string{bobID: "ACSC", frID: "RJCT"})))
+	if err != nil || len(report.Accepted)+len(report.Rejected) != 0 {
+		t.Errorf("second report = %+v, %v, want nothing settled", report, err)
+	}
+	if got := balanceOf(t, "alice"); got != eur(59) {
+		t.Errorf("balance after the second report = %v, want 59", got)
+	}
+
+	reloaded, err := NewPaymentStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rejected, err := reloaded.Get(frID)
+	if err != nil || rejected.Status != PaymentRejected || rejected.RefundID != reversalOf(t, frID) {
+		t.Errorf("reloaded payment = %+v, %v, want it rejected and refunded", rejected, err)
+	}
+	if accepted, err := reloaded.Get(bobID); err != nil || accepted.Status != PaymentAccepted || accepted.MessageID != message {
+		t.Errorf("reloaded payment = %+v, %v, want it accepted", accepted, err)
+	}
+
+	if _, err := s.ApplyStatusReport(strings.NewReader(pain002("pain_other", "ACCP", nil))); !errors.Is(err, ErrPaymentNotFound) {
+		t.Errorf("report for an unknown message: got %v, want %v", err, ErrPaymentNotFound)
+	}
+	if _, err := s.ApplyStatusReport(strings.NewReader("<Document/>")); !errors.Is(err, ErrInvalidStatusReport) {
+		t.Errorf("report that is not pain.002: got %v, want %v", err, ErrInvalidStatusReport)
+	}
+}
+
+// reversalOf returns the transaction that reversed txID.
+func reversalOf(t *testing.T, txID string) string {
+	t.Helper()
+	bookings, err := FindTransaction(txID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bookings[0].ReversedBy
+}
+
+func TestSEPAGroupStatus(t *testing.T) {
+	useStore(t)
+	useBooks(t)
+	useIBANIssuer(t, DefaultIBANIssuer)
+	useFees(t, nil)
+	s := usePayments(t, "")
+	openTestAccounts(t, &Account{Id: "alice", Name: "Alice", Balance: eur(100), AccountType: Giro})
+
+	alice, err := Store().Get("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, amount := range []float64{20, 30} {
+		if _, err := alice.BookSEPATransfer(eur(amount), Party{Name: "Bob", IBAN: "NL91ABNA0417164300"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Transfers to IBANs of this bank stay inside it.
+	if _, err := alice.BookSEPATransfer(eur(1), Party{IBAN: alice.IBAN}); !errors.Is(err, ErrSelfTransfer) {
+		t.Errorf("SEPA transfer to the own IBAN: got %v, want %v", err, ErrSelfTransfer)
+	}
+
+	submitted, err := s.ExportPain001(&bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := submitted[0].MessageID
+
+	// A pending report leaves the payments submitted.
+	report, err := s.ApplyStatusReport(strings.NewReader(pain002(message, "PDNG", nil)))
+	if err != nil || len(report.Accepted)+len(report.Rejected) != 0 {
+		t.Errorf("pending report = %+v, %v, want nothing settled", report, err)
+	}
+
+	// A rejected message rejects every payment in it.
+	report, err = s.ApplyStatusReport(strings.NewReader(pain002(message, "RJCT", nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Rejected) != 2 {
+		t.Errorf("rejected = %+v, want both payments", report.Rejected)
+	}
+	if got := balanceOf(t, "alice"); got != eur(100) {
+		t.Errorf("balance after the rejection = %v, want 100", got)
+	}
+}
+
+func TestSEPAPaymentsCannotBeReversed(t *testing.T) {
+	useStore(t)
+	b := useBooks(t)
+	useIBANIssuer(t, DefaultIBANIssuer)
+	useFees(t, nil)
+	s := usePayments(t, "")
+	openTestAccounts(t, &Account{Id: "alice", Name: "Alice", Balance: eur(100), AccountType: Giro})
+
+	alice, err := Store().Get("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipt, err := alice.BookSEPATransfer(eur(40), Party{Name: "Bob", IBAN: "NL91ABNA0417164300"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txID := receipt.Transactions[0].Id
+
+	if _, err := Reverse(txID); !errors.Is(err, ErrNotReversible) {
+		t.Errorf("reversing a queued payment: got %v, want %v", err, ErrNotReversible)
+	}
+	submitted, err := s.ExportPain001(&bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(submitted) != 1 || submitted[0].Id != txID {
+		t.Fatalf("submitted = %+v, want the payment", submitted)
+	}
+	if _, err := Reverse(txID); !errors.Is(err, ErrNotReversible) {
+		t.Errorf("reversing a submitted payment: got %v, want %v", err, ErrNotReversible)
+	}
+	if got := balanceOf(t, "alice"); got != eur(60) {
+		t.Errorf("balance = %v, want 60 with the payment on its way", got)
+	}
+	if got := b.Balance(SEPAClearingAccount, EUR); got != eur(40) {
+		t.Errorf("clearing balance = %v, want 40", got)
+	}
+
+	// Once rejected, the payment is refunded by its reversal.
+	if _, err := s.ApplyStatusReport(strings.NewReader(pain002(submitted[0].MessageID, "RJCT", nil))); err != nil {
+		t.Fatal(err)
+	}
+	if got := balanceOf(t, "alice"); got != eur(100) {
+		t.Errorf("balance after the rejection = %v, want 100", got)
+	}
+}
@@ -0,0 +1,459 @@
+package bank
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxRecordedFailures = 20
+
+type OrderStatus string
+
+const (
+	OrderActive   OrderStatus = "active"
+	OrderFinished OrderStatus = "finished"
+)
+
+var ErrStandingOrderNotFound = errors.New("could not find standing order")
+
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, Interval: 24 * time.Hour}
+
+// RetryPolicy controls how often a run that failed for insufficient funds is
+// tried again before it is skipped until the next scheduled date.
+type RetryPolicy struct {
+	MaxAttempts int
+	Interval    time.Duration
+}
+
+type OrderFailure struct {
+	Time    time.Time
+	Due     time.Time
+	Attempt int
+	Error   string
+}
+
+// StandingOrder transfers Amount from AccountID to the account named To on
+// every run of Schedule between Start and End.
+type StandingOrder struct {
+	Id        string
+	AccountID string
+	To        string
+	Amount    Money
+	Reference string `json:",omitempty"`
+	Schedule  string
+	Start     time.Time
+	End       time.Time `json:",omitempty"`
+	Retry     RetryPolicy
+	Status    OrderStatus
+
+	// NextRun is the scheduled date of the next transfer, RetryAt is set
+	// while a failed run waits for another attempt.
+	NextRun  time.Time
+	RetryAt  time.Time `json:",omitempty"`
+	Attempts int       `json:",omitempty"`
+	LastRun  time.Time `json:",omitempty"`
+	Failures []OrderFailure
+}
+
+// Validate checks the terms of the order.
+func (o *StandingOrder) Validate() error {
+	if o.AccountID == "" {
+		return errors.New("standing order needs an account")
+	}
+	if strings.TrimSpace(o.To) == "" {
+		return errors.New("standing order needs a recipient")
+	}
+	if !o.Amount.IsPositive() {
+		return fmt.Errorf("Amount should be larger then 0")
+	}
+	if o.Start.IsZero() {
+		return errors.New("standing order needs a start date")
+	}
+	if !o.End.IsZero() && o.End.Before(o.Start) {
+		return errors.New("standing order ends before it starts")
+	}
+	if o.Retry.MaxAttempts < 0 || o.Retry.Interval < 0 {
+		return errors.New("invalid retry policy")
+	}
+
+	_, err := ParseSchedule(o.Schedule)
+	return err
+}
+
+// begin starts the run state over with the first run at or after the
+// start date, but not before now, so no past runs are made up for.
+func (o *StandingOrder) begin(now time.Time) error {
+	schedule, err := ParseSchedule(o.Schedule)
+	if err != nil {
+		return err
+	}
+
+	from := o.Start
+	if from.Before(now) {
+		from = now
+	}
+	o.Status = OrderActive
+	o.RetryAt, o.Attempts = time.Time{}, 0
+	o.NextRun = schedule.Next(from.Add(-time.Nanosecond))
+	o.finishIfOver()
+	return nil
+}
+
+func (o *StandingOrder) due(now time.Time) bool {
+	if o.Status != OrderActive {
+		return false
+	}
+	if !o.RetryAt.IsZero() {
+		return !o.RetryAt.After(now)
+	}
+	return !o.NextRun.After(now)
+}
+
+func (o *StandingOrder) advance() error {
+	schedule, err := ParseSchedule(o.Schedule)
+	if err != nil {
+		return err
+	}
+	o.RetryAt, o.Attempts = time.Time{}, 0
+	o.NextRun = schedule.Next(o.NextRun)
+	o.finishIfOver()
+	return nil
+}
+
+func (o *StandingOrder) finishIfOver() {
+	if o.NextRun.IsZero() || (!o.End.IsZero() && o.NextRun.After(o.End)) {
+		o.Status = OrderFinished
+	}
+}
+
+// StandingOrderStore keeps the standing orders. Clock is injectable so
+// tests control which start dates are in the past.
+type StandingOrderStore struct {
+	mu     sync.Mutex
+	path   string
+	orders map[string]StandingOrder
+
+	Clock func() time.Time
+}
+
+var standingOrders = NewMemoryStandingOrderStore()
+
+func SetStandingOrders(s *StandingOrderStore) {
+	standingOrders = s
+}
+
+func StandingOrders() *StandingOrderStore {
+	return standingOrders
+}
+
+func NewMemoryStandingOrderStore() *StandingOrderStore {
+	return &StandingOrderStore{orders: map[string]StandingOrder{}, Clock: time.Now}
+}
+
+func NewStandingOrderStore(path string) (*StandingOrderStore, error) {
+	s := NewMemoryStandingOrderStore()
+	s.path = path
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+
+	var orders []StandingOrder
+	if err := json.Unmarshal(data, &orders); err != nil {
+		return nil, err
+	}
+	for _, order := range orders {
+		s.orders[order.Id] = order
+	}
+	return s, nil
+}
+
+func (s *StandingOrderStore) Create(order StandingOrder) (*StandingOrder, error) {
+	if order.Id == "" {
+		order.Id = newID("so")
+	}
+	if order.Retry == (RetryPolicy{}) {
+		order.Retry = DefaultRetryPolicy
+	}
+	if err := order.Validate(); err != nil {
+		return nil, err
+	}
+	now := s.Clock()
+	if order.Start.Before(startOfDay(now)) {
+		return nil, errors.New("standing order cannot start in the past")
+	}
+	if err := order.begin(now); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orders[order.Id]; ok {
+		return nil, fmt.Errorf("standing order %s already exists", order.Id)
+	}
+	if err := s.put(order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (s *StandingOrderStore) Get(id string) (*StandingOrder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[id]
+	if !ok {
+		return nil, ErrStandingOrderNotFound
+	}
+	return &order, nil
+}
+
+// List returns the orders of the account, or all orders for an empty id.
+func (s *StandingOrderStore) List(accountID string) []StandingOrder {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	orders := []StandingOrder{}
+	for _, order := range s.orders {
+		if accountID == "" || order.AccountID == accountID {
+			orders = append(orders, order)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].Id < orders[j].Id })
+	return orders
+}
+
+// Update replaces the terms of an order. The run state is kept unless the
+// schedule or the start date changed, then it starts over from the new
+// start date or now, whichever is later. The failure history is kept.
+func (s *StandingOrderStore) Update(order StandingOrder) (*StandingOrder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.orders[order.Id]
+	if !ok {
+		return nil, ErrStandingOrderNotFound
+	}
+	if order.Retry == (RetryPolicy{}) {
+		order.Retry = existing.Retry
+	}
+	order.AccountID = existing.AccountID
+	order.LastRun = existing.LastRun
+	order.Failures = existing.Failures
+	if err := order.Validate(); err != nil {
+		return nil, err
+	}
+
+	if order.Schedule != existing.Schedule || !order.Start.Equal(existing.Start) {
+		if err := order.begin(s.Clock()); err != nil {
+			return nil, err
+		}
+	} else {
+		order.NextRun, order.RetryAt, order.Attempts = existing.NextRun, existing.RetryAt, existing.Attempts
+		// A new end date may finish the order or give it more runs.
+		order.Status = OrderActive
+		order.finishIfOver()
+	}
+
+	if err := s.put(order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (s *StandingOrderStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.orders[id]
+	if !ok {
+		return ErrStandingOrderNotFound
+	}
+	delete(s.orders, id)
+	if err := s.persist(); err != nil {
+		s.orders[id] = existing
+		return err
+	}
+	return nil
+}
+
+func (s *StandingOrderStore) put(order StandingOrder) error {
+	previous, existed := s.orders[order.Id]
+	s.orders[order.Id] = order
+	if err := s.persist(); err != nil {
+		if existed {
+			s.orders[order.Id] = previous
+		} else {
+			delete(s.orders, order.Id)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *StandingOrderStore) persist() error {
+	if s.path == "" {
+		return nil
+	}
+
+	orders := make([]StandingOrder, 0, len(s.orders))
+	for _, order := range s.orders {
+		orders = append(orders, order)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].Id < orders[j].Id })
+
+	data, err := json.MarshalIndent(orders, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data, 0644)
+}
+
+// Scheduler executes due standing orders through Account.Transfer. Clock is
+// injectable so tests and catch-up runs can move time explicitly.
+type Scheduler struct {
+	Orders   *StandingOrderStore
+	Clock    func() time.Time
+	Interval time.Duration
+}
+
+func NewScheduler(orders *StandingOrderStore) *Scheduler {
+	return &Scheduler{Orders: orders, Clock: time.Now, Interval: time.Minute}
+}
+
+// Run calls RunDue every Interval until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.RunDue(); err != nil {
+			fmt.Println("standing orders:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue executes every run that is due at the current clock time, including
+// runs missed while the scheduler was not running.
+func (s *Scheduler) RunDue() error {
+	now := s.Clock()
+
+	var errs []error
+	for _, order := range s.Orders.List("") {
+		for order.due(now) {
+			if err := s.execute(&order, now); err != nil {
+				errs = append(errs, fmt.Errorf("standing order %s: %w", order.Id, err))
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Scheduler) execute(order *StandingOrder, now time.Time) error {
+	s.Orders.mu.Lock()
+	defer s.Orders.mu.Unlock()
+
+	// The order may have been changed or deleted since it was listed.
+	current, ok := s.Orders.orders[order.Id]
+	if !ok {
+		order.Status = OrderFinished
+		return nil
+	}
+	*order = current
+	if !order.due(now) {
+		return nil
+	}
+
+	// The run is saved as done before the transfer is booked, so an order
+	// store that cannot be written makes the order skip a run rather than
+	// pay it twice.
+	run := *order
+	order.LastRun = now
+	if err := order.advance(); err != nil {
+		*order = run
+		return err
+	}
+	if err := s.Orders.put(*order); err != nil {
+		*order = run
+		return err
+	}
+
+	err := s.transfer(&run)
+	if err == nil {
+		return nil
+	}
+
+	*order = run
+	returned := false
+	switch {
+	case errors.Is(err, ErrInsufficientFunds):
+		order.Attempts++
+		order.recordFailure(now, err)
+		if order.Attempts < order.Retry.MaxAttempts && order.Retry.Interval > 0 {
+			order.RetryAt = now.Add(order.Retry.Interval)
+		} else {
+			returned = true
+			if err := order.advance(); err != nil {
+				return err
+			}
+		}
+	default:
+		order.Attempts++
+		order.recordFailure(now, err)
+		if err := order.advance(); err != nil {
+			return err
+		}
+	}
+	if err := s.Orders.put(*order); err != nil {
+		return err
+	}
+	if returned {
+		// The run is given up, which counts as a returned payment.
+		return fees.ChargeReturnedPayment(order.AccountID, "standing order "+order.Id)
+	}
+	return nil
+}
+
+func (s *Scheduler) transfer(order *StandingOrder) error {
+	account, err := store.Get(order.AccountID)
+	if err != nil {
+		return err
+	}
+
+	reference := order.Reference
+	if reference == "" {
+		reference = "standing order " + order.Id
+	}
+	return account.Transfer(order.Amount, order.To, reference)
+}
+
+func (o *StandingOrder) recordFailure(now time.Time, err error) {
+	o.Failures = append(o.Failures, OrderFailure{
+		Time:    now,
+		Due:     o.NextRun,
+		Attempt: o.Attempts,
+		Error:   err.Error(),
+	})
+	if len(o.Failures) > maxRecordedFailures {
+		o.Failures = o.Failures[len(o.Failures)-maxRecordedFailures:]
+	}
+}
@@ -0,0 +1,348 @@
+package bank
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		spec  string
+		after time.Time
+		want  time.Time
+	}{
+		{"monthly 15", date(2026, 1, 10, 12, 0), date(2026, 1, 15, 0, 0)},
+		{"monthly 15", date(2026, 1, 15, 0, 0), date(2026, 2, 15, 0, 0)},
+		{"monthly 31", date(2026, 2, 1, 0, 0), date(2026, 2, 28, 0, 0)},
+		{"monthly 31", date(2026, 4, 30, 0, 0), date(2026, 5, 31, 0, 0)},
+		{"@daily", date(2026, 3, 1, 8, 30), date(2026, 3, 2, 0, 0)},
+		{"@monthly", date(2026, 12, 5, 0, 0), date(2027, 1, 1, 0, 0)},
+		{"30 9 * * 1-5", date(2026, 10, 16, 10, 0), date(2026, 10, 19, 9, 30)},
+		{"*/15 * * * *", date(2026, 1, 1, 10, 7), date(2026, 1, 1, 10, 15)},
+		{"0 12 1,15 * *", date(2026, 1, 2, 0, 0), date(2026, 1, 15, 12, 0)},
+		{"0 0 13 * 5", date(2026, 1, 1, 0, 0), date(2026, 1, 2, 0, 0)},
+		{"0 0 29 2 *", date(2026, 3, 1, 0, 0), date(2028, 2, 29, 0, 0)},
+		{"0 0 * * 7", date(2026, 10, 17, 0, 0), date(2026, 10, 18, 0, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := schedule.Next(tt.after); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.after, got, tt.want)
+			}
+		})
+	}
+
+	for _, spec := range []string{"", "monthly", "monthly 0", "monthly 32", "* * * *", "60 * * * *", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) accepted an invalid schedule", spec)
+		}
+	}
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func useScheduler(t *testing.T, now time.Time) (*Scheduler, *fakeClock) {
+	t.Helper()
+	useStore(t)
+	useBooks(t)
+
+	clock := &fakeClock{now: now}
+	scheduler := NewScheduler(NewMemoryStandingOrderStore())
+	scheduler.Clock = clock.Now
+	scheduler.Orders.Clock = clock.Now
+	return scheduler, clock
+}
+
+func openTestAccounts(t *testing.T, accounts ...*Account) {
+	t.Helper()
+	for _, acc := range accounts {
+		if err := OpenAccount(acc); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func balanceOf(t *testing.T, id string) Money {
+	t.Helper()
+	acc, err := Store().Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return acc.Balance
+}
+
+func TestSchedulerRunsStandingOrders(t *testing.T) {
+	scheduler, clock := useScheduler(t, date(2026, 1, 1, 0, 0))
+	openTestAccounts(t,
+		&Account{Id: "tenant", Name: "Tenant", Balance: eur(1000), AccountType: Savings},
+		&Account{Id: "landlord", Name: "Landlord", Balance: eur(0), AccountType: Savings},
+	)
+
+	order, err := scheduler.Orders.Create(StandingOrder{
+		AccountID: "tenant",
+		To:        "Landlord",
+		Amount:    eur(300),
+		Reference: "rent",
+		Schedule:  "monthly 1",
+		Start:     date(2026, 1, 1, 0, 0),
+		End:       date(2026, 3, 31, 0, 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !order.NextRun.Equal(date(2026, 1, 1, 0, 0)) {
+		t.Errorf("first run = %v, want the start date", order.NextRun)
+	}
+
+	if err := scheduler.RunDue(); err != nil {
+		t.Fatal(err)
+	}
+	if got := balanceOf(t, "landlord"); got != eur(300) {
+		t.Errorf("after first run landlord has %v, want 300", got)
+	}
+	if err := scheduler.RunDue(); err != nil {
+		t.Fatal(err)
+	}
+	if got := balanceOf(t, "landlord"); got != eur(300) {
+		t.Errorf("running twice on the same day booked again: %v", got)
+	}
+
+	// The scheduler was down for two months and catches up.
+	clock.now = date(2026, 5, 1, 0, 0)
+	if err := scheduler.RunDue(); err != nil {
+		t.Fatal(err)
+	}
+	if got := balanceOf(t, "landlord"); got != eur(900) {
+		t.Errorf("after catch-up landlord has %v, want 900", got)
+	}
+
+	order, err = scheduler.Orders.Get(order.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != OrderFinished {
+		t.Errorf("status after end date = %s, want %s", order.Status, OrderFinished)
+	}
+
+	bookings, err := Store().Get("landlord")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref := bookings.Transactions[0].Reference; ref != "rent" {
+		t.Errorf("reference = %q, want rent", ref)
+	}
+}
+
+func TestSchedulerRetriesInsufficientFunds(t *testing.T) {
+	scheduler, clock := useScheduler(t, date(2026, 1, 1, 0, 0))
+	saver := &Account{Id: "saver", Name: "Saver", Balance: eur(50), AccountType: Savings}
+	openTestAccounts(t, saver, &Account{Id: "pot", Name: "Pot", Balance: eur(0), AccountType: Savings})
+
+	order, err := scheduler.Orders.Create(StandingOrder{
+		AccountID: "saver",
+		To:        "Pot",
+		Amount:    eur(100),
+		Schedule:  "monthly 1",
+		Start:     date(2026, 1, 1, 0, 0),
+		Retry:     RetryPolicy{MaxAttempts: 2, Interval: 24 * time.Hour},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := scheduler.RunDue(); err != nil {
+		t.Fatal(err)
+	}
+	order, _ = scheduler.Orders.Get(order.Id)
+	if len(order.Failures) != 1 || !order.RetryAt.Equal(date(2026, 1, 2, 0, 0)) {
+		t.Fatalf("after first failure: %+v, want one failure and a retry the next day", order)
+	}
+
+	if err := saver.Deposit(eur(60)); err != nil {
+		t.Fatal(err)
+	}
+	clock.now = date(2026, 1, 2, 0, 0)
+	if err := scheduler.RunDue(); err != nil {
+		t.Fatal(err)
+	}
+	if got := balanceOf(t, "pot"); got != eur(100) {
+		t.Errorf("retry did not transfer: pot has %v", got)
+	}
+
+	order, _ = scheduler.Orders.Get(order.Id)
+	if !order.NextRun.Equal(date(2026, 2, 1, 0, 0)) || !order.RetryAt.IsZero() || order.Attempts != 0 {
+		t.Errorf("after successful retry: %+v, want next run on Feb 1st", order)
+	}
+
+	// February fails on both attempts and is skipped.
+	clock.now = date(2026, 2, 1, 0, 0)
+	scheduler.RunDue()
+	clock.now = date(2026, 2, 2, 0, 0)
+	scheduler.RunDue()
+
+	order, _ = scheduler.Orders.Get(order.Id)
+	if len(order.Failures) != 3 || !order.NextRun.Equal(date(2026, 3, 1, 0, 0)) {
+		t.Errorf("after exhausting retries: %+v, want three failures and next run on Mar 1st", order)
+	}
+	if got := balanceOf(t, "pot"); got != eur(100) {
+		t.Errorf("failed runs moved money: pot has %v", got)
+	}
+}
+
+func TestStandingOrderStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orders.json")
+	s, err := NewStandingOrderStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Clock = (&fakeClock{now: date(2026, 1, 1, 8, 0)}).Now
+
+	if _, err := s.Create(StandingOrder{AccountID: "a", To: "B", Amount: eur(1), Schedule: "bogus", Start: date(2026, 1, 1, 0, 0)}); err == nil {
+		t.Error("created an order with an invalid schedule")
+	}
+	if _, err := s.Create(StandingOrder{AccountID: "a", To: "B", Amount: eur(1), Schedule: "@daily", Start: date(2025, 12, 31, 0, 0)}); err == nil {
+		t.Error("created an order that starts in the past")
+	}
+
+	order, err := s.Create(StandingOrder{AccountID: "a", To: "B", Amount: eur(1), Schedule: "@daily", Start: date(2026, 1, 1, 0, 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Retry != DefaultRetryPolicy {
+		t.Errorf("retry policy = %+v, want the default", order.Retry)
+	}
+
+	order.Amount = eur(2)
+	order.AccountID = "someone-else"
+	if _, err := s.Update(*order); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewStandingOrderStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := reloaded.Get(order.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Amount != eur(2) || got.AccountID != "a" {
+		t.Errorf("reloaded order = %+v, want amount 2 on account a", got)
+	}
+
+	if err := reloaded.Delete(order.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reloaded.Get(order.Id); !errors.Is(err, ErrStandingOrderNotFound) {
+		t.Errorf("deleted order: got %v, want %v", err, ErrStandingOrderNotFound)
+	}
+}
+
+func TestUpdateKeepsRunState(t *testing.T) {
+	scheduler, clock := useScheduler(t, date(2026, 1, 1, 0, 0))
+	openTestAccounts(t,
+		&Account{Id: "tenant", Name: "Tenant", Balance: eur(1000), AccountType: Savings},
+		&Account{Id: "landlord", Name: "Landlord", Balance: eur(0), AccountType: Savings},
+	)
+
+	order, err := scheduler.Orders.Create(StandingOrder{
+		AccountID: "tenant",
+		To:        "Landlord",
+		Amount:    eur(30),
+		Schedule:  "monthly 1",
+		Start:     date(2026, 1, 1, 0, 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.now = date(2026, 2, 10, 0, 0)
+	if err := scheduler.RunDue(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changing the reference must not run January and February again.
+	order, _ = scheduler.Orders.Get(order.Id)
+	order.Reference = "rent"
+	updated, err := scheduler.Orders.Update(*order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !updated.NextRun.Equal(date(2026, 3, 1, 0, 0)) || !updated.LastRun.Equal(clock.now) {
+		t.Errorf("after changing the reference: %+v, want the next run on Mar 1st", updated)
+	}
+	if err := scheduler.RunDue(); err != nil {
+		t.Fatal(err)
+	}
+	if got := balanceOf(t, "landlord"); got != eur(60) {
+		t.Errorf("landlord has %v, want 60", got)
+	}
+
+	// A new schedule starts from now, not from the old start date.
+	updated.Schedule = "monthly 5"
+	if updated, err = scheduler.Orders.Update(*updated); err != nil {
+		t.Fatal(err)
+	}
+	if !updated.NextRun.Equal(date(2026, 3, 5, 0, 0)) {
+		t.Errorf("after changing the schedule: next run %v, want Mar 5th", updated.NextRun)
+	}
+	if err := scheduler.RunDue(); err != nil {
+		t.Fatal(err)
+	}
+	if got := balanceOf(t, "landlord"); got != eur(60) {
+		t.Errorf("landlord has %v after the new schedule, want 60", got)
+	}
+}
+
+func TestSchedulerPaysOnceWhenOrdersCannotBeSaved(t *testing.T) {
+	scheduler, _ := useScheduler(t, date(2026, 1, 1, 0, 0))
+	openTestAccounts(t,
+		&Account{Id: "tenant", Name: "Tenant", Balance: eur(1000), AccountType: Savings},
+		&Account{Id: "landlord", Name: "Landlord", Balance: eur(0), AccountType: Savings},
+	)
+
+	if _, err := scheduler.Orders.Create(StandingOrder{
+		AccountID: "tenant",
+		To:        "Landlord",
+		Amount:    eur(30),
+		Schedule:  "monthly 1",
+		Start:     date(2026, 1, 1, 0, 0),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The store cannot be written: nothing is paid.
+	scheduler.Orders.path = filepath.Join(t.TempDir(), "missing", "orders.json")
+	if err := scheduler.RunDue(); err == nil {
+		t.Error("RunDue succeeded without saving the order")
+	}
+	if got := balanceOf(t, "landlord"); !got.IsZero() {
+		t.Errorf("landlord has %v after a failed save, want 0", got)
+	}
+
+	scheduler.Orders.path = filepath.Join(t.TempDir(), "orders.json")
+	for range 2 {
+		if err := scheduler.RunDue(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := balanceOf(t, "landlord"); got != eur(30) {
+		t.Errorf("landlord has %v, want 30 paid once", got)
+	}
+}
@@ -0,0 +1,212 @@
+package bank
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+)
+
+var ErrInvalidPeriod = errors.New("invalid statement period")
+
+// Statement is an account's activity in one currency over a period, with
+// the balance before the first and after the last line.
+type Statement struct {
+	AccountID   string
+	IBAN        IBAN `json:",omitempty"`
+	AccountName string
+	AccountType AccountType
+	Currency    Currency
+	// From is the first instant of the period, To the first instant after
+	// it.
+	From    time.Time
+	To      time.Time
+	Created time.Time
+	Opening Money
+	Closing Money
+	Lines   []StatementLine
+}
+
+// StatementLine is a transaction with its signed amount, negative for money
+// leaving the account, and the balance after it.
+type StatementLine struct {
+	Id           string
+	Time         time.Time
+	Type         TransactionType
+	Counterparty string `json:",omitempty"`
+	Reference    string `json:",omitempty"`
+	Amount       Money
+	Balance      Money
+}
+
+// Statement lists the transactions in currency, the base currency if
+// empty, booked from from up to but excluding to. A zero from starts on
+// the day of the first transaction, a zero to ends now.
+func (account *Account) Statement(from, to time.Time, currency Currency) (*Statement, error) {
+	acc, err := account.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if to.IsZero() {
+		to = now
+	}
+	if !from.IsZero() && !from.Before(to) {
+		return nil, fmt.Errorf("%w: %s is not before %s", ErrInvalidPeriod, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	}
+	if currency == "" {
+		currency = acc.BaseCurrency()
+	}
+	if err := checkCurrency(currency); err != nil {
+		return nil, err
+	}
+
+	statement := &Statement{
+		AccountID:   acc.Id,
+		IBAN:        acc.IBAN,
+		AccountName: acc.Name,
+		AccountType: acc.AccountType,
+		Currency:    currency,
+		From:        from,
+		To:          to,
+		Created:     now,
+	}
+
+	// The opening balance is worked back from today's balance, so the
+	// statement agrees with the account even where the history does not
+	// reach back to its opening.
+	signed := books.transactionDeltas(acc.Id, currency)
+	opening := acc.BalanceIn(currency).Minor
+	for i, txn := range acc.Transactions {
+		if txn.Amount.WithCurrency(acc.BaseCurrency()).Currency != currency {
+			continue
+		}
+		amount := signedAmount(txn, signed, acc.BaseCurrency())
+		if !txn.Time.Before(from) {
+			opening -= amount.Minor
+		}
+		if !txn.Time.Before(from) && txn.Time.Before(to) {
+			statement.Lines = append(statement.Lines, StatementLine{
+				Id:           statementLineID(acc.Id, txn.Id, i),
+				Time:         txn.Time,
+				Type:         txn.Type,
+				Counterparty: txn.Counterparty,
+				Reference:    txn.Reference,
+				Amount:       amount,
+			})
+		}
+	}
+	slices.SortStableFunc(statement.Lines, func(a, b StatementLine) int { return a.Time.Compare(b.Time) })
+
+	balance := NewMoney(opening, currency)
+	statement.Opening = balance
+	for i := range statement.Lines {
+		balance.Minor += statement.Lines[i].Amount.Minor
+		statement.Lines[i].Balance = balance
+	}
+	statement.Closing = balance
+
+	if statement.From.IsZero() {
+		statement.From = to
+		if len(statement.Lines) > 0 {
+			statement.From = startOfDay(statement.Lines[0].Time)
+		}
+	}
+	return statement, nil
+}
+
+// transactionDeltas sums the legs of each transaction on account in
+// currency, credits positive.
+func (b *Books) transactionDeltas(account string, currency Currency) map[string]int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	deltas := map[string]int64{}
+	for _, entry := range b.entries {
+		if entry.TransactionID == "" {
+			continue
+		}
+		for _, leg := range entry.Legs {
+			if leg.Account != account || leg.Amount.Currency != currency {
+				continue
+			}
+			if leg.Side == Credit {
+				deltas[entry.TransactionID] += leg.Amount.Minor
+			} else {
+				deltas[entry.TransactionID] -= leg.Amount.Minor
+			}
+		}
+	}
+	return deltas
+}
+
+// signedAmount takes the sign of a transaction from the books. Transactions
+// from before the books existed are signed by type, which has to guess that
+// their transfers went out.
+func signedAmount(txn Transactions, signed map[string]int64, base Currency) Money {
+	amount := txn.Amount.WithCurrency(base)
+	if delta, ok := signed[txn.Id]; ok && txn.Id != "" {
+		return NewMoney(delta, amount.Currency)
+	}
+	switch txn.Type {
+	case Deposit, Interest, Reversal:
+		return amount
+	default:
+		return amount.Neg()
+	}
+}
+
+// balanceAt works the balance in currency at t back from today's balance,
+// as Statement does for its opening balance. signed holds the deltas of the
+// account's transactions in the books.
+func (account *Account) balanceAt(currency Currency, t time.Time, signed map[string]int64) Money {
+	balance := account.BalanceIn(currency)
+	for _, txn := range account.Transactions {
+		if txn.Time.Before(t) || txn.Amount.WithCurrency(account.BaseCurrency()).Currency != currency {
+			continue
+		}
+		balance.Minor -= signedAmount(txn, signed, account.BaseCurrency()).Minor
+	}
+	return balance
+}
+
+// statementLineID is the transaction id, or a stable stand-in for
+// transactions recorded before they had one.
+func statementLineID(accountID, txID string, index int) string {
+	if txID != "" {
+		return txID
+	}
+	return fmt.Sprintf("%s-%d", accountID, index+1)
+}
+
+var statementCSVHeader = []string{"Date", "Transaction ID", "Type", "Counterparty", "Reference", "Amount", "Currency", "Balance"}
+
+// WriteCSV writes one row per line between an opening and a closing balance
+// row. Amounts are plain decimals with a leading minus for debits.
+func (s *Statement) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	rows := [][]string{
+		statementCSVHeader,
+		{s.From.UTC().Format(time.DateOnly), "", "opening balance", "", "", "", s.Currency.Code(), s.Opening.Decimal()},
+	}
+	for _, line := range s.Lines {
+		rows = append(rows, []string{
+			line.Time.UTC().Format(time.RFC3339),
+			line.Id,
+			string(line.Type),
+			line.Counterparty,
+			line.Reference,
+			line.Amount.Decimal(),
+			s.Currency.Code(),
+			line.Balance.Decimal(),
+		})
+	}
+	rows = append(rows, []string{s.To.UTC().Format(time.DateOnly), "", "closing balance", "", "", "", s.Currency.Code(), s.Closing.Decimal()})
+	if err := out.WriteAll(rows); err != nil {
+		return err
+	}
+	return out.Error()
+}
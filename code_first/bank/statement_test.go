@@ -0,0 +1,228 @@
+package bank
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"testing"
+	"time"
+)
+
+func statementFixture(t *testing.T) *Account {
+	t.Helper()
+	useStore(t)
+	useBooks(t)
+	openTestAccounts(t,
+		&Account{Id: "alice", Name: "Alice", Balance: eur(100), AccountType: Giro},
+		&Account{Id: "bob", Name: "Bob", Balance: eur(50), AccountType: Savings},
+	)
+
+	alice, err := Store().Get("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := Store().Get("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := alice.Transfer(eur(30), "bob", "rent"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bob.Transfer(eur(10), "alice", "refund"); err != nil {
+		t.Fatal(err)
+	}
+	if err := alice.Withdraw(eur(20)); err != nil {
+		t.Fatal(err)
+	}
+	if alice, err = Store().Get("alice"); err != nil {
+		t.Fatal(err)
+	}
+	return alice
+}
+
+func TestStatement(t *testing.T) {
+	alice := statementFixture(t)
+	today := startOfDay(time.Now())
+
+	statement, err := alice.Statement(today, today.AddDate(0, 0, 1), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statement.Opening != eur(100) || statement.Closing != eur(60) || statement.Currency != EUR {
+		t.Errorf("statement runs from %v to %v in %s, want 100.00 to 60.00 EUR", statement.Opening, statement.Closing, statement.Currency)
+	}
+	want := []struct {
+		txType       TransactionType
+		counterparty string
+		amount       Money
+		balance      Money
+	}{
+		{Transfer, "bob", eur(-30), eur(70)},
+		{Transfer, "bob", eur(10), eur(80)},
+		{Withdraw, "", eur(-20), eur(60)},
+	}
+	if len(statement.Lines) != len(want) {
+		t.Fatalf("statement has %d lines, want %d: %+v", len(statement.Lines), len(want), statement.Lines)
+	}
+	for i, line := range statement.Lines {
+		if line.Type != want[i].txType || line.Counterparty != want[i].counterparty || line.Amount != want[i].amount || line.Balance != want[i].balance {
+			t.Errorf("line %d = %+v, want %+v", i, line, want[i])
+		}
+	}
+
+	bob, err := Store().Get("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	statement, err = bob.Statement(time.Time{}, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statement.Opening != eur(50) || statement.Closing != eur(70) || !statement.From.Equal(today) {
+		t.Errorf("bob's statement from %v runs from %v to %v, want 50.00 to 70.00 EUR from today", statement.From, statement.Opening, statement.Closing)
+	}
+
+	// Periods without activity carry the balance.
+	statement, err = alice.Statement(today.AddDate(0, 0, 1), today.AddDate(0, 0, 2), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statement.Lines) != 0 || statement.Opening != eur(60) || statement.Closing != eur(60) {
+		t.Errorf("tomorrow's statement = %+v, want 60.00 EUR and no lines", statement)
+	}
+	statement, err = alice.Statement(today.AddDate(0, 0, -1), today, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statement.Lines) != 0 || statement.Opening != eur(100) || statement.Closing != eur(100) {
+		t.Errorf("yesterday's statement = %+v, want 100.00 EUR and no lines", statement)
+	}
+
+	if _, err := alice.Statement(today, today, ""); !errors.Is(err, ErrInvalidPeriod) {
+		t.Errorf("empty period: got %v, want %v", err, ErrInvalidPeriod)
+	}
+	if _, err := alice.Statement(today, time.Time{}, "xxx"); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("unknown currency: got %v, want %v", err, ErrUnknownCurrency)
+	}
+}
+
+func TestStatementHistoryBeforeBooks(t *testing.T) {
+	useStore(t)
+	useBooks(t)
+	openTestAccounts(t, &Account{Id: "old", Name: "Old", Balance: eur(75), Transactions: []Transactions{
+		{Type: Deposit, Amount: eur(100), Time: date(2025, 1, 5, 10, 0)},
+		{Type: Withdraw, Amount: eur(25), Time: date(2025, 2, 1, 10, 0)},
+	}})
+	old, err := Store().Get("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statement, err := old.Statement(date(2025, 2, 1, 0, 0), date(2025, 3, 1, 0, 0), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statement.Opening != eur(100) || statement.Closing != eur(75) || len(statement.Lines) != 1 {
+		t.Fatalf("statement = %+v, want one withdrawal from 100.00 to 75.00 EUR", statement)
+	}
+	if line := statement.Lines[0]; line.Id != "old-2" || line.Amount != eur(-25) {
+		t.Errorf("line = %+v, want old-2 over -25.00 EUR", line)
+	}
+}
+
+func TestStatementExports(t *testing.T) {
+	alice := statementFixture(t)
+	today := startOfDay(time.Now())
+	statement, err := alice.Statement(today, today.AddDate(0, 0, 1), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := statement.WriteCSV(&buf); err != nil {
+			t.Fatal(err)
+		}
+		rows, err := csv.NewReader(&buf).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 6 {
+			t.Fatalf("got %d rows, want a header, opening, 3 lines and closing: %q", len(rows), rows)
+		}
+		if got := rows[1]; got[2] != "opening balance" || got[7] != "100.00" {
+			t.Errorf("opening row = %q", got)
+		}
+		if got := rows[2]; got[1] != statement.Lines[0].Id || got[3] != "bob" || got[4] != "rent" || got[5] != "-30.00" || got[6] != "EUR" || got[7] != "70.00" {
+			t.Errorf("first line = %q", got)
+		}
+		if got := rows[5]; got[2] != "closing balance" || got[7] != "60.00" {
+			t.Errorf("closing row = %q", got)
+		}
+	})
+
+	t.Run("ofx", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := statement.WriteOFX(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(buf.Bytes(), []byte(`<?OFX OFXHEADER="200" VERSION="220"`)) {
+			t.Errorf("missing OFX header:\n%s", buf.String())
+		}
+		var doc ofxDocument
+		if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+			t.Fatal(err)
+		}
+		response := doc.Bank.Statement.Response
+		if response.Currency != "EUR" || response.Account.ID != "alice" || response.Account.Type != "CHECKING" {
+			t.Errorf("account = %+v in %s", response.Account, response.Currency)
+		}
+		var types, amounts []string
+		for _, txn := range response.List.Transactions {
+			types = append(types, txn.Type)
+			amounts = append(amounts, txn.Amount)
+		}
+		if len(types) != 3 || types[0] != "XFER" || types[2] != "DEBIT" || amounts[0] != "-30.00" || amounts[1] != "10.00" {
+			t.Errorf("transactions %q over %q, want XFER, XFER, DEBIT over -30.00, 10.00, -20.00", types, amounts)
+		}
+		if response.Ledger.Amount != "60.00" {
+			t.Errorf("ledger balance = %s, want 60.00", response.Ledger.Amount)
+		}
+	})
+
+	t.Run("camt.053", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := statement.WriteCAMT053(&buf); err != nil {
+			t.Fatal(err)
+		}
+		var doc camtDocument
+		if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc.XMLName.Space != camt053Namespace || len(doc.Statements) != 1 {
+			t.Fatalf("document %v with %d statements", doc.XMLName, len(doc.Statements))
+		}
+		stmt := doc.Statements[0]
+		if stmt.Account.IBAN == "" || stmt.Account.IBAN != statement.IBAN.String() || stmt.Account.Currency != "EUR" {
+			t.Errorf("account = %+v", stmt.Account)
+		}
+		wantBalances := []camtBalance{
+			{Code: "OPBD", Amount: camtAmount{Currency: "EUR", Value: "100.00"}, Indicator: "CRDT", Date: today.Format(time.DateOnly)},
+			{Code: "CLBD", Amount: camtAmount{Currency: "EUR", Value: "60.00"}, Indicator: "CRDT", Date: today.Format(time.DateOnly)},
+		}
+		if len(stmt.Balances) != 2 || stmt.Balances[0] != wantBalances[0] || stmt.Balances[1] != wantBalances[1] {
+			t.Errorf("balances = %+v, want %+v", stmt.Balances, wantBalances)
+		}
+		if len(stmt.Entries) != 3 {
+			t.Fatalf("got %d entries, want 3", len(stmt.Entries))
+		}
+		out, in := stmt.Entries[0], stmt.Entries[1]
+		if out.Amount.Value != "30.00" || out.Indicator != "DBIT" || out.Details.CreditorAccount == nil || out.Details.CreditorAccount.ID() != "bob" || out.Details.Remittance.Unstructured[0] != "rent" {
+			t.Errorf("outgoing transfer = %+v, details %+v", out, out.Details)
+		}
+		if in.Amount.Value != "10.00" || in.Indicator != "CRDT" || in.Details.DebtorAccount == nil || in.Details.DebtorAccount.ID() != "bob" {
+			t.Errorf("incoming transfer = %+v, details %+v", in, in.Details)
+		}
+	})
+}
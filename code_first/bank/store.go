@@ -0,0 +1,145 @@
+package bank
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrAccountNotFound  = errors.New("could not find account")
+	ErrAccountExists    = errors.New("account already exists")
+	ErrInvalidAccountID = errors.New("invalid account id")
+)
+
+type AccountStore interface {
+	Get(id string) (*Account, error)
+	List() ([]Account, error)
+	Save(account *Account) error
+	SaveAll(accounts ...*Account) error
+	Delete(id string) error
+	FindByName(name string) (*Account, error)
+}
+
+var store AccountStore = NewJSONStore(dbFile)
+
+func SetStore(s AccountStore) {
+	store = s
+}
+
+func Store() AccountStore {
+	return store
+}
+
+func NewStore(kind, path string) (AccountStore, error) {
+	switch strings.ToLower(kind) {
+	case "", "json":
+		if path == "" {
+			path = dbFile
+		}
+		return NewJSONStore(path), nil
+	case "memory":
+		return NewMemoryStore(), nil
+	case "dir":
+		if path == "" {
+			path = "accounts"
+		}
+		return NewDirStore(path), nil
+	case "events":
+		if path == "" {
+			path = "ledger"
+		}
+		return NewEventStore(path)
+	default:
+		return nil, fmt.Errorf("unknown account store: %s", kind)
+	}
+}
+
+type MemoryStore struct {
+	mu       sync.RWMutex
+	accounts map[string]Account
+}
+
+func NewMemoryStore(accounts ...Account) *MemoryStore {
+	ms := &MemoryStore{accounts: map[string]Account{}}
+	for _, acc := range accounts {
+		ms.accounts[acc.Id] = acc.clone()
+	}
+	return ms
+}
+
+func (ms *MemoryStore) Get(id string) (*Account, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	acc, ok := ms.accounts[id]
+	if !ok {
+		return nil, ErrAccountNotFound
+	}
+	acc = acc.clone()
+	return &acc, nil
+}
+
+func (ms *MemoryStore) List() ([]Account, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	accounts := make([]Account, 0, len(ms.accounts))
+	for _, acc := range ms.accounts {
+		accounts = append(accounts, acc.clone())
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Id < accounts[j].Id })
+	return accounts, nil
+}
+
+func (ms *MemoryStore) Save(account *Account) error {
+	return ms.SaveAll(account)
+}
+
+func (ms *MemoryStore) SaveAll(accounts ...*Account) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for _, account := range accounts {
+		ms.accounts[account.Id] = account.clone()
+	}
+	return nil
+}
+
+func (ms *MemoryStore) Delete(id string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.accounts[id]; !ok {
+		return ErrAccountNotFound
+	}
+	delete(ms.accounts, id)
+	return nil
+}
+
+func (ms *MemoryStore) FindByName(name string) (*Account, error) {
+	accounts, err := ms.List()
+	if err != nil {
+		return nil, err
+	}
+	return findByName(accounts, name)
+}
+
+func findByName(accounts []Account, name string) (*Account, error) {
+	for i, account := range accounts {
+		if strings.EqualFold(account.Name, name) {
+			return &accounts[i], nil
+		}
+	}
+	return nil, ErrAccountNotFound
+}
+
+func (account Account) clone() Account {
+	account.Transactions = append([]Transactions(nil), account.Transactions...)
+	account.SubBalances = maps.Clone(account.SubBalances)
+	account.pending = nil
+	return account
+}
@@ -0,0 +1,242 @@
+package bank
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccountStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) AccountStore{
+		"memory": func(t *testing.T) AccountStore { return NewMemoryStore() },
+		"json": func(t *testing.T) AccountStore {
+			return NewJSONStore(filepath.Join(t.TempDir(), dbFile))
+		},
+		"dir": func(t *testing.T) AccountStore { return NewDirStore(t.TempDir()) },
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+
+			if accounts, err := s.List(); err != nil || len(accounts) != 0 {
+				t.Fatalf("empty store List() = %v, %v", accounts, err)
+			}
+
+			alice := &Account{Id: "1", Name: "Alice", Balance: eur(100), AccountType: Giro}
+			bob := &Account{Id: "2", Name: "Bob", Balance: eur(50), AccountType: Savings}
+			for _, acc := range []*Account{bob, alice} {
+				if err := s.Save(acc); err != nil {
+					t.Fatalf("Save(%s) error = %v", acc.Id, err)
+				}
+			}
+
+			alice.Balance = eur(150)
+			if err := s.Save(alice); err != nil {
+				t.Fatalf("Save(update) error = %v", err)
+			}
+
+			got, err := s.Get("1")
+			if err != nil || got.Balance != eur(150) {
+				t.Errorf("Get(1) = %v, %v, want balance 150", got, err)
+			}
+
+			got, err = s.FindByName("bob")
+			if err != nil || got.Id != "2" {
+				t.Errorf("FindByName(bob) = %v, %v, want id 2", got, err)
+			}
+
+			accounts, err := s.List()
+			if err != nil || len(accounts) != 2 {
+				t.Errorf("List() = %v, %v", accounts, err)
+			}
+
+			if err := s.Delete("2"); err != nil {
+				t.Errorf("Delete(2) error = %v", err)
+			}
+			if _, err := s.Get("2"); !errors.Is(err, ErrAccountNotFound) {
+				t.Errorf("Get(2) after delete error = %v, want ErrAccountNotFound", err)
+			}
+			if err := s.Delete("2"); !errors.Is(err, ErrAccountNotFound) {
+				t.Errorf("Delete(2) twice error = %v, want ErrAccountNotFound", err)
+			}
+			if _, err := s.FindByName("Charlie"); !errors.Is(err, ErrAccountNotFound) {
+				t.Errorf("FindByName(Charlie) error = %v, want ErrAccountNotFound", err)
+			}
+		})
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	tests := map[string]struct {
+		kind    string
+		wantErr bool
+	}{
+		"Happy Path: default": {kind: ""},
+		"Happy Path: json":    {kind: "json"},
+		"Happy Path: memory":  {kind: "Memory"},
+		"Happy Path: dir":     {kind: "dir"},
+		"Happy Path: events":  {kind: "events"},
+		"Unhappy Path: sql":   {kind: "sql", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := NewStore(tc.kind, t.TempDir())
+			if tc.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil || s == nil {
+				t.Errorf("NewStore(%q) = %v, %v", tc.kind, s, err)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreReturnsCopies(t *testing.T) {
+	ms := NewMemoryStore(Account{Id: "1", Name: "Alice", Balance: eur(100)})
+
+	acc, _ := ms.Get("1")
+	acc.Balance = Money{}
+
+	got, _ := ms.Get("1")
+	if got.Balance != eur(100) {
+		t.Errorf("balance = %v, want 100", got.Balance)
+	}
+}
+
+func TestSaveAll(t *testing.T) {
+	stores := map[string]func(t *testing.T) AccountStore{
+		"memory": func(t *testing.T) AccountStore { return NewMemoryStore() },
+		"json": func(t *testing.T) AccountStore {
+			return NewJSONStore(filepath.Join(t.TempDir(), dbFile))
+		},
+		"dir": func(t *testing.T) AccountStore { return NewDirStore(t.TempDir()) },
+		"events": func(t *testing.T) AccountStore {
+			es, err := NewEventStore(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return es
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+
+			alice := &Account{Id: "1", Name: "Alice", Balance: eur(100)}
+			bob := &Account{Id: "2", Name: "Bob", Balance: eur(50)}
+			if err := s.SaveAll(alice, bob); err != nil {
+				t.Fatalf("SaveAll() error = %v", err)
+			}
+
+			accounts, err := s.List()
+			if err != nil || len(accounts) != 2 {
+				t.Errorf("List() = %v, %v", accounts, err)
+			}
+		})
+	}
+}
+
+func TestDirStoreRejectsBatchWithInvalidID(t *testing.T) {
+	ds := NewDirStore(t.TempDir())
+
+	err := ds.SaveAll(&Account{Id: "1", Name: "Alice"}, &Account{Id: "../escape", Name: "Mallory"})
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if _, err := ds.Get("1"); !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("Alice was written although the batch failed: %v", err)
+	}
+}
+
+func TestJSONStoreWritesAtomically(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, dbFile)
+	js := NewJSONStore(path)
+
+	if err := js.Save(&Account{Id: "1", Name: "Alice", Balance: eur(1)}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp-") {
+			t.Errorf("temporary file left behind: %s", entry.Name())
+		}
+	}
+}
+
+func TestDirStoreRecoversInterruptedTransaction(t *testing.T) {
+	dir := t.TempDir()
+	ds := NewDirStore(dir)
+	if err := ds.SaveAll(&Account{Id: "1", Name: "Alice", Balance: eur(100)}, &Account{Id: "2", Name: "Bob"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash after the transaction file was written but before
+	// the account files were replaced.
+	pending := []*Account{
+		{Id: "1", Name: "Alice", Balance: eur(70)},
+		{Id: "2", Name: "Bob", Balance: eur(30)},
+	}
+	data, _ := json.Marshal(pending)
+	if err := os.WriteFile(filepath.Join(dir, dirStoreTxnFile), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := NewDirStore(dir)
+	for _, want := range pending {
+		got, err := reopened.Get(want.Id)
+		if err != nil || got.Balance != want.Balance {
+			t.Errorf("Get(%s) = %v, %v, want balance %v", want.Id, got, err, want.Balance)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, dirStoreTxnFile)); !os.IsNotExist(err) {
+		t.Errorf("transaction file still present: %v", err)
+	}
+}
+
+func TestLockFileExcludesOtherHolders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	unlock, err := lockFile(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		second, err := lockFile(path, true)
+		if err == nil {
+			second()
+		}
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second exclusive lock acquired while the first was held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("second lock not acquired after unlock")
+	}
+}
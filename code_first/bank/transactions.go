@@ -0,0 +1,196 @@
+package bank
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTransactionNotFound = errors.New("could not find transaction")
+	ErrAlreadyReversed     = errors.New("transaction is already reversed")
+	ErrNotReversible       = errors.New("transaction cannot be reversed")
+)
+
+// Booking is a transaction as it appears on one account. Both sides of a
+// transfer share the transaction id.
+type Booking struct {
+	AccountID string
+	Transactions
+}
+
+func newTransactionID() string {
+	return newID("tx")
+}
+
+func referenceText(reference []string) string {
+	return strings.TrimSpace(strings.Join(reference, " "))
+}
+
+// FindTransaction returns every booking of the transaction.
+func FindTransaction(txID string) ([]Booking, error) {
+	if txID == "" {
+		return nil, ErrTransactionNotFound
+	}
+
+	accounts, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var bookings []Booking
+	for _, acc := range accounts {
+		if txn, ok := acc.transaction(txID); ok {
+			bookings = append(bookings, Booking{AccountID: acc.Id, Transactions: txn})
+		}
+	}
+	if len(bookings) == 0 {
+		return nil, ErrTransactionNotFound
+	}
+	return bookings, nil
+}
+
+// Reverse books a compensating transaction that undoes txID on every account
+// it touched and returns its id. The original stays in the history, marked as
+// reversed and linked to the compensating transaction. SEPA payments can
+// only be reversed once they are rejected.
+func Reverse(txID string, reference ...string) (string, error) {
+	entry, ok := books.EntryForTransaction(txID)
+	if !ok {
+		return "", ErrTransactionNotFound
+	}
+	// The money of a SEPA payment is on its way to the other bank until
+	// the payment is rejected, which reverses it.
+	if payment, err := payments.Get(txID); err == nil && payment.Status != PaymentRejected {
+		return "", fmt.Errorf("%w: SEPA payment %s is %s", ErrNotReversible, txID, payment.Status)
+	}
+
+	var accounts []*Account
+	seen := map[string]bool{}
+	for _, leg := range entry.Legs {
+		if IsInternalAccount(leg.Account) || seen[leg.Account] {
+			continue
+		}
+		seen[leg.Account] = true
+		acc, err := store.Get(leg.Account)
+		if err != nil {
+			return "", fmt.Errorf("reversing %s: %w", txID, err)
+		}
+		accounts = append(accounts, acc)
+	}
+
+	reversal := entry.Reversal()
+	reversal.TransactionID = newTransactionID()
+	ref := referenceText(reference)
+
+	err := update(func() error {
+		for i, acc := range accounts {
+			txn, ok := acc.transaction(txID)
+			if !ok {
+				return ErrTransactionNotFound
+			}
+			if txn.Status == StatusReversed {
+				return ErrAlreadyReversed
+			}
+			if txn.Type == Reversal {
+				return ErrNotReversible
+			}
+
+			// An exchange moves money between two currencies of the
+			// same account, so the reversal is booked per currency.
+			deltas := map[Currency]Money{}
+			for _, leg := range reversal.Legs {
+				if leg.Account != acc.Id {
+					continue
+				}
+				amount := leg.Amount
+				if leg.Side == Debit {
+					amount = amount.Neg()
+				}
+				delta, err := deltas[amount.Currency].WithCurrency(amount.Currency).Add(amount)
+				if err != nil {
+					return err
+				}
+				deltas[amount.Currency] = delta
+			}
+
+			var debits []Money
+			for _, delta := range deltas {
+				if delta.IsNegative() {
+					debits = append(debits, delta.Neg())
+				}
+			}
+			if err := acc.covers(debits...); err != nil {
+				return err
+			}
+
+			counterparty := ""
+			if len(accounts) == 2 {
+				counterparty = accounts[1-i].Id
+			}
+			for _, currency := range slices.Sorted(maps.Keys(deltas)) {
+				err := acc.record(Event{
+					Type:          TransactionReversed,
+					Amount:        deltas[currency],
+					Counterparty:  counterparty,
+					TransactionID: reversal.TransactionID,
+					Reference:     ref,
+					Reverses:      txID,
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return postAndSave(reversal, accounts...)
+	}, accounts...)
+	if err != nil {
+		return "", err
+	}
+	return reversal.TransactionID, nil
+}
+
+// IsInternalAccount reports whether the ledger account belongs to the bank
+// rather than to a customer.
+func IsInternalAccount(id string) bool {
+	return strings.HasPrefix(id, "internal:")
+}
+
+func (account *Account) transaction(txID string) (Transactions, bool) {
+	for _, txn := range account.Transactions {
+		if txn.Id == txID {
+			return txn, true
+		}
+	}
+	return Transactions{}, false
+}
+
+var transactionsCSVHeader = []string{"Date", "Transaction ID", "Type", "Counterparty", "Reference", "Amount", "Currency", "Status"}
+
+// WriteTransactionsCSV writes one row per transaction. Amounts are unsigned
+// as they are stored, the type tells the direction.
+func WriteTransactionsCSV(w io.Writer, transactions []Transactions) error {
+	out := csv.NewWriter(w)
+	rows := [][]string{transactionsCSVHeader}
+	for _, txn := range transactions {
+		rows = append(rows, []string{
+			txn.Time.UTC().Format(time.RFC3339),
+			txn.Id,
+			string(txn.Type),
+			txn.Counterparty,
+			txn.Reference,
+			txn.Amount.Decimal(),
+			txn.Amount.Currency.Code(),
+			string(txn.Status),
+		})
+	}
+	if err := out.WriteAll(rows); err != nil {
+		return err
+	}
+	return out.Error()
+}
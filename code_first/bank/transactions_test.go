@@ -0,0 +1,183 @@
+package bank
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTransactionIDs(t *testing.T) {
+	useStore(t)
+	useBooks(t)
+
+	alice := &Account{Id: "alice", Name: "Alice", Balance: eur(100), AccountType: Savings}
+	bob := &Account{Id: "bob", Name: "Bob", Balance: eur(0), AccountType: Savings}
+	for _, acc := range []*Account{alice, bob} {
+		if err := OpenAccount(acc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := alice.Deposit(eur(10), "salary"); err != nil {
+		t.Fatal(err)
+	}
+	if err := alice.Transfer(eur(30), "Bob", "rent", "march"); err != nil {
+		t.Fatal(err)
+	}
+
+	deposit := alice.Transactions[0]
+	if deposit.Id == "" || deposit.Reference != "salary" || deposit.Status != StatusBooked {
+		t.Errorf("deposit = %+v, want id, reference salary and status booked", deposit)
+	}
+
+	out := alice.Transactions[1]
+	if out.Id == deposit.Id {
+		t.Error("transactions share an id")
+	}
+	if out.Counterparty != "bob" || out.Reference != "rent march" {
+		t.Errorf("outgoing transfer = %+v, want counterparty bob and reference \"rent march\"", out)
+	}
+
+	bookings, err := FindTransaction(out.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bookings) != 2 || bookings[0].AccountID != "alice" || bookings[1].AccountID != "bob" {
+		t.Errorf("bookings = %+v, want one on alice and one on bob", bookings)
+	}
+	if bookings[1].Counterparty != "alice" {
+		t.Errorf("incoming transfer counterparty = %q, want alice", bookings[1].Counterparty)
+	}
+
+	if _, err := FindTransaction("tx_unknown"); !errors.Is(err, ErrTransactionNotFound) {
+		t.Errorf("unknown transaction: got %v, want %v", err, ErrTransactionNotFound)
+	}
+}
+
+func TestReverse(t *testing.T) {
+	useStore(t)
+	b := useBooks(t)
+
+	alice := &Account{Id: "alice", Name: "Alice", Balance: eur(100), AccountType: Savings}
+	bob := &Account{Id: "bob", Name: "Bob", Balance: eur(0), AccountType: Savings}
+	for _, acc := range []*Account{alice, bob} {
+		if err := OpenAccount(acc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := alice.Transfer(eur(40), "Bob"); err != nil {
+		t.Fatal(err)
+	}
+	transferID := alice.Transactions[0].Id
+
+	reversalID, err := Reverse(transferID, "refund")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		account *Account
+		balance Money
+		delta   Money
+	}{
+		{alice, eur(100), eur(40)},
+		{bob, eur(0), eur(-40)},
+	} {
+		acc, err := Store().Get(tc.account.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if acc.Balance != tc.balance {
+			t.Errorf("%s balance = %v, want %v", acc.Id, acc.Balance, tc.balance)
+		}
+
+		original, _ := acc.transaction(transferID)
+		if original.Status != StatusReversed || original.ReversedBy != reversalID {
+			t.Errorf("%s original = %+v, want reversed by %s", acc.Id, original, reversalID)
+		}
+		reversal, _ := acc.transaction(reversalID)
+		if reversal.Type != Reversal || reversal.Reverses != transferID || reversal.Amount != tc.delta || reversal.Reference != "refund" {
+			t.Errorf("%s reversal = %+v, want reversal of %s by %v", acc.Id, reversal, transferID, tc.delta)
+		}
+	}
+
+	entry, ok := b.EntryForTransaction(reversalID)
+	if !ok {
+		t.Fatal("reversal was not posted to the books")
+	}
+	if original, _ := b.EntryForTransaction(transferID); entry.ReversalOf != original.ID {
+		t.Errorf("reversal entry links to %q, want %q", entry.ReversalOf, original.ID)
+	}
+	if _, err := b.TrialBalance(); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := Reverse(transferID); !errors.Is(err, ErrAlreadyReversed) {
+		t.Errorf("second reversal: got %v, want %v", err, ErrAlreadyReversed)
+	}
+	if _, err := Reverse(reversalID); !errors.Is(err, ErrNotReversible) {
+		t.Errorf("reversing a reversal: got %v, want %v", err, ErrNotReversible)
+	}
+	if _, err := Reverse("tx_unknown"); !errors.Is(err, ErrTransactionNotFound) {
+		t.Errorf("unknown transaction: got %v, want %v", err, ErrTransactionNotFound)
+	}
+}
+
+func TestReverseRespectsBalance(t *testing.T) {
+	useStore(t)
+	useBooks(t)
+
+	acc := &Account{Id: "carol", Name: "Carol", Balance: eur(0), AccountType: Savings}
+	if err := OpenAccount(acc); err != nil {
+		t.Fatal(err)
+	}
+	if err := acc.Deposit(eur(50)); err != nil {
+		t.Fatal(err)
+	}
+	depositID := acc.Transactions[0].Id
+	if err := acc.Withdraw(eur(30)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Reverse(depositID); err == nil {
+		t.Fatal("reversing the deposit overdrew a savings account")
+	}
+
+	stored, err := Store().Get("carol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Balance != eur(20) || stored.Transactions[0].Status != StatusBooked {
+		t.Errorf("failed reversal changed the account: %+v", stored)
+	}
+}
+
+func TestReverseReplaysFromJournal(t *testing.T) {
+	dir := t.TempDir()
+	useEventStore(t, dir)
+	useBooks(t)
+
+	acc := &Account{Id: "dave", Name: "Dave", Balance: eur(10), AccountType: Savings}
+	if err := OpenAccount(acc); err != nil {
+		t.Fatal(err)
+	}
+	if err := acc.Deposit(eur(5)); err != nil {
+		t.Fatal(err)
+	}
+	reversalID, err := Reverse(acc.Transactions[0].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	useEventStore(t, dir)
+	replayed, err := Store().Get("dave")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Balance != eur(10) {
+		t.Errorf("replayed balance = %v, want 10", replayed.Balance)
+	}
+	if replayed.Transactions[0].ReversedBy != reversalID {
+		t.Errorf("replayed original = %+v, want reversed by %s", replayed.Transactions[0], reversalID)
+	}
+}
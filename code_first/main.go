@@ -1,18 +1,454 @@
 package main
 
 import (
+	"code_first/bank"
 	"code_first/server"
+	"context"
+	"errors"
 	"fmt"
+	"math/big"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func main() {
 	fmt.Println("Code First application is getting started")
 
-	err := server.InitializeAcc(os.Args)
+	store, err := bank.NewStore(os.Getenv("BANK_STORE"), os.Getenv("BANK_STORE_PATH"))
 	if err != nil {
+		fmt.Println(err)
 		os.Exit(1)
 	}
+	if events, ok := store.(*bank.EventStore); ok {
+		events.OnSnapshotError = func(err error) { fmt.Println("could not write snapshot:", err) }
+	}
+	bank.SetStore(store)
+
+	booksPath := os.Getenv("BANK_BOOKS")
+	if booksPath == "" {
+		booksPath = "books.jsonl"
+	}
+	books, err := bank.NewBooks(booksPath)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	bank.SetBooks(books)
+
+	customersPath := os.Getenv("BANK_CUSTOMERS")
+	if customersPath == "" {
+		customersPath = "customers.json"
+	}
+	customers, err := bank.NewCustomerStore(customersPath)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	bank.SetCustomers(customers)
+
+	idempotencyPath := os.Getenv("BANK_IDEMPOTENCY")
+	if idempotencyPath == "" {
+		idempotencyPath = "idempotency.json"
+	}
+	retention := bank.DefaultIdempotencyRetention
+	if value := os.Getenv("BANK_IDEMPOTENCY_RETENTION"); value != "" {
+		if retention, err = time.ParseDuration(value); err != nil {
+			fmt.Println("invalid BANK_IDEMPOTENCY_RETENTION:", err)
+			os.Exit(1)
+		}
+	}
+	idempotency, err := bank.NewIdempotencyStore(idempotencyPath, retention)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	bank.SetIdempotencyStore(idempotency)
+
+	ordersPath := os.Getenv("BANK_STANDING_ORDERS")
+	if ordersPath == "" {
+		ordersPath = "standing_orders.json"
+	}
+	orders, err := bank.NewStandingOrderStore(ordersPath)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	bank.SetStandingOrders(orders)
+
+	products := map[bank.AccountType]bank.InterestProduct{bank.Savings: bank.DefaultSavingsProduct}
+	if productsPath := os.Getenv("BANK_INTEREST_PRODUCTS"); productsPath != "" {
+		if products, err = bank.LoadInterestProducts(productsPath); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	interestPath := os.Getenv("BANK_INTEREST")
+	if interestPath == "" {
+		interestPath = "interest.json"
+	}
+	interest, err := bank.NewInterestEngine(interestPath, products)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	feeSchedules := bank.DefaultFeeSchedules
+	if schedulesPath := os.Getenv("BANK_FEE_SCHEDULES"); schedulesPath != "" {
+		if feeSchedules, err = bank.LoadFeeSchedules(schedulesPath); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	feesPath := os.Getenv("BANK_FEES")
+	if feesPath == "" {
+		feesPath = "fees.json"
+	}
+	fees, err := bank.NewFeeEngine(feesPath, feeSchedules)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	bank.SetFees(fees)
+
+	ratesTTL := bank.DefaultRatesTTL
+	if value := os.Getenv("BANK_RATES_TTL"); value != "" {
+		if ratesTTL, err = time.ParseDuration(value); err != nil {
+			fmt.Println("invalid BANK_RATES_TTL:", err)
+			os.Exit(1)
+		}
+	}
+	var rates bank.ExchangeRateProvider = bank.NewCachedRateProvider(
+		bank.NewFrankfurterProvider(os.Getenv("BANK_RATES_URL"), bank.DefaultRatesTimeout), ratesTTL)
+	if ratesPath := os.Getenv("BANK_RATES_FILE"); ratesPath != "" {
+		static, err := bank.LoadStaticRates(ratesPath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		rates = bank.ChainRateProvider{rates, static}
+	}
+	bank.SetRates(rates)
+
+	quotesPath := os.Getenv("BANK_FX_QUOTES")
+	if quotesPath == "" {
+		quotesPath = "fx_quotes.json"
+	}
+	quotes, err := bank.NewQuoteStore(quotesPath)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if value := os.Getenv("BANK_FX_QUOTE_TTL"); value != "" {
+		if quotes.TTL, err = time.ParseDuration(value); err != nil {
+			fmt.Println("invalid BANK_FX_QUOTE_TTL:", err)
+			os.Exit(1)
+		}
+	}
+	if value := os.Getenv("BANK_FX_SPREAD"); value != "" {
+		spread, ok := new(big.Rat).SetString(value)
+		if !ok || spread.Sign() < 0 || spread.Cmp(big.NewRat(1, 1)) >= 0 {
+			fmt.Println("invalid BANK_FX_SPREAD:", value)
+			os.Exit(1)
+		}
+		quotes.Spread = spread
+	}
+	bank.SetQuotes(quotes)
+
+	paymentsPath := os.Getenv("BANK_PAYMENTS")
+	if paymentsPath == "" {
+		paymentsPath = "payments.json"
+	}
+	payments, err := bank.NewPaymentStore(paymentsPath)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	bank.SetPayments(payments)
+
+	ibanIssuer := bank.DefaultIBANIssuer
+	if value := os.Getenv("BANK_IBAN_COUNTRY"); value != "" {
+		ibanIssuer.Country = value
+	}
+	if value := os.Getenv("BANK_IBAN_BANK_CODE"); value != "" {
+		ibanIssuer.BankCode = value
+	}
+	if value := os.Getenv("BANK_BIC"); value != "" {
+		ibanIssuer.BIC = value
+	}
+	if err := bank.SetIBANIssuer(ibanIssuer); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := errors.Join(bank.Migrate(store), bank.AssignIBANs()); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "register" {
+		if err := register(os.Args[2:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := importStatement(os.Args[2:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "exchange" {
+		if err := backdatedExchange(os.Args[2:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "reverse" {
+		if len(os.Args) < 3 {
+			fmt.Println("usage: reverse <transaction-id> [reference]")
+			os.Exit(1)
+		}
+		reversalID, err := bank.Reverse(os.Args[2], os.Args[3:]...)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println("booked reversal", reversalID)
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "sepa-export" {
+		if len(os.Args) != 3 {
+			fmt.Println("usage: sepa-export <pain.001-file>")
+			os.Exit(1)
+		}
+		if err := exportPayments(os.Args[2]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "sepa-status" {
+		if len(os.Args) != 3 {
+			fmt.Println("usage: sepa-status <pain.002-file>")
+			os.Exit(1)
+		}
+		if err := applyStatusReport(os.Args[2]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "status" {
+		if len(os.Args) != 4 {
+			fmt.Println("usage: status <account-id> <active|frozen|closed>")
+			os.Exit(1)
+		}
+		if err := bank.SetAccountStatus(os.Args[2], bank.AccountStatus(os.Args[3])); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "accrue" {
+		asOf := time.Now()
+		if len(os.Args) > 2 {
+			if asOf, err = time.Parse("2006-01-02", os.Args[2]); err != nil {
+				fmt.Println("usage: accrue [YYYY-MM-DD]")
+				os.Exit(1)
+			}
+		}
+		if err := errors.Join(interest.Run(asOf), fees.Run(asOf)); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	err = server.InitializeAcc(os.Args)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	go bank.NewScheduler(orders).Run(context.Background())
+	go interest.RunEvery(context.Background(), time.Hour)
+	go fees.RunEvery(context.Background(), time.Hour)
 
 	server.Router()
 }
+
+// register creates a customer and hands the listed accounts over to them:
+//
+//	code_first register <name> <password> [account-id...]
+func register(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: register <name> <password> [account-id...]")
+	}
+
+	customer, err := bank.Customers().Register(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	for _, id := range args[2:] {
+		if err := bank.AssignOwner(id, customer.Id); err != nil {
+			return fmt.Errorf("assigning account %s: %w", id, err)
+		}
+	}
+	fmt.Println("registered customer", customer.Id)
+	return nil
+}
+
+// importStatement loads another bank's statement file into an account as
+// part of a migration. The options are those of bank.ParseCSVMapping plus
+// format and dry_run:
+//
+//	code_first import <account-id> <file> [option=value...]
+func importStatement(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: import <account-id> <file> [format=csv|mt940|camt.053] [dry_run=true] [option=value...]")
+	}
+
+	values := url.Values{}
+	for _, arg := range args[2:] {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return fmt.Errorf("option %q is not of the form name=value", arg)
+		}
+		values.Set(name, value)
+	}
+
+	opts := bank.ImportOptions{Format: bank.ImportCSV}
+	var err error
+	if format := values.Get("format"); format != "" {
+		if opts.Format, err = bank.ParseImportFormat(format); err != nil {
+			return err
+		}
+	}
+	if opts.Format == bank.ImportCSV {
+		if opts.CSV, err = bank.ParseCSVMapping(values); err != nil {
+			return err
+		}
+	}
+	if value := values.Get("dry_run"); value != "" {
+		if opts.DryRun, err = strconv.ParseBool(value); err != nil {
+			return errors.New("dry_run must be true or false")
+		}
+	}
+
+	account, err := bank.Store().Get(args[0])
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(args[1])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	result, err := account.ImportStatement(file, opts)
+	if err != nil {
+		return err
+	}
+	verb := "imported"
+	if result.DryRun {
+		verb = "would import"
+	}
+	fmt.Printf("%s %d lines, skipped %d duplicates\n", verb, len(result.Imported), len(result.Duplicates))
+	for _, lineErr := range result.Errors {
+		fmt.Println(lineErr)
+	}
+	return nil
+}
+
+// backdatedExchange books an exchange at the rate of a past value date, to
+// correct a booking the customer was due on that day:
+//
+//	code_first exchange <account-id> <amount> <target> <YYYY-MM-DD> [reference]
+func backdatedExchange(args []string) error {
+	if len(args) < 4 {
+		return errors.New("usage: exchange <account-id> <amount> <target> <YYYY-MM-DD> [reference]")
+	}
+
+	account, err := bank.Store().Get(args[0])
+	if err != nil {
+		return err
+	}
+	amount, err := bank.ParseMoney(args[1], account.BaseCurrency())
+	if err != nil {
+		return err
+	}
+	target, err := bank.ParseCurrency(args[2])
+	if err != nil {
+		return err
+	}
+	valueDate, err := time.Parse(time.DateOnly, args[3])
+	if err != nil {
+		return fmt.Errorf("invalid value date %q, want YYYY-MM-DD", args[3])
+	}
+
+	bought, err := account.ExchangeAt(amount, target, valueDate, args[4:]...)
+	if err != nil {
+		return err
+	}
+	fmt.Println("bought", bought)
+	return nil
+}
+
+// exportPayments writes the queued SEPA payments to a new pain.001 file for
+// the clearing partner:
+//
+//	code_first sepa-export <pain.001-file>
+func exportPayments(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+
+	submitted, err := bank.Payments().ExportPain001(file)
+	if err != nil {
+		return errors.Join(err, file.Close(), os.Remove(path))
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("submitted %d payments with message %s\n", len(submitted), submitted[0].MessageID)
+	return nil
+}
+
+// applyStatusReport settles the payments a pain.002 status report of the
+// clearing partner answers:
+//
+//	code_first sepa-status <pain.002-file>
+func applyStatusReport(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	report, err := bank.Payments().ApplyStatusReport(file)
+	if report != nil {
+		fmt.Printf("message %s: %d accepted, %d rejected\n", report.MessageID, len(report.Accepted), len(report.Rejected))
+		for _, id := range report.Unknown {
+			fmt.Println("unknown payment", id)
+		}
+	}
+	return err
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"code_first/bank"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccountResponse is an account, written as text like /show and as CSV of
+// its transactions.
+type AccountResponse struct {
+	*bank.Account
+}
+
+func (r AccountResponse) WriteText(w io.Writer) error {
+	return r.WriteSummary(w, r.Transactions)
+}
+
+func (r AccountResponse) WriteCSV(w io.Writer) error {
+	return bank.WriteTransactionsCSV(w, r.Transactions)
+}
+
+// BookingResponse is the account as a booking left it with the
+// transactions the booking added, fees included, so clients see both the
+// new balance and what was booked.
+type BookingResponse struct {
+	bank.Account
+	Booked []bank.Transactions
+}
+
+func bookingResponse(receipt *bank.Receipt) BookingResponse {
+	return BookingResponse{Account: receipt.Account, Booked: receipt.Transactions}
+}
+
+func (r BookingResponse) WriteText(w io.Writer) error {
+	return r.WriteSummary(w, r.Booked)
+}
+
+func (r BookingResponse) WriteCSV(w io.Writer) error {
+	return bank.WriteTransactionsCSV(w, r.Booked)
+}
+
+// TransactionList is a page of transactions, written as CSV on request.
+type TransactionList []bank.Transactions
+
+func (l TransactionList) WriteCSV(w io.Writer) error {
+	return bank.WriteTransactionsCSV(w, l)
+}
+
+// NewAccount is what a customer chooses for a new account. The overdraft
+// limit is not among it, giro accounts open without one.
+type NewAccount struct {
+	Id          string           `json:"id"`
+	Name        string           `json:"name"`
+	AccountType bank.AccountType `json:"type"`
+}
+
+func createAccount(w http.ResponseWriter, req *http.Request) {
+	var newAcc NewAccount
+	if err := json.NewDecoder(req.Body).Decode(&newAcc); err != nil {
+		writeInvalidJSON(w)
+		return
+	}
+
+	if strings.TrimSpace(newAcc.Name) == "" {
+		httpError(w, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	accType := bank.AccountType(strings.ToLower(string(newAcc.AccountType)))
+	if accType != bank.Giro && accType != bank.Savings {
+		httpError(w, "give a valid account type: (giro | savings)", http.StatusBadRequest)
+		return
+	}
+
+	if newAcc.Id == "" {
+		newAcc.Id = bank.NewAccountID()
+	}
+
+	account := &bank.Account{
+		Id:          newAcc.Id,
+		Name:        newAcc.Name,
+		Balance:     bank.NewMoney(0, bank.DefaultCurrency),
+		AccountType: accType,
+		Owner:       customerFrom(req),
+	}
+
+	if err := bank.OpenAccount(account); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	respond(w, req, http.StatusCreated, AccountResponse{account})
+}
+
+func getAccount(w http.ResponseWriter, req *http.Request) {
+	account, ok := loadAccount(w, req)
+	if !ok {
+		return
+	}
+	respond(w, req, http.StatusOK, AccountResponse{account})
+}
+
+func depositToAccount(w http.ResponseWriter, req *http.Request) {
+	account, transaction, ok := accountRequest(w, req)
+	if !ok {
+		return
+	}
+
+	receipt, err := account.BookDeposit(transaction.Amount, transaction.Reference)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	respond(w, req, http.StatusOK, bookingResponse(receipt))
+}
+
+func withdrawFromAccount(w http.ResponseWriter, req *http.Request) {
+	account, transaction, ok := accountRequest(w, req)
+	if !ok {
+		return
+	}
+
+	receipt, err := account.BookWithdrawal(transaction.Amount, transaction.Reference)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	respond(w, req, http.StatusOK, bookingResponse(receipt))
+}
+
+func transferFromAccount(w http.ResponseWriter, req *http.Request) {
+	account, transaction, ok := accountRequest(w, req)
+	if !ok {
+		return
+	}
+
+	receipt, err := bookTransfer(account, transaction)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	respond(w, req, http.StatusOK, bookingResponse(receipt))
+}
+
+// exchangeOnAccount sells the amount for the target currency within the
+// account at the current rate. Backdated exchanges are booked by the back
+// office.
+func exchangeOnAccount(w http.ResponseWriter, req *http.Request) {
+	account, transaction, ok := accountRequest(w, req)
+	if !ok {
+		return
+	}
+
+	amount, err := exchangeAmount(transaction)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	receipt, err := account.BookExchange(amount, transaction.TargetCurrency, time.Time{}, transaction.Reference)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	respond(w, req, http.StatusOK, bookingResponse(receipt))
+}
+
+func listTransactions(w http.ResponseWriter, req *http.Request) {
+	account, ok := loadAccount(w, req)
+	if !ok {
+		return
+	}
+
+	query, err := bank.ParseTransactionQuery(req.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	transactions, total, err := account.QueryTransactions(query)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	respond(w, req, http.StatusOK, TransactionList(transactions))
+}
+
+func loadAccount(w http.ResponseWriter, req *http.Request) (*bank.Account, bool) {
+	account, err := bank.Store().Get(req.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return nil, false
+	}
+
+	if !account.OwnedBy(customerFrom(req)) {
+		httpError(w, "account does not belong to you", http.StatusForbidden)
+		return nil, false
+	}
+	return account, true
+}
+
+func accountRequest(w http.ResponseWriter, req *http.Request) (*bank.Account, Transaction, bool) {
+	var transaction Transaction
+
+	account, ok := loadAccount(w, req)
+	if !ok {
+		return nil, transaction, false
+	}
+
+	if err := json.NewDecoder(req.Body).Decode(&transaction); err != nil {
+		writeInvalidJSON(w)
+		return nil, transaction, false
+	}
+	return account, transaction, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"bytes"
+	"code_first/bank"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func doRequest(t *testing.T, handler http.Handler, method, path, token string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if str, ok := body.(string); ok {
+		reader = bytes.NewReader([]byte(str))
+	} else {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAccountsAPI(t *testing.T) {
+	bank.SetStore(bank.NewMemoryStore())
+	router := NewRouter()
+	token := loginAs(t, router, "alice")
+
+	for _, newAcc := range []NewAccount{
+		{Id: "a1", Name: "Alice", AccountType: bank.Giro},
+		{Id: "b1", Name: "Bob", AccountType: bank.Savings},
+	} {
+		if rr := doRequest(t, router, http.MethodPost, "/accounts", token, newAcc); rr.Code != http.StatusCreated {
+			t.Fatalf("create %s: got %d: %s", newAcc.Id, rr.Code, rr.Body.String())
+		}
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     any
+		wantCode int
+	}{
+		{"duplicate account", http.MethodPost, "/accounts", NewAccount{Id: "a1", Name: "Alice", AccountType: bank.Giro}, http.StatusConflict},
+		{"missing name", http.MethodPost, "/accounts", NewAccount{AccountType: bank.Giro}, http.StatusBadRequest},
+		{"IBAN as id", http.MethodPost, "/accounts", NewAccount{Id: "DE89 3704 0044 0532 0130 00", Name: "Mallory", AccountType: bank.Giro}, http.StatusBadRequest},
+		{"ledger account as id", http.MethodPost, "/accounts", NewAccount{Id: bank.CashAccount, Name: "Mallory", AccountType: bank.Giro}, http.StatusBadRequest},
+		{"chosen overdraft", http.MethodPost, "/accounts", map[string]string{"id": "c1", "name": "Carol", "type": "giro", "overdraw": "1000.00 EUR"}, http.StatusCreated},
+		{"withdraw without overdraft", http.MethodPost, "/accounts/c1/withdrawals", Transaction{Amount: eur(1)}, http.StatusUnprocessableEntity},
+		{"invalid type", http.MethodPost, "/accounts", NewAccount{Name: "Carol", AccountType: "credit"}, http.StatusBadRequest},
+		{"generated id", http.MethodPost, "/accounts", NewAccount{Name: "Carol", AccountType: bank.Savings}, http.StatusCreated},
+		{"get account", http.MethodGet, "/accounts/a1", nil, http.StatusOK},
+		{"unknown account", http.MethodGet, "/accounts/zz", nil, http.StatusNotFound},
+		{"deposit", http.MethodPost, "/accounts/a1/deposits", Transaction{Amount: eur(200)}, http.StatusOK},
+		{"deposit invalid json", http.MethodPost, "/accounts/a1/deposits", "{bad json}", http.StatusBadRequest},
+		{"deposit unknown account", http.MethodPost, "/accounts/zz/deposits", Transaction{Amount: eur(1)}, http.StatusNotFound},
+		{"withdraw beyond balance", http.MethodPost, "/accounts/a1/withdrawals", Transaction{Amount: eur(250)}, http.StatusUnprocessableEntity},
+		{"withdraw", http.MethodPost, "/accounts/a1/withdrawals", Transaction{Amount: eur(150)}, http.StatusOK},
+		{"withdraw over limit", http.MethodPost, "/accounts/a1/withdrawals", Transaction{Amount: eur(100)}, http.StatusUnprocessableEntity},
+		{"deposit to bob", http.MethodPost, "/accounts/b1/deposits", Transaction{Amount: eur(40)}, http.StatusOK},
+		{"transfer", http.MethodPost, "/accounts/b1/transfers", Transaction{Amount: eur(15), To: "Alice"}, http.StatusOK},
+		{"deposit usd", http.MethodPost, "/accounts/b1/deposits", Transaction{Amount: bank.NewMoney(500, bank.USD)}, http.StatusOK},
+		{"exchange into same currency", http.MethodPost, "/accounts/b1/exchanges", Transaction{Amount: bank.NewMoney(100, bank.USD), TargetCurrency: bank.USD}, http.StatusBadRequest},
+		{"deposit unknown currency", http.MethodPost, "/accounts/b1/deposits", map[string]string{"amount": "5.00 XYZ"}, http.StatusBadRequest},
+		{"exchange into unknown currency", http.MethodPost, "/accounts/b1/exchanges", map[string]string{"amount": "1.00 EUR", "target": "xyz"}, http.StatusBadRequest},
+		{"exchange with mismatching base", http.MethodPost, "/accounts/b1/exchanges", Transaction{Amount: eur(1), BaseCurrency: bank.USD, TargetCurrency: bank.GBP}, http.StatusBadRequest},
+		{"transfer to self", http.MethodPost, "/accounts/b1/transfers", Transaction{Amount: eur(1), To: "b1"}, http.StatusUnprocessableEntity},
+		{"transfer to unknown", http.MethodPost, "/accounts/b1/transfers", Transaction{Amount: eur(1), To: "zz"}, http.StatusUnprocessableEntity},
+		// Statement imports book against the opening balance and are
+		// left to the back office.
+		{"statement import", http.MethodPost, "/accounts/a1/imports", "Date,Amount\n2025-01-02,1000.00\n", http.StatusNotFound},
+		{"wrong method", http.MethodDelete, "/accounts/a1", nil, http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := doRequest(t, router, tt.method, tt.path, token, tt.body)
+			if rr.Code != tt.wantCode {
+				t.Errorf("got %d, want %d: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+		})
+	}
+
+	rr := doRequest(t, router, http.MethodGet, "/accounts/a1", token, nil)
+	var alice bank.Account
+	if err := json.Unmarshal(rr.Body.Bytes(), &alice); err != nil {
+		t.Fatal(err)
+	}
+	if alice.Balance != eur(65) {
+		t.Errorf("Alice balance = %v, want 65", alice.Balance)
+	}
+
+	rr = doRequest(t, router, http.MethodGet, "/accounts/a1/transactions?criteria=type&filter=transfer", token, nil)
+	var transactions []bank.Transactions
+	if err := json.Unmarshal(rr.Body.Bytes(), &transactions); err != nil {
+		t.Fatal(err)
+	}
+	if len(transactions) != 1 || transactions[0].Amount != eur(15) {
+		t.Errorf("transfers = %+v, want one transfer of 15", transactions)
+	}
+
+	rr = doRequest(t, router, http.MethodGet, "/accounts/a1/transactions?type=deposit,withdraw&sort=amount&limit=1", token, nil)
+	if err := json.Unmarshal(rr.Body.Bytes(), &transactions); err != nil {
+		t.Fatal(err)
+	}
+	if len(transactions) != 1 || transactions[0].Type != bank.Withdraw || rr.Header().Get("X-Total-Count") != "2" {
+		t.Errorf("smallest of %s deposits and withdrawals = %+v, want the withdrawal of 150", rr.Header().Get("X-Total-Count"), transactions)
+	}
+	if rr = doRequest(t, router, http.MethodGet, "/accounts/a1/transactions?sort=balance", token, nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("invalid query: got %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestLegacyRoutesWithoutDefaultAccount(t *testing.T) {
+	acc = nil
+	defer setupTestAccount()
+
+	rr := doRequest(t, NewRouter(), http.MethodPost, "/deposit", "", Transaction{Amount: eur(1)})
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("got %d, want %d", rr.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"code_first/bank"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const tokenTTL = 12 * time.Hour
+
+type contextKey string
+
+const customerKey contextKey = "customer"
+
+type Credentials struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+type Session struct {
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+type session struct {
+	customerID string
+	expires    time.Time
+}
+
+// sessions maps bearer tokens to the customer that logged in with them.
+var sessions = struct {
+	mu     sync.Mutex
+	tokens map[string]session
+}{tokens: map[string]session{}}
+
+func newSession(customerID string) (Session, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return Session{}, err
+	}
+
+	s := Session{Token: hex.EncodeToString(raw), Expires: time.Now().Add(tokenTTL)}
+
+	sessions.mu.Lock()
+	defer sessions.mu.Unlock()
+	sessions.tokens[s.Token] = session{customerID: customerID, expires: s.Expires}
+	return s, nil
+}
+
+func lookupSession(token string) (string, bool) {
+	sessions.mu.Lock()
+	defer sessions.mu.Unlock()
+
+	s, ok := sessions.tokens[token]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(s.expires) {
+		delete(sessions.tokens, token)
+		return "", false
+	}
+	return s.customerID, true
+}
+
+func endSession(token string) {
+	sessions.mu.Lock()
+	defer sessions.mu.Unlock()
+	delete(sessions.tokens, token)
+}
+
+func registerCustomer(w http.ResponseWriter, req *http.Request) {
+	var creds Credentials
+	if err := json.NewDecoder(req.Body).Decode(&creds); err != nil {
+		writeInvalidJSON(w)
+		return
+	}
+
+	customer, err := bank.Customers().Register(creds.Name, creds.Password)
+	if err != nil {
+		writeErrorOr(w, err, http.StatusBadRequest)
+		return
+	}
+
+	respond(w, req, http.StatusCreated, map[string]string{"id": customer.Id, "name": customer.Name})
+}
+
+func login(w http.ResponseWriter, req *http.Request) {
+	var creds Credentials
+	if err := json.NewDecoder(req.Body).Decode(&creds); err != nil {
+		writeInvalidJSON(w)
+		return
+	}
+
+	customer, err := bank.Customers().Authenticate(creds.Name, creds.Password)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s, err := newSession(customer.Id)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respond(w, req, http.StatusOK, s)
+}
+
+func logout(w http.ResponseWriter, req *http.Request) {
+	token, _ := bearerToken(req)
+	endSession(token)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func listAccounts(w http.ResponseWriter, req *http.Request) {
+	accounts, err := bank.OwnedAccounts(customerFrom(req))
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respond(w, req, http.StatusOK, accounts)
+}
+
+// authenticated rejects requests without a valid bearer token and makes the
+// customer behind the token available through customerFrom.
+func authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		token, ok := bearerToken(req)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="bank"`)
+			httpError(w, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		customerID, ok := lookupSession(token)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="bank", error="invalid_token"`)
+			httpError(w, "invalid or expired token", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, req.WithContext(context.WithValue(req.Context(), customerKey, customerID)))
+	}
+}
+
+// ownsDefaultAccount only lets the owner of the configured account use the
+// single-account routes.
+func ownsDefaultAccount(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		current, err := acc.Snapshot()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !current.OwnedBy(customerFrom(req)) {
+			httpError(w, "account does not belong to you", http.StatusForbidden)
+			return
+		}
+		next(w, req)
+	}
+}
+
+// ownsNamedAccount guards the name parameter of /show, which otherwise
+// reads any account by its holder's name.
+func ownsNamedAccount(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		name := req.URL.Query().Get("name")
+		if name != "" {
+			named, err := bank.Store().FindByName(name)
+			if err == nil && !named.OwnedBy(customerFrom(req)) {
+				httpError(w, "account does not belong to you", http.StatusForbidden)
+				return
+			}
+		}
+		next(w, req)
+	}
+}
+
+func customerFrom(req *http.Request) string {
+	customerID, _ := req.Context().Value(customerKey).(string)
+	return customerID
+}
+
+func bearerToken(req *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
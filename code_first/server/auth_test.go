@@ -0,0 +1,179 @@
+package server
+
+import (
+	"code_first/bank"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func loginAs(t *testing.T, handler http.Handler, name string) string {
+	t.Helper()
+
+	creds := Credentials{Name: name, Password: name + "-secret"}
+	rr := doRequest(t, handler, http.MethodPost, "/customers", "", creds)
+	if rr.Code != http.StatusCreated && rr.Code != http.StatusConflict {
+		t.Fatalf("register %s: got %d: %s", name, rr.Code, rr.Body.String())
+	}
+
+	rr = doRequest(t, handler, http.MethodPost, "/login", "", creds)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("login %s: got %d: %s", name, rr.Code, rr.Body.String())
+	}
+
+	var s Session
+	if err := json.Unmarshal(rr.Body.Bytes(), &s); err != nil {
+		t.Fatal(err)
+	}
+	return s.Token
+}
+
+func TestAuthentication(t *testing.T) {
+	bank.SetStore(bank.NewMemoryStore())
+	bank.SetCustomers(bank.NewMemoryCustomerStore())
+	router := NewRouter()
+
+	if rr := doRequest(t, router, http.MethodPost, "/customers", "", Credentials{Name: "carol", Password: "short"}); rr.Code != http.StatusBadRequest {
+		t.Errorf("weak password: got %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+
+	token := loginAs(t, router, "carol")
+
+	if rr := doRequest(t, router, http.MethodPost, "/customers", "", Credentials{Name: "Carol", Password: "carol-secret"}); rr.Code != http.StatusConflict {
+		t.Errorf("duplicate customer: got %d, want %d", rr.Code, http.StatusConflict)
+	}
+	if rr := doRequest(t, router, http.MethodPost, "/login", "", Credentials{Name: "carol", Password: "wrong-password"}); rr.Code != http.StatusUnauthorized {
+		t.Errorf("wrong password: got %d, want %d", rr.Code, http.StatusUnauthorized)
+	}
+	if rr := doRequest(t, router, http.MethodGet, "/accounts", "", nil); rr.Code != http.StatusUnauthorized {
+		t.Errorf("missing token: got %d, want %d", rr.Code, http.StatusUnauthorized)
+	}
+	if rr := doRequest(t, router, http.MethodGet, "/accounts", "not-a-token", nil); rr.Code != http.StatusUnauthorized {
+		t.Errorf("unknown token: got %d, want %d", rr.Code, http.StatusUnauthorized)
+	}
+	if rr := doRequest(t, router, http.MethodGet, "/accounts", token, nil); rr.Code != http.StatusOK {
+		t.Errorf("valid token: got %d, want %d", rr.Code, http.StatusOK)
+	}
+
+	if rr := doRequest(t, router, http.MethodPost, "/logout", token, nil); rr.Code != http.StatusNoContent {
+		t.Errorf("logout: got %d, want %d", rr.Code, http.StatusNoContent)
+	}
+	if rr := doRequest(t, router, http.MethodGet, "/accounts", token, nil); rr.Code != http.StatusUnauthorized {
+		t.Errorf("token after logout: got %d, want %d", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	bank.SetStore(bank.NewMemoryStore())
+	bank.SetCustomers(bank.NewMemoryCustomerStore())
+	router := NewRouter()
+
+	alice := loginAs(t, router, "alice")
+	bob := loginAs(t, router, "bob")
+
+	for token, newAcc := range map[string]NewAccount{
+		alice: {Id: "a1", Name: "Alice", AccountType: bank.Savings},
+		bob:   {Id: "b1", Name: "Bob", AccountType: bank.Savings},
+	} {
+		if rr := doRequest(t, router, http.MethodPost, "/accounts", token, newAcc); rr.Code != http.StatusCreated {
+			t.Fatalf("create %s: got %d: %s", newAcc.Id, rr.Code, rr.Body.String())
+		}
+	}
+	if rr := doRequest(t, router, http.MethodPost, "/accounts/b1/deposits", bob, Transaction{Amount: eur(50)}); rr.Code != http.StatusOK {
+		t.Fatalf("deposit: got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     any
+		wantCode int
+	}{
+		{"read other account", http.MethodGet, "/accounts/b1", nil, http.StatusForbidden},
+		{"withdraw from other account", http.MethodPost, "/accounts/b1/withdrawals", Transaction{Amount: eur(10)}, http.StatusForbidden},
+		{"transfer from other account", http.MethodPost, "/accounts/b1/transfers", Transaction{Amount: eur(10), To: "Alice"}, http.StatusForbidden},
+		{"other account transactions", http.MethodGet, "/accounts/b1/transactions", nil, http.StatusForbidden},
+		{"read own account", http.MethodGet, "/accounts/a1", nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := doRequest(t, router, tt.method, tt.path, alice, tt.body)
+			if rr.Code != tt.wantCode {
+				t.Errorf("got %d, want %d: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+		})
+	}
+
+	rr := doRequest(t, router, http.MethodGet, "/accounts", alice, nil)
+	var owned []bank.Account
+	if err := json.Unmarshal(rr.Body.Bytes(), &owned); err != nil {
+		t.Fatal(err)
+	}
+	if len(owned) != 1 || owned[0].Id != "a1" {
+		t.Errorf("alice's accounts = %+v, want only a1", owned)
+	}
+
+	bobAcc, err := bank.Store().Get("b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bobAcc.Balance != eur(50) {
+		t.Errorf("Bob balance = %v, want 50", bobAcc.Balance)
+	}
+}
+
+func TestLegacyRoutesRequireOwner(t *testing.T) {
+	setupTestAccount()
+	defer setupTestAccount()
+	bank.SetCustomers(bank.NewMemoryCustomerStore())
+	router := NewRouter()
+
+	alice := loginAs(t, router, "alice")
+	mallory := loginAs(t, router, "mallory")
+
+	aliceID := customerID(t, "alice")
+	acc.Owner = aliceID
+	if err := bank.OpenAccount(acc); err != nil {
+		t.Fatal(err)
+	}
+	if err := bank.OpenAccount(&bank.Account{Id: "bob", Name: "Bob", Balance: eur(20), AccountType: bank.Savings}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		wantCode int
+	}{
+		{"anonymous show", http.MethodGet, "/show", "", http.StatusUnauthorized},
+		{"anonymous withdraw", http.MethodPost, "/withdraw", "", http.StatusUnauthorized},
+		{"other customer withdraw", http.MethodPost, "/withdraw", mallory, http.StatusForbidden},
+		{"other customer show", http.MethodGet, "/show", mallory, http.StatusForbidden},
+		{"owner reads foreign account by name", http.MethodGet, "/show?name=Bob", alice, http.StatusForbidden},
+		{"owner show", http.MethodGet, "/show", alice, http.StatusOK},
+		{"owner withdraw", http.MethodPost, "/withdraw", alice, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := doRequest(t, router, tt.method, tt.path, tt.token, Transaction{Amount: eur(10)})
+			if rr.Code != tt.wantCode {
+				t.Errorf("got %d, want %d: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+		})
+	}
+}
+
+func customerID(t *testing.T, name string) string {
+	t.Helper()
+
+	customer, err := bank.Customers().FindByName(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return customer.Id
+}
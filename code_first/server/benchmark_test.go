@@ -13,9 +13,9 @@ func setupTestAccountForBenchmark() {
 	acc = &bank.Account{
 		Id:          "test123",
 		Name:        "",
-		Balance:     1000.0,
+		Balance:     eur(1000.0),
 		AccountType: bank.Giro,
-		Overdraw:    500.0,
+		Overdraw:    eur(500.0),
 	}
 }
 
@@ -33,7 +33,7 @@ func BenchmarkShowAccountDetails(b *testing.B) {
 func BenchmarkDeposit(b *testing.B) {
 	setupTestAccountForBenchmark()
 
-	transaction := Transaction{Amount: 100.0}
+	transaction := Transaction{Amount: eur(100.0)}
 	jsonData, _ := json.Marshal(transaction)
 
 	b.ResetTimer()
@@ -48,7 +48,7 @@ func BenchmarkDeposit(b *testing.B) {
 }
 
 func BenchmarkWithdraw(b *testing.B) {
-	transaction := Transaction{Amount: 50.0}
+	transaction := Transaction{Amount: eur(50.0)}
 	jsonData, _ := json.Marshal(transaction)
 
 	b.ResetTimer()
@@ -62,7 +62,7 @@ func BenchmarkWithdraw(b *testing.B) {
 }
 
 func BenchmarkTransfer(b *testing.B) {
-	transaction := Transaction{Amount: 100.0, To: "recipient"}
+	transaction := Transaction{Amount: eur(100.0), To: "recipient"}
 	jsonData, _ := json.Marshal(transaction)
 
 	b.ResetTimer()
@@ -77,7 +77,7 @@ func BenchmarkTransfer(b *testing.B) {
 
 func BenchmarkConvert(b *testing.B) {
 
-	convert := Transaction{Amount: 100.0, BaseCurrency: "eur", TargetCurrency: "usd"}
+	convert := Transaction{Amount: eur(100.0), BaseCurrency: "eur", TargetCurrency: "usd"}
 	conversionData, _ := json.Marshal(convert)
 
 	b.ResetTimer()
@@ -102,8 +102,8 @@ func BenchmarkFullTransactionFlow(b *testing.B) {
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	transaction := Transaction{Amount: 100.0}
-	convert := Transaction{Amount: 100.0, BaseCurrency: "eur", TargetCurrency: "usd"}
+	transaction := Transaction{Amount: eur(100.0)}
+	convert := Transaction{Amount: eur(100.0), BaseCurrency: "eur", TargetCurrency: "usd"}
 	transactionData, _ := json.Marshal(transaction)
 	conversionData, _ := json.Marshal(convert)
 
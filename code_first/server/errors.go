@@ -0,0 +1,74 @@
+package server
+
+import (
+	"code_first/bank"
+	"errors"
+	"net/http"
+)
+
+// bankErrors maps errors of the bank package to the HTTP status and the
+// problem code reported to the client. The first error err wraps wins.
+var bankErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{bank.ErrAccountNotFound, http.StatusNotFound, "account_not_found"},
+	{bank.ErrTransactionNotFound, http.StatusNotFound, "transaction_not_found"},
+	{bank.ErrStandingOrderNotFound, http.StatusNotFound, "standing_order_not_found"},
+	{bank.ErrQuoteNotFound, http.StatusNotFound, "quote_not_found"},
+	{bank.ErrPaymentNotFound, http.StatusNotFound, "payment_not_found"},
+	{bank.ErrNonPositiveAmount, http.StatusBadRequest, "non_positive_amount"},
+	{bank.ErrInvalidAmount, http.StatusBadRequest, "invalid_amount"},
+	{bank.ErrCurrencyMismatch, http.StatusBadRequest, "currency_mismatch"},
+	{bank.ErrMoneyOverflow, http.StatusBadRequest, "amount_out_of_range"},
+	{bank.ErrSameCurrency, http.StatusBadRequest, "same_currency"},
+	{bank.ErrUnknownCurrency, http.StatusBadRequest, "unknown_currency"},
+	{bank.ErrInvalidQuery, http.StatusBadRequest, "invalid_query"},
+	{bank.ErrInvalidPeriod, http.StatusBadRequest, "invalid_period"},
+	{bank.ErrInvalidImport, http.StatusBadRequest, "invalid_import"},
+	{bank.ErrInvalidIBAN, http.StatusBadRequest, "invalid_iban"},
+	{bank.ErrInvalidAccountID, http.StatusBadRequest, "invalid_account_id"},
+	{bank.ErrInvalidBIC, http.StatusBadRequest, "invalid_bic"},
+	{bank.ErrWeakPassword, http.StatusBadRequest, "weak_password"},
+	{bank.ErrInvalidCredentials, http.StatusUnauthorized, "invalid_credentials"},
+	{bank.ErrInsufficientFunds, http.StatusUnprocessableEntity, "insufficient_funds"},
+	{bank.ErrSelfTransfer, http.StatusUnprocessableEntity, "self_transfer"},
+	{bank.ErrRecipientNotFound, http.StatusUnprocessableEntity, "recipient_not_found"},
+	{bank.ErrNotSEPA, http.StatusUnprocessableEntity, "not_sepa"},
+	{bank.ErrIdempotencyKeyReused, http.StatusUnprocessableEntity, "idempotency_key_reused"},
+	{bank.ErrAccountFrozen, http.StatusConflict, "account_frozen"},
+	{bank.ErrAccountClosed, http.StatusConflict, "account_closed"},
+	{bank.ErrAccountExists, http.StatusConflict, "account_exists"},
+	{bank.ErrCustomerExists, http.StatusConflict, "customer_exists"},
+	{bank.ErrAlreadyReversed, http.StatusConflict, "already_reversed"},
+	{bank.ErrNotReversible, http.StatusConflict, "not_reversible"},
+	{bank.ErrQuoteUsed, http.StatusConflict, "quote_used"},
+	{bank.ErrIdempotencyKeyInFlight, http.StatusConflict, "idempotency_key_in_flight"},
+	{bank.ErrQuoteExpired, http.StatusGone, "quote_expired"},
+	{bank.ErrRateUnavailable, http.StatusBadGateway, "rate_unavailable"},
+}
+
+// lookupError returns the status and code of the bank error err wraps,
+// fallback and its status code for errors the table does not know.
+func lookupError(err error, fallback int) (int, string) {
+	for _, known := range bankErrors {
+		if errors.Is(err, known.err) {
+			return known.status, known.code
+		}
+	}
+	return fallback, statusCode(fallback)
+}
+
+// writeError reports err as a problem, errors the table does not know as
+// server errors.
+func writeError(w http.ResponseWriter, err error) {
+	writeErrorOr(w, err, http.StatusInternalServerError)
+}
+
+// writeErrorOr is writeError for handlers whose unknown errors are not
+// server errors, such as validation errors without a sentinel.
+func writeErrorOr(w http.ResponseWriter, err error, fallback int) {
+	status, code := lookupError(err, fallback)
+	writeProblem(w, status, code, err.Error())
+}
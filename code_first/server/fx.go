@@ -0,0 +1,90 @@
+package server
+
+import (
+	"code_first/bank"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type QuoteRequest struct {
+	Account        string        `json:"account"`
+	Amount         bank.Money    `json:"amount"`
+	BaseCurrency   bank.Currency `json:"base"`
+	TargetCurrency bank.Currency `json:"target"`
+	Reference      string        `json:"reference"`
+}
+
+// createQuote prices an exchange without booking it. The customer executes
+// the quote within its expiry to get the quoted rate.
+func createQuote(w http.ResponseWriter, req *http.Request) {
+	var request QuoteRequest
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		writeInvalidJSON(w)
+		return
+	}
+
+	account, err := bank.Store().Get(request.Account)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !account.OwnedBy(customerFrom(req)) {
+		httpError(w, "account does not belong to you", http.StatusForbidden)
+		return
+	}
+
+	amount, err := exchangeAmount(Transaction{Amount: request.Amount, BaseCurrency: request.BaseCurrency})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	quote, err := bank.Quotes().Create(account, amount, request.TargetCurrency, request.Reference)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	respond(w, req, http.StatusCreated, quote)
+}
+
+func getQuote(w http.ResponseWriter, req *http.Request) {
+	quote, ok := loadQuote(w, req)
+	if !ok {
+		return
+	}
+	respond(w, req, http.StatusOK, quote)
+}
+
+func executeQuote(w http.ResponseWriter, req *http.Request) {
+	quote, ok := loadQuote(w, req)
+	if !ok {
+		return
+	}
+
+	executed, err := bank.Quotes().Execute(quote.Id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	respond(w, req, http.StatusOK, executed)
+}
+
+// loadQuote finds a quote of the customer, other customers' quotes are
+// reported as not found.
+func loadQuote(w http.ResponseWriter, req *http.Request) (*bank.FXQuote, bool) {
+	quote, err := bank.Quotes().Get(req.PathValue("id"))
+	if err == nil {
+		var account *bank.Account
+		if account, err = bank.Store().Get(quote.AccountID); err == nil && !account.OwnedBy(customerFrom(req)) {
+			err = bank.ErrQuoteNotFound
+		}
+	}
+	if err != nil {
+		if errors.Is(err, bank.ErrAccountNotFound) {
+			err = bank.ErrQuoteNotFound
+		}
+		writeError(w, err)
+		return nil, false
+	}
+	return quote, true
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"code_first/bank"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestQuotesAPI(t *testing.T) {
+	useRatesArchive(t)
+	bank.SetStore(bank.NewMemoryStore())
+	bank.SetCustomers(bank.NewMemoryCustomerStore())
+	now := time.Now()
+	quotes := bank.NewMemoryQuoteStore()
+	quotes.Clock = func() time.Time { return now }
+	original := bank.Quotes()
+	bank.SetQuotes(quotes)
+	t.Cleanup(func() { bank.SetQuotes(original) })
+	router := NewRouter()
+
+	alice := loginAs(t, router, "alice")
+	bob := loginAs(t, router, "bob")
+	if rr := doRequest(t, router, http.MethodPost, "/accounts", alice, NewAccount{Id: "a1", Name: "Alice", AccountType: bank.Savings}); rr.Code != http.StatusCreated {
+		t.Fatalf("create account: got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := doRequest(t, router, http.MethodPost, "/accounts/a1/deposits", alice, Transaction{Amount: eur(100)}); rr.Code != http.StatusOK {
+		t.Fatalf("deposit: got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	newQuote := func(token string, request QuoteRequest) (*httptest.ResponseRecorder, bank.FXQuote) {
+		t.Helper()
+		rr := doRequest(t, router, http.MethodPost, "/fx/quotes", token, request)
+		var quote bank.FXQuote
+		if rr.Code == http.StatusCreated {
+			if err := json.Unmarshal(rr.Body.Bytes(), &quote); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rr, quote
+	}
+
+	rr, quote := newQuote(alice, QuoteRequest{Account: "a1", Amount: eur(50), TargetCurrency: bank.USD})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("quote: got %d: %s", rr.Code, rr.Body.String())
+	}
+	// 1.0921 less 0.5% is 1.0866395, 50 EUR buy 54.331975 USD.
+	if quote.Rate != "1.0866395" || quote.MidRate != "1.0921" || quote.Buy != bank.NewMoney(5433, bank.USD) {
+		t.Errorf("quote = %+v, want 54.33 USD at 1.0866395", quote)
+	}
+	_, expiring := newQuote(alice, QuoteRequest{Account: "a1", Amount: eur(10), TargetCurrency: bank.USD})
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		body     any
+		wantCode int
+	}{
+		{"quote for another customer's account", http.MethodPost, "/fx/quotes", bob, QuoteRequest{Account: "a1", Amount: eur(1), TargetCurrency: bank.USD}, http.StatusForbidden},
+		{"quote for unknown account", http.MethodPost, "/fx/quotes", alice, QuoteRequest{Account: "zz", Amount: eur(1), TargetCurrency: bank.USD}, http.StatusNotFound},
+		{"quote into same currency", http.MethodPost, "/fx/quotes", alice, QuoteRequest{Account: "a1", Amount: eur(1), TargetCurrency: bank.EUR}, http.StatusBadRequest},
+		{"quote beyond balance", http.MethodPost, "/fx/quotes", alice, QuoteRequest{Account: "a1", Amount: eur(101), TargetCurrency: bank.USD}, http.StatusUnprocessableEntity},
+		{"quote without rate", http.MethodPost, "/fx/quotes", alice, QuoteRequest{Account: "a1", Amount: eur(1), TargetCurrency: bank.GBP}, http.StatusBadGateway},
+		{"view own quote", http.MethodGet, "/fx/quotes/" + quote.Id, alice, nil, http.StatusOK},
+		{"view other customer's quote", http.MethodGet, "/fx/quotes/" + quote.Id, bob, nil, http.StatusNotFound},
+		{"execute other customer's quote", http.MethodPost, "/fx/quotes/" + quote.Id + "/execute", bob, nil, http.StatusNotFound},
+		{"execute", http.MethodPost, "/fx/quotes/" + quote.Id + "/execute", alice, nil, http.StatusOK},
+		{"execute twice", http.MethodPost, "/fx/quotes/" + quote.Id + "/execute", alice, nil, http.StatusConflict},
+		{"execute unknown", http.MethodPost, "/fx/quotes/q_missing/execute", alice, nil, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := doRequest(t, router, tt.method, tt.path, tt.token, tt.body)
+			if rr.Code != tt.wantCode {
+				t.Errorf("got %d, want %d: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+		})
+	}
+
+	now = now.Add(bank.DefaultQuoteTTL)
+	if rr := doRequest(t, router, http.MethodPost, "/fx/quotes/"+expiring.Id+"/execute", alice, nil); rr.Code != http.StatusGone {
+		t.Errorf("execute expired quote: got %d, want %d", rr.Code, http.StatusGone)
+	}
+
+	rr = doRequest(t, router, http.MethodGet, "/accounts/a1", alice, nil)
+	var acc bank.Account
+	if err := json.Unmarshal(rr.Body.Bytes(), &acc); err != nil {
+		t.Fatal(err)
+	}
+	if acc.Balance != eur(50) || acc.BalanceIn(bank.USD) != bank.NewMoney(5433, bank.USD) {
+		t.Errorf("balances = %v and %v, want 50.00 EUR and 54.33 USD", acc.Balance, acc.SubBalances)
+	}
+}
@@ -0,0 +1,17 @@
+package server
+
+import (
+	"code_first/bank"
+	"net/http"
+)
+
+// validateIBAN serves GET /ibans/{iban}. A valid IBAN, in electronic or
+// print form, is returned taken apart with the BIC of its bank if known.
+func validateIBAN(w http.ResponseWriter, req *http.Request) {
+	iban, err := bank.ParseIBAN(req.PathValue("iban"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	respond(w, req, http.StatusOK, iban.Info())
+}
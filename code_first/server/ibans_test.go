@@ -0,0 +1,33 @@
+package server
+
+import (
+	"code_first/bank"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestIBANsAPI(t *testing.T) {
+	router := NewRouter()
+
+	rr := doRequest(t, router, http.MethodGet, "/ibans/DE89%203704%200044%200532%200130%2000", "", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("valid IBAN: got %d: %s", rr.Code, rr.Body.String())
+	}
+	var info bank.IBANInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.IBAN != "DE89370400440532013000" || info.PrintFormat != "DE89 3704 0044 0532 0130 00" || info.BankCode != "37040044" {
+		t.Errorf("info = %+v", info)
+	}
+
+	rr = doRequest(t, router, http.MethodGet, "/ibans/DE88370400440532013000", "", nil)
+	var problem Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusBadRequest || problem.Code != "invalid_iban" {
+		t.Errorf("wrong check digits: got %d %+v", rr.Code, problem)
+	}
+}
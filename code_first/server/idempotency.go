@@ -0,0 +1,127 @@
+package server
+
+import (
+	"bytes"
+	"code_first/bank"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	idempotencyHeader = "Idempotency-Key"
+	replayedHeader    = "Idempotent-Replayed"
+	maxIdempotencyKey = 255
+	maxIdempotentBody = 1 << 20
+)
+
+// responseRecorder holds back the status and body of a response until it is
+// stored, headers go straight to the ResponseWriter.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(data)
+}
+
+// send writes the held back response.
+func (r *responseRecorder) send() {
+	r.ResponseWriter.WriteHeader(r.status)
+	r.ResponseWriter.Write(r.body.Bytes())
+}
+
+// idempotent lets clients safely retry a request by sending an
+// Idempotency-Key header. The first response for a key is stored and replayed
+// for retries with the same payload, a different payload under the same key
+// is rejected. Server errors are not stored so the request can be retried,
+// and a response that cannot be stored is answered with a server error.
+func idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		key := strings.TrimSpace(req.Header.Get(idempotencyHeader))
+		if key == "" {
+			next(w, req)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			httpError(w, "Idempotency-Key is too long", http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxIdempotentBody))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			httpError(w, fmt.Sprintf("request body is larger than %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			httpError(w, "could not read request body", http.StatusBadRequest)
+			return
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		scoped := strings.Join([]string{customerFrom(req), req.Method, req.URL.Path, key}, " ")
+		sum := sha256.Sum256(append([]byte(req.URL.RawQuery+"\n"), body...))
+		fingerprint := hex.EncodeToString(sum[:])
+
+		record, err := bank.Idempotency().Begin(scoped, fingerprint)
+		switch {
+		case err != nil:
+			writeError(w, err)
+			return
+		case record != nil:
+			for name, values := range record.Header {
+				w.Header()[name] = values
+			}
+			w.Header().Set(replayedHeader, "true")
+			w.WriteHeader(record.Status)
+			w.Write(record.Body)
+			return
+		}
+
+		// Releasing a completed key does nothing, releasing it here frees it
+		// even if the handler panics.
+		defer bank.Idempotency().Release(scoped)
+
+		rec := &responseRecorder{ResponseWriter: w}
+		next(rec, req)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		if rec.status >= http.StatusInternalServerError {
+			rec.send()
+			return
+		}
+
+		record = &bank.IdempotencyRecord{
+			Key:         scoped,
+			Fingerprint: fingerprint,
+			Status:      rec.status,
+			Body:        rec.body.Bytes(),
+		}
+		if contentType := w.Header().Values("Content-Type"); len(contentType) > 0 {
+			record.Header = map[string][]string{"Content-Type": contentType}
+		}
+		if err := bank.Idempotency().Complete(*record); err != nil {
+			writeError(w, fmt.Errorf("could not store the response for the Idempotency-Key: %w", err))
+			return
+		}
+		rec.send()
+	}
+}
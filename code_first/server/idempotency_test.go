@@ -0,0 +1,116 @@
+package server
+
+import (
+	"code_first/bank"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func idempotentRequest(handler http.Handler, path, token, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	if key != "" {
+		req.Header.Set(idempotencyHeader, key)
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	bank.SetStore(bank.NewMemoryStore())
+	bank.SetCustomers(bank.NewMemoryCustomerStore())
+	bank.SetIdempotencyStore(bank.NewMemoryIdempotencyStore(time.Hour))
+	router := NewRouter()
+
+	token := loginAs(t, router, "alice")
+	if rr := doRequest(t, router, http.MethodPost, "/accounts", token, NewAccount{Id: "a1", Name: "Alice", AccountType: bank.Savings}); rr.Code != http.StatusCreated {
+		t.Fatalf("create: got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	const deposit = `{"amount": "25.00 EUR"}`
+	first := idempotentRequest(router, "/accounts/a1/deposits", token, "dep-1", deposit)
+	if first.Code != http.StatusOK {
+		t.Fatalf("first deposit: got %d: %s", first.Code, first.Body.String())
+	}
+
+	retry := idempotentRequest(router, "/accounts/a1/deposits", token, "dep-1", deposit)
+	if retry.Code != http.StatusOK || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry = %d %q, want replay of %q", retry.Code, retry.Body.String(), first.Body.String())
+	}
+	if retry.Header().Get(replayedHeader) != "true" {
+		t.Errorf("retry is missing the %s header", replayedHeader)
+	}
+
+	if rr := idempotentRequest(router, "/accounts/a1/deposits", token, "dep-1", `{"amount": "30.00 EUR"}`); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reused key: got %d, want %d", rr.Code, http.StatusUnprocessableEntity)
+	}
+
+	if rr := idempotentRequest(router, "/accounts/a1/deposits", token, "", deposit); rr.Code != http.StatusOK {
+		t.Errorf("deposit without key: got %d", rr.Code)
+	}
+
+	if rr := idempotentRequest(router, "/accounts/a1/withdrawals", token, "wd-1", `{"amount": "500.00 EUR"}`); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("failed withdrawal: got %d, want %d", rr.Code, http.StatusUnprocessableEntity)
+	}
+	if rr := idempotentRequest(router, "/accounts/a1/withdrawals", token, "wd-1", `{"amount": "500.00 EUR"}`); rr.Code != http.StatusUnprocessableEntity || rr.Header().Get(replayedHeader) != "true" {
+		t.Errorf("retried failed withdrawal: got %d, want the stored %d", rr.Code, http.StatusUnprocessableEntity)
+	}
+
+	oversized := `{"amount": "1.00 EUR", "reference": "` + strings.Repeat("x", maxIdempotentBody) + `"}`
+	if rr := idempotentRequest(router, "/accounts/a1/deposits", token, "dep-2", oversized); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized deposit: got %d, want %d", rr.Code, http.StatusRequestEntityTooLarge)
+	}
+
+	account, err := bank.Store().Get("a1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != eur(50) {
+		t.Errorf("balance = %v, want 50 after one retried and one plain deposit", account.Balance)
+	}
+}
+
+func TestIdempotencyKeyReleasedAfterPanic(t *testing.T) {
+	bank.SetIdempotencyStore(bank.NewMemoryIdempotencyStore(time.Hour))
+
+	panicking := idempotent(func(w http.ResponseWriter, req *http.Request) { panic("handler failed") })
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("handler did not panic")
+			}
+		}()
+		idempotentRequest(panicking, "/deposit", "", "dep-1", `{}`)
+	}()
+
+	ok := idempotent(func(w http.ResponseWriter, req *http.Request) { w.WriteHeader(http.StatusOK) })
+	if rr := idempotentRequest(ok, "/deposit", "", "dep-1", `{}`); rr.Code != http.StatusOK {
+		t.Errorf("retry after the panic: got %d, want %d", rr.Code, http.StatusOK)
+	}
+}
+
+func TestIdempotentResponseNotStored(t *testing.T) {
+	idempotency, err := bank.NewIdempotencyStore(filepath.Join(t.TempDir(), "missing", "idempotency.json"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bank.SetIdempotencyStore(idempotency)
+
+	ok := idempotent(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("booked"))
+	})
+	rr := idempotentRequest(ok, "/deposit", "", "dep-1", `{}`)
+	if rr.Code != http.StatusInternalServerError || strings.Contains(rr.Body.String(), "booked") {
+		t.Errorf("unstored response = %d %q, want a server error", rr.Code, rr.Body.String())
+	}
+	if record, err := idempotency.Begin(" POST /deposit dep-1", ""); err != nil || record != nil {
+		t.Errorf("key after the failed store = %+v, %v, want it released without a record", record, err)
+	}
+}
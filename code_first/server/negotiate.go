@@ -0,0 +1,110 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	jsonContentType = "application/json"
+	textContentType = "text/plain"
+	csvContentType  = "text/csv"
+)
+
+// textBody and csvBody are responses that can also be written as plain
+// text or CSV.
+type textBody interface {
+	WriteText(w io.Writer) error
+}
+
+type csvBody interface {
+	WriteCSV(w io.Writer) error
+}
+
+// respond writes v as JSON, or as text or CSV if v can be written that way
+// and the Accept header prefers it. Requests that accept none of these get
+// 406.
+func respond(w http.ResponseWriter, req *http.Request, status int, v any) {
+	offers := []string{jsonContentType}
+	text, isText := v.(textBody)
+	if isText {
+		offers = append(offers, textContentType)
+	}
+	csv, isCSV := v.(csvBody)
+	if isCSV {
+		offers = append(offers, csvContentType)
+	}
+
+	w.Header().Add("Vary", "Accept")
+	switch negotiate(req.Header.Get("Accept"), offers) {
+	case jsonContentType:
+		writeJSON(w, status, v)
+	case textContentType:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		text.WriteText(w)
+	case csvContentType:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.WriteHeader(status)
+		csv.WriteCSV(w)
+	default:
+		httpError(w, fmt.Sprintf("can respond with %s only", strings.Join(offers, ", ")), http.StatusNotAcceptable)
+	}
+}
+
+// negotiate picks the offer the Accept header gives the highest quality,
+// the earlier offer on a tie. The quality of an offer comes from the most
+// specific media range matching it. Without an Accept header the first
+// offer is taken, an empty result means none is acceptable.
+func negotiate(accept string, offers []string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+
+	type mediaRange struct {
+		mediaType string
+		quality   float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{mediaType, quality})
+	}
+
+	best, bestQuality := "", 0.0
+	for _, offer := range offers {
+		kind, _, _ := strings.Cut(offer, "/")
+		quality, specificity := 0.0, -1
+		for _, r := range ranges {
+			s := -1
+			switch r.mediaType {
+			case offer:
+				s = 2
+			case kind + "/*":
+				s = 1
+			case "*/*":
+				s = 0
+			}
+			if s > specificity {
+				quality, specificity = r.quality, s
+			}
+		}
+		if quality > bestQuality {
+			best, bestQuality = offer, quality
+		}
+	}
+	return best
+}
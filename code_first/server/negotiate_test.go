@@ -0,0 +1,139 @@
+package server
+
+import (
+	"code_first/bank"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{jsonContentType, textContentType, csvContentType}
+
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", jsonContentType},
+		{"*/*", jsonContentType},
+		{"application/json", jsonContentType},
+		{"text/csv", csvContentType},
+		{"text/*", textContentType},
+		{"text/plain;q=0.5, text/csv", csvContentType},
+		{"text/*;q=0.9, text/plain;q=0.1, */*;q=0.2", csvContentType},
+		{"text/html,application/xhtml+xml,*/*;q=0.8", jsonContentType},
+		{"image/png", ""},
+		{"application/json;q=0", ""},
+		{"nonsense, text/csv", csvContentType},
+	}
+
+	for _, tt := range tests {
+		if got := negotiate(tt.accept, offers); got != tt.want {
+			t.Errorf("negotiate(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+	if got := negotiate("text/plain", offers[:1]); got != "" {
+		t.Errorf("text for a JSON-only response: got %q", got)
+	}
+}
+
+func TestLegacyResponseBodies(t *testing.T) {
+	setupTestAccount()
+
+	req := httptest.NewRequest(http.MethodPost, "/deposit", strings.NewReader(`{"amount":"25.00","reference":"rent"}`))
+	rr := httptest.NewRecorder()
+	deposit(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("deposit: got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	var booking BookingResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &booking); err != nil {
+		t.Fatal(err)
+	}
+	if booking.Balance != eur(125) {
+		t.Errorf("balance = %v, want 125", booking.Balance)
+	}
+	if len(booking.Booked) != 1 || booking.Booked[0].Id == "" || booking.Booked[0].Reference != "rent" {
+		t.Errorf("booked = %+v", booking.Booked)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/withdraw", strings.NewReader(`{"amount":"5.00"}`))
+	req.Header.Set("Accept", "text/csv")
+	rr = httptest.NewRecorder()
+	withdraw(rr, req)
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/csv") || !strings.HasPrefix(rr.Body.String(), "Date,Transaction ID,Type") {
+		t.Errorf("CSV withdrawal: %s %q", rr.Header().Get("Content-Type"), rr.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/show?type=deposit", nil)
+	rr = httptest.NewRecorder()
+	showAccountDetails(rr, req)
+	var shown bank.Account
+	if err := json.Unmarshal(rr.Body.Bytes(), &shown); err != nil {
+		t.Fatalf("show: %v: %s", err, rr.Body.String())
+	}
+	if shown.Balance != eur(120) || len(shown.Transactions) != 1 || rr.Header().Get("X-Total-Count") != "1" {
+		t.Errorf("show = %+v, total %s", shown, rr.Header().Get("X-Total-Count"))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/show", nil)
+	req.Header.Set("Accept", "text/plain")
+	rr = httptest.NewRecorder()
+	showAccountDetails(rr, req)
+	if body := rr.Body.String(); !strings.HasPrefix(body, "Balance: 120.00\n") || !strings.Contains(body, "Reference: rent") {
+		t.Errorf("text show = %q", body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/show", nil)
+	req.Header.Set("Accept", "image/png")
+	rr = httptest.NewRecorder()
+	showAccountDetails(rr, req)
+	if rr.Code != http.StatusNotAcceptable {
+		t.Errorf("image/png: got %d", rr.Code)
+	}
+}
+
+func TestProblemResponses(t *testing.T) {
+	setupTestAccount()
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		method     string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"insufficient funds", withdraw, http.MethodPost, `{"amount":"1000.00"}`, http.StatusUnprocessableEntity, "insufficient_funds"},
+		{"zero amount", deposit, http.MethodPost, `{"amount":"0"}`, http.StatusBadRequest, "non_positive_amount"},
+		{"unknown recipient", transfer, http.MethodPost, `{"amount":"1.00","to":"nobody"}`, http.StatusUnprocessableEntity, "recipient_not_found"},
+		{"invalid json", deposit, http.MethodPost, `{bad json}`, http.StatusBadRequest, "invalid_json"},
+		{"invalid method", deposit, http.MethodGet, ``, http.StatusMethodNotAllowed, "method_not_allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			tt.handler(rr, httptest.NewRequest(tt.method, "/", strings.NewReader(tt.body)))
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("got %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if got := rr.Header().Get("Content-Type"); got != problemContentType {
+				t.Errorf("Content-Type = %q", got)
+			}
+			var problem Problem
+			if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+			if problem.Status != tt.wantStatus || problem.Code != tt.wantCode || problem.Title != http.StatusText(tt.wantStatus) || problem.Detail == "" {
+				t.Errorf("problem = %+v, want code %s", problem, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"code_first/bank"
+	"net/http"
+)
+
+// bookTransfer books the transfer of a request. A creditor name or BIC
+// makes it a SEPA credit transfer to the IBAN in To, otherwise To may be
+// any recipient Transfer accepts.
+func bookTransfer(account *bank.Account, transaction Transaction) (*bank.Receipt, error) {
+	if transaction.CreditorName == "" && transaction.CreditorBIC == "" {
+		return account.BookTransfer(transaction.Amount, transaction.To, transaction.Reference)
+	}
+
+	iban, err := bank.ParseIBAN(transaction.To)
+	if err != nil {
+		return nil, err
+	}
+	creditor := bank.Party{Name: transaction.CreditorName, IBAN: iban, BIC: transaction.CreditorBIC}
+	return account.BookSEPATransfer(transaction.Amount, creditor, transaction.Reference)
+}
+
+// listPayments serves the SEPA credit transfers of the account, oldest
+// first.
+func listPayments(w http.ResponseWriter, req *http.Request) {
+	account, ok := loadAccount(w, req)
+	if !ok {
+		return
+	}
+	respond(w, req, http.StatusOK, bank.Payments().List(account.Id))
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"code_first/bank"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestPaymentsAPI(t *testing.T) {
+	bank.SetStore(bank.NewMemoryStore())
+	bank.SetPayments(bank.NewMemoryPaymentStore())
+	router := NewRouter()
+	token := loginAs(t, router, "alice")
+
+	newAcc := NewAccount{Id: "a1", Name: "Alice", AccountType: bank.Giro}
+	if rr := doRequest(t, router, http.MethodPost, "/accounts", token, newAcc); rr.Code != http.StatusCreated {
+		t.Fatalf("create account: got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := doRequest(t, router, http.MethodPost, "/accounts/a1/deposits", token, Transaction{Amount: eur(100)}); rr.Code != http.StatusOK {
+		t.Fatalf("deposit: got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	tests := []struct {
+		name     string
+		body     Transaction
+		wantCode int
+		wantErr  string
+	}{
+		{"external IBAN", Transaction{Amount: eur(20), To: "NL91 ABNA 0417 1643 00", Reference: "invoice"}, http.StatusOK, ""},
+		{"named creditor", Transaction{Amount: eur(5), To: "NL91ABNA0417164300", CreditorName: "Bob", CreditorBIC: "ABNANL2A"}, http.StatusOK, ""},
+		{"creditor without IBAN", Transaction{Amount: eur(5), To: "Bob", CreditorName: "Bob"}, http.StatusBadRequest, "invalid_iban"},
+		{"outside SEPA", Transaction{Amount: eur(5), To: "BR1800360305000010009795493C1"}, http.StatusUnprocessableEntity, "not_sepa"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := doRequest(t, router, http.MethodPost, "/accounts/a1/transfers", token, tt.body)
+			if rr.Code != tt.wantCode {
+				t.Fatalf("got %d, want %d: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+			if tt.wantErr == "" {
+				return
+			}
+			var problem Problem
+			if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil || problem.Code != tt.wantErr {
+				t.Errorf("problem = %+v, %v, want code %s", problem, err, tt.wantErr)
+			}
+		})
+	}
+
+	rr := doRequest(t, router, http.MethodGet, "/accounts/a1/payments", token, nil)
+	var payments []bank.Payment
+	if err := json.Unmarshal(rr.Body.Bytes(), &payments); err != nil {
+		t.Fatal(err)
+	}
+	if len(payments) != 2 || payments[0].Status != bank.PaymentQueued || payments[0].Reference != "invoice" || payments[1].Creditor.Name != "Bob" {
+		t.Errorf("payments = %+v, want both transfers queued", payments)
+	}
+
+	other := loginAs(t, router, "mallory")
+	if rr := doRequest(t, router, http.MethodGet, "/accounts/a1/payments", other, nil); rr.Code != http.StatusForbidden {
+		t.Errorf("payments of another customer: got %d, want %d", rr.Code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 9457 problem details body. Code names the error for
+// clients that need to tell errors apart, detail is for people.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Code   string `json:"code"`
+}
+
+func writeProblem(w http.ResponseWriter, status int, code, detail string) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   cmp.Or(code, statusCode(status)),
+	})
+}
+
+// httpError replaces http.Error for errors without a bank error behind
+// them, the code is the status text like bad_request.
+func httpError(w http.ResponseWriter, detail string, status int) {
+	writeProblem(w, status, "", detail)
+}
+
+func writeInvalidJSON(w http.ResponseWriter) {
+	writeProblem(w, http.StatusBadRequest, "invalid_json", "Invalid Json")
+}
+
+func statusCode(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"code_first/bank"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// maxRatesRange caps the time series a single request may ask for.
+const maxRatesRange = 366 * 24 * time.Hour
+
+type RatesResponse struct {
+	Base   bank.Currency    `json:"base"`
+	Target bank.Currency    `json:"target"`
+	Rates  []bank.DatedRate `json:"rates"`
+}
+
+// exchangeRates serves GET /rates?base=&target=&from=&to=. Without from it
+// returns the latest rate, with from the daily rates up to to or today.
+func exchangeRates(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	if query.Get("base") == "" || query.Get("target") == "" {
+		httpError(w, "base and target are required", http.StatusBadRequest)
+		return
+	}
+	base, err := bank.ParseCurrency(query.Get("base"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	target, err := bank.ParseCurrency(query.Get("target"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if base == target {
+		writeError(w, fmt.Errorf("%w: %s", bank.ErrSameCurrency, base.Code()))
+		return
+	}
+
+	from, err := parseDate(query.Get("from"))
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseDate(query.Get("to"))
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := RatesResponse{Base: base, Target: target}
+	if from.IsZero() {
+		if !to.IsZero() {
+			httpError(w, "to requires from", http.StatusBadRequest)
+			return
+		}
+		rate, err := bank.ExchangeRate(base, target, time.Time{})
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		response.Rates = []bank.DatedRate{rate}
+		respond(w, req, http.StatusOK, response)
+		return
+	}
+
+	if to.IsZero() {
+		to = time.Now().UTC().Truncate(24 * time.Hour)
+	}
+	if to.Before(from) {
+		httpError(w, "from is after to", http.StatusBadRequest)
+		return
+	}
+	if to.Sub(from) > maxRatesRange {
+		httpError(w, "range is longer than a year", http.StatusBadRequest)
+		return
+	}
+	if response.Rates, err = bank.Rates().Series(base, target, from, to); err != nil {
+		writeError(w, err)
+		return
+	}
+	respond(w, req, http.StatusOK, response)
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"code_first/bank"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const ratesArchive = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<Cube>
+		<Cube time="2024-01-04"><Cube currency="USD" rate="1.09"/></Cube>
+		<Cube time="2024-01-05"><Cube currency="USD" rate="1.0921"/><Cube currency="JPY" rate="160"/><Cube currency="BHD" rate="0.4"/></Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+// useRatesArchive serves the rates of ratesArchive for the test.
+func useRatesArchive(t *testing.T) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "hist.xml")
+	if err := os.WriteFile(path, []byte(ratesArchive), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := bank.LoadStaticRates(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := bank.Rates()
+	bank.SetRates(archive)
+	t.Cleanup(func() { bank.SetRates(original) })
+}
+
+func TestRatesAPI(t *testing.T) {
+	useRatesArchive(t)
+	bank.SetStore(bank.NewMemoryStore())
+	bank.SetCustomers(bank.NewMemoryCustomerStore())
+	router := NewRouter()
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantRates []string
+	}{
+		{"latest", "base=EUR&target=USD", http.StatusOK, []string{"2024-01-05 1.0921"}},
+		{"series", "base=eur&target=usd&from=2024-01-01&to=2024-01-31", http.StatusOK, []string{"2024-01-04 1.09", "2024-01-05 1.0921"}},
+		{"inverse", "base=USD&target=EUR&from=2024-01-04&to=2024-01-04", http.StatusOK, []string{"2024-01-04 0.9174311927"}},
+		{"missing target", "base=EUR", http.StatusBadRequest, nil},
+		{"same currency", "base=EUR&target=EUR", http.StatusBadRequest, nil},
+		{"to without from", "base=EUR&target=USD&to=2024-01-05", http.StatusBadRequest, nil},
+		{"from after to", "base=EUR&target=USD&from=2024-01-05&to=2024-01-04", http.StatusBadRequest, nil},
+		{"bad date", "base=EUR&target=USD&from=05.01.2024", http.StatusBadRequest, nil},
+		{"range too long", "base=EUR&target=USD&from=2022-01-01&to=2024-01-05", http.StatusBadRequest, nil},
+		{"no rate", "base=EUR&target=GBP", http.StatusBadGateway, nil},
+		{"numeric code", "base=978&target=840", http.StatusOK, []string{"2024-01-05 1.0921"}},
+		{"unknown currency", "base=EUR&target=XYZ", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := doRequest(t, router, http.MethodGet, "/rates?"+tt.query, "", nil)
+			if rr.Code != tt.wantCode {
+				t.Fatalf("got %d, want %d: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+			if tt.wantRates == nil {
+				return
+			}
+			var response struct {
+				Rates []struct{ Date, Rate string }
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, rate := range response.Rates {
+				got = append(got, rate.Date+" "+rate.Rate)
+			}
+			if len(got) != len(tt.wantRates) {
+				t.Fatalf("rates = %v, want %v", got, tt.wantRates)
+			}
+			for i := range got {
+				if got[i] != tt.wantRates[i] {
+					t.Errorf("rates = %v, want %v", got, tt.wantRates)
+				}
+			}
+		})
+	}
+
+	token := loginAs(t, router, "alice")
+	if rr := doRequest(t, router, http.MethodPost, "/accounts", token, NewAccount{Id: "a1", Name: "Alice", AccountType: bank.Savings}); rr.Code != http.StatusCreated {
+		t.Fatalf("create account: got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := doRequest(t, router, http.MethodPost, "/accounts/a1/deposits", token, Transaction{Amount: eur(100)}); rr.Code != http.StatusOK {
+		t.Fatalf("deposit: got %d: %s", rr.Code, rr.Body.String())
+	}
+	// Customers cannot pick the rate of another day.
+	rr := doRequest(t, router, http.MethodPost, "/accounts/a1/exchanges", token, map[string]string{"amount": "10.00 EUR", "target": "USD", "value_date": "2024-01-04"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("exchange: got %d: %s", rr.Code, rr.Body.String())
+	}
+	var acc bank.Account
+	if err := json.Unmarshal(rr.Body.Bytes(), &acc); err != nil {
+		t.Fatal(err)
+	}
+	last := acc.Transactions[len(acc.Transactions)-1]
+	// The latest rate less the spread of 0.5%.
+	if acc.BalanceIn(bank.USD) != bank.NewMoney(1086, bank.USD) || last.Rate != "1.0866395" || last.RateDate.Format(time.DateOnly) != "2024-01-05" {
+		t.Errorf("account = %v with %+v, want 10.86 USD at the latest rate of 2024-01-05", acc.SubBalances, last)
+	}
+}
+
+func TestExchangeAmountWithoutCurrency(t *testing.T) {
+	useRatesArchive(t)
+	bank.SetStore(bank.NewMemoryStore())
+	router := NewRouter()
+	token := loginAs(t, router, "alice")
+
+	if rr := doRequest(t, router, http.MethodPost, "/accounts", token, NewAccount{Id: "a1", Name: "Alice", AccountType: bank.Savings}); rr.Code != http.StatusCreated {
+		t.Fatalf("create account: got %d: %s", rr.Code, rr.Body.String())
+	}
+	for _, amount := range []string{"10000 JPY", "100.000 BHD"} {
+		if rr := doRequest(t, router, http.MethodPost, "/accounts/a1/deposits", token, map[string]string{"amount": amount}); rr.Code != http.StatusOK {
+			t.Fatalf("deposit %s: got %d: %s", amount, rr.Code, rr.Body.String())
+		}
+	}
+
+	// An amount without currency counts in units of the base currency,
+	// whatever its minor units.
+	tests := []struct {
+		name     string
+		body     map[string]string
+		wantCode int
+		want     bank.Money
+	}{
+		{"yen", map[string]string{"amount": "100", "base": "JPY", "target": "EUR"}, http.StatusOK, bank.NewMoney(9900, bank.JPY)},
+		{"yen with decimals", map[string]string{"amount": "100.50", "base": "JPY", "target": "EUR"}, http.StatusBadRequest, bank.NewMoney(9900, bank.JPY)},
+		{"dinar", map[string]string{"amount": "10", "base": "BHD", "target": "EUR"}, http.StatusOK, bank.NewMoney(90000, "bhd")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := doRequest(t, router, http.MethodPost, "/accounts/a1/exchanges", token, tt.body)
+			if rr.Code != tt.wantCode {
+				t.Fatalf("got %d, want %d: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+			account, err := bank.Store().Get("a1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := account.BalanceIn(tt.want.Currency); got != tt.want {
+				t.Errorf("balance = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -8,128 +8,162 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var acc *bank.Account
 
 type Transaction struct {
-	Amount         float64       `json:"amount"`
+	Amount         bank.Money    `json:"amount"`
 	To             string        `json:"to"`
 	BaseCurrency   bank.Currency `json:"base"`
 	TargetCurrency bank.Currency `json:"target"`
+	Reference      string        `json:"reference"`
+	// CreditorName and CreditorBIC describe the recipient of a transfer
+	// to an IBAN at another bank.
+	CreditorName string `json:"creditor_name,omitempty"`
+	CreditorBIC  string `json:"creditor_bic,omitempty"`
 }
 
+// showAccountDetails returns the default account, or the account held by
+// name, with the transactions selected by the query parameters.
 func showAccountDetails(w http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodGet {
-		http.Error(w, "Invalid method", http.StatusMethodNotAllowed)
+		httpError(w, "Invalid method", http.StatusMethodNotAllowed)
 		return
 	}
 
 	request := req.URL.Query()
 
-	err := acc.ShowAccountDetails(w,
-		request.Get("name"),
-		request.Get("criteria"),
-		request.Get("filter"))
+	query, err := bank.ParseTransactionQuery(request)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
-}
 
-func deposit(w http.ResponseWriter, req *http.Request) {
-	if req.Method != http.MethodPost {
-		http.Error(w, "Invalid method", http.StatusMethodNotAllowed)
+	var account *bank.Account
+	if name := request.Get("name"); name != "" {
+		if account, err = bank.Store().FindByName(name); err != nil {
+			// An unknown name is a bad parameter, not a missing resource.
+			_, code := lookupError(err, http.StatusBadRequest)
+			writeProblem(w, http.StatusBadRequest, code, err.Error())
+			return
+		}
+	} else if account, err = acc.Snapshot(); err != nil {
+		writeError(w, err)
 		return
 	}
+	var total int
+	account.Transactions, total = query.Run(account.Transactions)
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	respond(w, req, http.StatusOK, AccountResponse{account})
+}
 
-	var transaction Transaction
-
-	err := json.NewDecoder(req.Body).Decode(&transaction)
-	if err != nil {
-		http.Error(w, "Invalid Json", http.StatusBadRequest)
+func deposit(w http.ResponseWriter, req *http.Request) {
+	transaction, ok := legacyRequest(w, req)
+	if !ok {
 		return
 	}
 
-	err = acc.Deposit(transaction.Amount)
+	receipt, err := acc.BookDeposit(transaction.Amount, transaction.Reference)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
+	respond(w, req, http.StatusOK, bookingResponse(receipt))
 }
 
 func transfer(w http.ResponseWriter, req *http.Request) {
-	if req.Method != http.MethodPost {
-		http.Error(w, "Invalid method", http.StatusMethodNotAllowed)
+	transaction, ok := legacyRequest(w, req)
+	if !ok {
 		return
 	}
 
-	var transaction Transaction
-
-	err := json.NewDecoder(req.Body).Decode(&transaction)
+	receipt, err := bookTransfer(acc, transaction)
 	if err != nil {
-		http.Error(w, "Invalid Json", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
+	respond(w, req, http.StatusOK, bookingResponse(receipt))
+}
 
-	err = acc.Transfer(transaction.Amount, transaction.To)
-	if err != nil {
-		fmt.Println(err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+func withdraw(w http.ResponseWriter, req *http.Request) {
+	transaction, ok := legacyRequest(w, req)
+	if !ok {
 		return
 	}
 
+	receipt, err := acc.BookWithdrawal(transaction.Amount, transaction.Reference)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	respond(w, req, http.StatusOK, bookingResponse(receipt))
 }
 
-func withdraw(w http.ResponseWriter, req *http.Request) {
-	if req.Method != http.MethodPost {
-		http.Error(w, "Invalid method", http.StatusMethodNotAllowed)
+func convert(w http.ResponseWriter, req *http.Request) {
+	transaction, ok := legacyRequest(w, req)
+	if !ok {
 		return
 	}
 
-	var transaction Transaction
-
-	err := json.NewDecoder(req.Body).Decode(&transaction)
+	amount, err := exchangeAmount(transaction)
 	if err != nil {
-		http.Error(w, "Invalid Json", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
-
-	err = acc.Withdraw(transaction.Amount)
+	// Customers always exchange at the current rate.
+	receipt, err := acc.BookExchange(amount, transaction.TargetCurrency, time.Time{}, transaction.Reference)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
+	respond(w, req, http.StatusOK, bookingResponse(receipt))
 }
 
-func convert(w http.ResponseWriter, req *http.Request) {
+// legacyRequest checks the method of the single-account booking routes and
+// decodes their body.
+func legacyRequest(w http.ResponseWriter, req *http.Request) (Transaction, bool) {
+	var transaction Transaction
 	if req.Method != http.MethodPost {
-		http.Error(w, "Invalid method", http.StatusMethodNotAllowed)
-		return
+		httpError(w, "Invalid method", http.StatusMethodNotAllowed)
+		return transaction, false
 	}
-
-	var transaction Transaction
-
-	err := json.NewDecoder(req.Body).Decode(&transaction)
-	if err != nil {
-		http.Error(w, "Invalid Json", http.StatusBadRequest)
-		return
+	if err := json.NewDecoder(req.Body).Decode(&transaction); err != nil {
+		writeInvalidJSON(w)
+		return transaction, false
 	}
+	return transaction, true
+}
 
-	converted, err := bank.ConvertCurrency(transaction.Amount, transaction.BaseCurrency, transaction.TargetCurrency)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+// exchangeAmount is the amount to sell, the currency may be given with the
+// amount or as base.
+func exchangeAmount(transaction Transaction) (bank.Money, error) {
+	amount := transaction.Amount
+	if transaction.BaseCurrency != "" && amount.Currency != "" && amount.Currency != transaction.BaseCurrency {
+		return bank.Money{}, fmt.Errorf("%w: %s != %s", bank.ErrCurrencyMismatch, amount.Currency.Code(), transaction.BaseCurrency.Code())
 	}
+	return amount.InCurrency(transaction.BaseCurrency)
+}
 
-	err = acc.Withdraw(*converted)
+// parseDate reads an optional YYYY-MM-DD date, an empty value is the zero
+// time.
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	day, err := time.Parse(time.DateOnly, value)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+		return time.Time{}, fmt.Errorf("invalid date %q, want YYYY-MM-DD", value)
 	}
+	return day, nil
 }
 
 func InitializeAcc(args []string) error {
+	if len(args) < 2 {
+		return bank.InitialAccounts()
+	}
+
 	var accType bank.AccountType
 	var argsLenght int
 	switch strings.ToLower(args[1]) {
@@ -147,14 +181,17 @@ func InitializeAcc(args []string) error {
 		return errors.New("Please passe Id, Name, Balance and Account Type")
 	}
 
-	balance, err := strconv.ParseFloat(args[4], 64)
+	balance, err := bank.ParseMoney(args[4], bank.DefaultCurrency)
 	if err != nil {
 		return errors.New("Please give valid balance number")
 	}
 
-	overdraw, err := strconv.ParseFloat(args[5], 64)
-	if err != nil && accType == bank.Giro {
-		return errors.New("Please give valid overdraw value")
+	var overdraw bank.Money
+	if accType == bank.Giro {
+		overdraw, err = bank.ParseMoney(args[5], bank.DefaultCurrency)
+		if err != nil {
+			return errors.New("Please give valid overdraw value")
+		}
 	}
 
 	acc = &bank.Account{
@@ -165,17 +202,64 @@ func InitializeAcc(args []string) error {
 		Overdraw:    overdraw,
 	}
 
-	bank.InitialAccounts()
-	return nil
+	if err := bank.OpenAccount(acc); err != nil && !errors.Is(err, bank.ErrAccountExists) {
+		return err
+	}
+	return bank.InitialAccounts()
 }
 
-func Router() {
+func NewRouter() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/show", requireDefaultAccount(authenticated(ownsDefaultAccount(ownsNamedAccount(showAccountDetails)))))
+	mux.HandleFunc("/deposit", requireDefaultAccount(authenticated(ownsDefaultAccount(idempotent(deposit)))))
+	mux.HandleFunc("/transfer", requireDefaultAccount(authenticated(ownsDefaultAccount(idempotent(transfer)))))
+	mux.HandleFunc("/withdraw", requireDefaultAccount(authenticated(ownsDefaultAccount(idempotent(withdraw)))))
+	mux.HandleFunc("/convert", requireDefaultAccount(authenticated(ownsDefaultAccount(idempotent(convert)))))
+
+	mux.HandleFunc("GET /rates", exchangeRates)
+	mux.HandleFunc("GET /ibans/{iban}", validateIBAN)
+	mux.HandleFunc("POST /fx/quotes", authenticated(idempotent(createQuote)))
+	mux.HandleFunc("GET /fx/quotes/{id}", authenticated(getQuote))
+	mux.HandleFunc("POST /fx/quotes/{id}/execute", authenticated(idempotent(executeQuote)))
+
+	mux.HandleFunc("POST /customers", registerCustomer)
+	mux.HandleFunc("POST /login", login)
+	mux.HandleFunc("POST /logout", authenticated(logout))
+
+	mux.HandleFunc("GET /accounts", authenticated(listAccounts))
+	mux.HandleFunc("POST /accounts", authenticated(createAccount))
+	mux.HandleFunc("GET /accounts/{id}", authenticated(getAccount))
+	mux.HandleFunc("POST /accounts/{id}/deposits", authenticated(idempotent(depositToAccount)))
+	mux.HandleFunc("POST /accounts/{id}/withdrawals", authenticated(idempotent(withdrawFromAccount)))
+	mux.HandleFunc("POST /accounts/{id}/transfers", authenticated(idempotent(transferFromAccount)))
+	mux.HandleFunc("POST /accounts/{id}/exchanges", authenticated(idempotent(exchangeOnAccount)))
+	mux.HandleFunc("GET /accounts/{id}/transactions", authenticated(listTransactions))
+	mux.HandleFunc("GET /accounts/{id}/statements", authenticated(accountStatement))
+	mux.HandleFunc("GET /accounts/{id}/payments", authenticated(listPayments))
+
+	mux.HandleFunc("GET /accounts/{id}/standing-orders", authenticated(listStandingOrders))
+	mux.HandleFunc("POST /accounts/{id}/standing-orders", authenticated(idempotent(createStandingOrder)))
+	mux.HandleFunc("GET /accounts/{id}/standing-orders/{order}", authenticated(getStandingOrder))
+	mux.HandleFunc("PUT /accounts/{id}/standing-orders/{order}", authenticated(updateStandingOrder))
+	mux.HandleFunc("DELETE /accounts/{id}/standing-orders/{order}", authenticated(deleteStandingOrder))
+
+	mux.HandleFunc("GET /transactions/{id}", authenticated(getTransaction))
+	mux.HandleFunc("POST /transactions/{id}/reversal", authenticated(idempotent(reverseTransaction)))
+
+	return mux
+}
 
-	http.HandleFunc("/show", showAccountDetails)
-	http.HandleFunc("/deposit", deposit)
-	http.HandleFunc("/transfer", transfer)
-	http.HandleFunc("/withdraw", withdraw)
-	http.HandleFunc("/convert", convert)
+func Router() {
+	http.ListenAndServe(":8090", NewRouter())
+}
 
-	http.ListenAndServe(":8090", nil)
+func requireDefaultAccount(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if acc == nil {
+			httpError(w, "no default account configured", http.StatusNotFound)
+			return
+		}
+		next(w, req)
+	}
 }
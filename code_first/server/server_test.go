@@ -4,18 +4,31 @@ import (
 	"bytes"
 	"code_first/bank"
 	"encoding/json"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"sync"
 	"testing"
 )
 
+func TestMain(m *testing.M) {
+	bank.SetStore(bank.NewMemoryStore())
+	os.Exit(m.Run())
+}
+
+func eur(amount float64) bank.Money {
+	return bank.NewMoney(int64(math.Round(amount*100)), bank.EUR)
+}
+
 func setupTestAccount() {
+	bank.SetStore(bank.NewMemoryStore())
 	acc = &bank.Account{
 		Id:          "123",
 		Name:        "Alice",
-		Balance:     100.0,
+		Balance:     eur(100.0),
 		AccountType: bank.Giro,
-		Overdraw:    50.0,
+		Overdraw:    eur(50.0),
 	}
 }
 
@@ -56,8 +69,8 @@ func TestDeposit(t *testing.T) {
 		body     any
 		wantCode int
 	}{
-		{"valid deposit", http.MethodPost, Transaction{Amount: 50}, http.StatusOK},
-		{"invalid method", http.MethodGet, Transaction{Amount: 50}, http.StatusMethodNotAllowed},
+		{"valid deposit", http.MethodPost, Transaction{Amount: eur(50)}, http.StatusOK},
+		{"invalid method", http.MethodGet, Transaction{Amount: eur(50)}, http.StatusMethodNotAllowed},
 		{"invalid json", http.MethodPost, "{bad json}", http.StatusBadRequest},
 	}
 
@@ -91,9 +104,9 @@ func TestWithdraw(t *testing.T) {
 		body     any
 		wantCode int
 	}{
-		{"valid withdraw", http.MethodPost, Transaction{Amount: 30}, http.StatusOK},
-		{"overdraw attempt", http.MethodPost, Transaction{Amount: 1000}, http.StatusBadRequest},
-		{"invalid method", http.MethodGet, Transaction{Amount: 20}, http.StatusMethodNotAllowed},
+		{"valid withdraw", http.MethodPost, Transaction{Amount: eur(30)}, http.StatusOK},
+		{"overdraw attempt", http.MethodPost, Transaction{Amount: eur(1000)}, http.StatusUnprocessableEntity},
+		{"invalid method", http.MethodGet, Transaction{Amount: eur(20)}, http.StatusMethodNotAllowed},
 		{"invalid json", http.MethodPost, "{bad json}", http.StatusBadRequest},
 	}
 
@@ -120,6 +133,9 @@ func TestWithdraw(t *testing.T) {
 
 func TestTransfer(t *testing.T) {
 	setupTestAccount()
+	if err := bank.OpenAccount(&bank.Account{Id: "456", Name: "Bob", Balance: eur(0), AccountType: bank.Savings}); err != nil {
+		t.Fatal(err)
+	}
 
 	tests := []struct {
 		name     string
@@ -127,9 +143,13 @@ func TestTransfer(t *testing.T) {
 		body     any
 		wantCode int
 	}{
-		{"invalid method", http.MethodGet, Transaction{Amount: 20, To: "Bob"}, http.StatusMethodNotAllowed},
+		{"invalid method", http.MethodGet, Transaction{Amount: eur(20), To: "Bob"}, http.StatusMethodNotAllowed},
 		{"invalid json", http.MethodPost, "{bad json}", http.StatusBadRequest},
-		{"insufficient funds", http.MethodPost, Transaction{Amount: 2000, To: "Bob"}, http.StatusBadRequest},
+		{"insufficient funds", http.MethodPost, Transaction{Amount: eur(2000), To: "Bob"}, http.StatusUnprocessableEntity},
+		{"unknown recipient", http.MethodPost, Transaction{Amount: eur(20), To: "Charlie"}, http.StatusUnprocessableEntity},
+		{"self transfer", http.MethodPost, Transaction{Amount: eur(20), To: "123"}, http.StatusUnprocessableEntity},
+		{"zero amount", http.MethodPost, Transaction{Amount: eur(0), To: "Bob"}, http.StatusBadRequest},
+		{"within overdraft", http.MethodPost, Transaction{Amount: eur(120), To: "456"}, http.StatusOK},
 	}
 
 	for _, tt := range tests {
@@ -152,3 +172,56 @@ func TestTransfer(t *testing.T) {
 		})
 	}
 }
+
+func TestConcurrentHandlers(t *testing.T) {
+	setupTestAccount()
+	if err := bank.OpenAccount(&bank.Account{Id: "456", Name: "Bob", AccountType: bank.Giro}); err != nil {
+		t.Fatal(err)
+	}
+
+	depositBody, _ := json.Marshal(Transaction{Amount: eur(3)})
+	withdrawBody, _ := json.Marshal(Transaction{Amount: eur(1)})
+	transferBody, _ := json.Marshal(Transaction{Amount: eur(1), To: "Bob"})
+
+	const rounds = 25
+	var wg sync.WaitGroup
+	for range rounds {
+		for _, call := range []struct {
+			handler http.HandlerFunc
+			body    []byte
+		}{
+			{deposit, depositBody},
+			{withdraw, withdrawBody},
+			{transfer, transferBody},
+			{showAccountDetails, nil},
+		} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				method := http.MethodPost
+				if call.body == nil {
+					method = http.MethodGet
+				}
+				rr := httptest.NewRecorder()
+				call.handler(rr, httptest.NewRequest(method, "/", bytes.NewReader(call.body)))
+				if rr.Code != http.StatusOK {
+					t.Errorf("got %d: %s", rr.Code, rr.Body.String())
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	stored, err := bank.Store().Get("123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := eur(100 + rounds*(3-1-1)); stored.Balance != want {
+		t.Errorf("balance = %v, want %v", stored.Balance, want)
+	}
+
+	bob, _ := bank.Store().Get("456")
+	if bob.Balance != eur(rounds) {
+		t.Errorf("Bob balance = %v, want %v", bob.Balance, eur(rounds))
+	}
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"code_first/bank"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type StandingOrderRequest struct {
+	To        string     `json:"to"`
+	Amount    bank.Money `json:"amount"`
+	Reference string     `json:"reference"`
+	Schedule  string     `json:"schedule"`
+	Start     time.Time  `json:"start"`
+	End       time.Time  `json:"end"`
+	Retry     *struct {
+		MaxAttempts int    `json:"max_attempts"`
+		Interval    string `json:"interval"`
+	} `json:"retry"`
+}
+
+func (r StandingOrderRequest) order(accountID string) (bank.StandingOrder, error) {
+	order := bank.StandingOrder{
+		AccountID: accountID,
+		To:        r.To,
+		Amount:    r.Amount,
+		Reference: r.Reference,
+		Schedule:  r.Schedule,
+		Start:     r.Start,
+		End:       r.End,
+	}
+	if r.Retry != nil {
+		order.Retry.MaxAttempts = r.Retry.MaxAttempts
+		if r.Retry.Interval != "" {
+			interval, err := time.ParseDuration(r.Retry.Interval)
+			if err != nil {
+				return order, errors.New("retry interval must be a duration like 24h")
+			}
+			order.Retry.Interval = interval
+		}
+	}
+	return order, nil
+}
+
+func createStandingOrder(w http.ResponseWriter, req *http.Request) {
+	account, ok := loadAccount(w, req)
+	if !ok {
+		return
+	}
+
+	order, ok := decodeStandingOrder(w, req, account.Id)
+	if !ok {
+		return
+	}
+	if order.Start.IsZero() {
+		order.Start = time.Now()
+	}
+
+	created, err := bank.StandingOrders().Create(order)
+	if err != nil {
+		writeErrorOr(w, err, http.StatusBadRequest)
+		return
+	}
+	respond(w, req, http.StatusCreated, created)
+}
+
+func listStandingOrders(w http.ResponseWriter, req *http.Request) {
+	account, ok := loadAccount(w, req)
+	if !ok {
+		return
+	}
+	respond(w, req, http.StatusOK, bank.StandingOrders().List(account.Id))
+}
+
+func getStandingOrder(w http.ResponseWriter, req *http.Request) {
+	order, ok := loadStandingOrder(w, req)
+	if !ok {
+		return
+	}
+	respond(w, req, http.StatusOK, order)
+}
+
+func updateStandingOrder(w http.ResponseWriter, req *http.Request) {
+	existing, ok := loadStandingOrder(w, req)
+	if !ok {
+		return
+	}
+
+	order, ok := decodeStandingOrder(w, req, existing.AccountID)
+	if !ok {
+		return
+	}
+	order.Id = existing.Id
+	if order.Start.IsZero() {
+		order.Start = existing.Start
+	}
+
+	updated, err := bank.StandingOrders().Update(order)
+	if err != nil {
+		writeErrorOr(w, err, http.StatusBadRequest)
+		return
+	}
+	respond(w, req, http.StatusOK, updated)
+}
+
+func deleteStandingOrder(w http.ResponseWriter, req *http.Request) {
+	order, ok := loadStandingOrder(w, req)
+	if !ok {
+		return
+	}
+
+	if err := bank.StandingOrders().Delete(order.Id); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func loadStandingOrder(w http.ResponseWriter, req *http.Request) (*bank.StandingOrder, bool) {
+	account, ok := loadAccount(w, req)
+	if !ok {
+		return nil, false
+	}
+
+	order, err := bank.StandingOrders().Get(req.PathValue("order"))
+	if err != nil || order.AccountID != account.Id {
+		writeError(w, bank.ErrStandingOrderNotFound)
+		return nil, false
+	}
+	return order, true
+}
+
+func decodeStandingOrder(w http.ResponseWriter, req *http.Request, accountID string) (bank.StandingOrder, bool) {
+	var request StandingOrderRequest
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		writeInvalidJSON(w)
+		return bank.StandingOrder{}, false
+	}
+
+	order, err := request.order(accountID)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return bank.StandingOrder{}, false
+	}
+	return order, true
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"code_first/bank"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestStandingOrdersAPI(t *testing.T) {
+	bank.SetStore(bank.NewMemoryStore())
+	bank.SetCustomers(bank.NewMemoryCustomerStore())
+	orders := bank.NewMemoryStandingOrderStore()
+	orders.Clock = func() time.Time { return time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC) }
+	bank.SetStandingOrders(orders)
+	router := NewRouter()
+
+	alice := loginAs(t, router, "alice")
+	mallory := loginAs(t, router, "mallory")
+	if rr := doRequest(t, router, http.MethodPost, "/accounts", alice, NewAccount{Id: "a1", Name: "Alice", AccountType: bank.Savings}); rr.Code != http.StatusCreated {
+		t.Fatalf("create account: got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rent := map[string]any{
+		"to":       "Landlord",
+		"amount":   "300.00 EUR",
+		"schedule": "monthly 1",
+		"start":    "2026-11-01T00:00:00Z",
+		"retry":    map[string]any{"max_attempts": 5, "interval": "12h"},
+	}
+	rr := doRequest(t, router, http.MethodPost, "/accounts/a1/standing-orders", alice, rent)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create order: got %d: %s", rr.Code, rr.Body.String())
+	}
+	var order bank.StandingOrder
+	if err := json.Unmarshal(rr.Body.Bytes(), &order); err != nil {
+		t.Fatal(err)
+	}
+	if order.Retry.MaxAttempts != 5 || order.NextRun.Format("2006-01-02") != "2026-11-01" {
+		t.Errorf("created order = %+v", order)
+	}
+
+	path := "/accounts/a1/standing-orders/" + order.Id
+	rent["amount"] = "350.00 EUR"
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		body     any
+		wantCode int
+	}{
+		{"invalid schedule", http.MethodPost, "/accounts/a1/standing-orders", alice, map[string]any{"to": "Bob", "amount": "1 EUR", "schedule": "sometimes"}, http.StatusBadRequest},
+		{"start in the past", http.MethodPost, "/accounts/a1/standing-orders", alice, map[string]any{"to": "Bob", "amount": "1 EUR", "schedule": "@daily", "start": "2026-09-01T00:00:00Z"}, http.StatusBadRequest},
+		{"other customer creates", http.MethodPost, "/accounts/a1/standing-orders", mallory, rent, http.StatusForbidden},
+		{"list", http.MethodGet, "/accounts/a1/standing-orders", alice, nil, http.StatusOK},
+		{"get", http.MethodGet, path, alice, nil, http.StatusOK},
+		{"other customer reads", http.MethodGet, path, mallory, nil, http.StatusForbidden},
+		{"update", http.MethodPut, path, alice, rent, http.StatusOK},
+		{"update without start", http.MethodPut, path, alice, map[string]any{"to": "Landlord", "amount": "350.00 EUR", "schedule": "monthly 1", "reference": "rent"}, http.StatusOK},
+		{"unknown order", http.MethodGet, "/accounts/a1/standing-orders/so_unknown", alice, nil, http.StatusNotFound},
+		{"other customer deletes", http.MethodDelete, path, mallory, nil, http.StatusForbidden},
+		{"delete", http.MethodDelete, path, alice, nil, http.StatusNoContent},
+		{"get deleted", http.MethodGet, path, alice, nil, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := doRequest(t, router, tt.method, tt.path, tt.token, tt.body)
+			if rr.Code != tt.wantCode {
+				t.Errorf("got %d, want %d: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+			if tt.name == "update" || tt.name == "update without start" {
+				var updated bank.StandingOrder
+				json.Unmarshal(rr.Body.Bytes(), &updated)
+				if updated.Amount != eur(350) || updated.Retry.MaxAttempts != 5 || !updated.NextRun.Equal(order.NextRun) {
+					t.Errorf("updated order = %+v, want amount 350, 5 attempts and the next run kept", updated)
+				}
+			}
+		})
+	}
+}
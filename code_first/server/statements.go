@@ -0,0 +1,85 @@
+package server
+
+import (
+	"code_first/bank"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// statementFormats maps the format parameter of GET
+// /accounts/{id}/statements to the content type and file extension of the
+// export.
+var statementFormats = map[string]struct {
+	contentType string
+	extension   string
+	write       func(*bank.Statement, io.Writer) error
+}{
+	"csv":      {"text/csv; charset=utf-8", "csv", (*bank.Statement).WriteCSV},
+	"ofx":      {"application/x-ofx", "ofx", (*bank.Statement).WriteOFX},
+	"camt.053": {"application/xml", "xml", (*bank.Statement).WriteCAMT053},
+	"camt053":  {"application/xml", "xml", (*bank.Statement).WriteCAMT053},
+}
+
+// accountStatement serves GET /accounts/{id}/statements?from=&to=&format=
+// &currency=. from and to are dates, to is inclusive. Without a format the
+// statement is returned as JSON or CSV as the Accept header asks, otherwise
+// as a download.
+func accountStatement(w http.ResponseWriter, req *http.Request) {
+	account, ok := loadAccount(w, req)
+	if !ok {
+		return
+	}
+
+	query := req.URL.Query()
+	from, err := parseDate(query.Get("from"))
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseDate(query.Get("to"))
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !to.IsZero() {
+		to = to.AddDate(0, 0, 1)
+	}
+	var currency bank.Currency
+	if code := query.Get("currency"); code != "" {
+		if currency, err = bank.ParseCurrency(code); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	format := strings.ToLower(query.Get("format"))
+	export, ok := statementFormats[format]
+	if !ok && format != "" && format != "json" {
+		httpError(w, fmt.Sprintf("unknown statement format %q", format), http.StatusBadRequest)
+		return
+	}
+
+	statement, err := account.Statement(from, to, currency)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	switch {
+	case format == "json":
+		writeJSON(w, http.StatusOK, statement)
+		return
+	case !ok:
+		respond(w, req, http.StatusOK, statement)
+		return
+	}
+
+	filename := fmt.Sprintf("statement-%s-%s-%s.%s", statement.AccountID,
+		statement.From.Format("20060102"), statement.To.AddDate(0, 0, -1).Format("20060102"), export.extension)
+	w.Header().Set("Content-Type", export.contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if err := export.write(statement, w); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
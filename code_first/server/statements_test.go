@@ -0,0 +1,86 @@
+package server
+
+import (
+	"code_first/bank"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatementsAPI(t *testing.T) {
+	bank.SetStore(bank.NewMemoryStore())
+	bank.SetCustomers(bank.NewMemoryCustomerStore())
+	router := NewRouter()
+
+	alice := loginAs(t, router, "alice")
+	bob := loginAs(t, router, "bob")
+	if rr := doRequest(t, router, http.MethodPost, "/accounts", alice, NewAccount{Id: "a1", Name: "Alice", AccountType: bank.Savings}); rr.Code != http.StatusCreated {
+		t.Fatalf("create account: got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := doRequest(t, router, http.MethodPost, "/accounts", bob, NewAccount{Id: "b1", Name: "Bob", AccountType: bank.Savings}); rr.Code != http.StatusCreated {
+		t.Fatalf("create account: got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := doRequest(t, router, http.MethodPost, "/accounts/a1/deposits", alice, Transaction{Amount: eur(100)}); rr.Code != http.StatusOK {
+		t.Fatalf("deposit: got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := doRequest(t, router, http.MethodPost, "/accounts/a1/transfers", alice, Transaction{Amount: eur(40), To: "b1"}); rr.Code != http.StatusOK {
+		t.Fatalf("transfer: got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	today := time.Now().UTC().Format(time.DateOnly)
+	period := "/accounts/a1/statements?from=" + today + "&to=" + today
+
+	rr := doRequest(t, router, http.MethodGet, period, alice, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("statement: got %d: %s", rr.Code, rr.Body.String())
+	}
+	var statement bank.Statement
+	if err := json.Unmarshal(rr.Body.Bytes(), &statement); err != nil {
+		t.Fatal(err)
+	}
+	if statement.Opening != eur(0) || statement.Closing != eur(60) || len(statement.Lines) != 2 || statement.Lines[1].Amount != eur(-40) {
+		t.Errorf("statement = %+v, want a deposit and a transfer from 0.00 to 60.00 EUR", statement)
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		token       string
+		wantCode    int
+		contentType string
+		contains    string
+	}{
+		{"csv", period + "&format=csv", alice, http.StatusOK, "text/csv", "closing balance,,,,EUR,60.00"},
+		{"ofx", period + "&format=ofx", alice, http.StatusOK, "application/x-ofx", "<TRNAMT>-40.00</TRNAMT>"},
+		{"camt.053", period + "&format=camt.053", alice, http.StatusOK, "application/xml", "<Cd>CLBD</Cd>"},
+		{"json", period + "&format=json", alice, http.StatusOK, "application/json", `"Closing"`},
+		{"unknown format", period + "&format=pdf", alice, http.StatusBadRequest, "", ""},
+		{"invalid date", "/accounts/a1/statements?from=01.01.2026", alice, http.StatusBadRequest, "", ""},
+		{"from after to", "/accounts/a1/statements?from=2026-02-01&to=2026-01-01", alice, http.StatusBadRequest, "", ""},
+		{"unknown currency", "/accounts/a1/statements?currency=xyz", alice, http.StatusBadRequest, "", ""},
+		{"other customer", period, bob, http.StatusForbidden, "", ""},
+		{"unknown account", "/accounts/zz/statements", alice, http.StatusNotFound, "", ""},
+		{"anonymous", period, "", http.StatusUnauthorized, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := doRequest(t, router, http.MethodGet, tt.path, tt.token, nil)
+			if rr.Code != tt.wantCode {
+				t.Fatalf("got %d, want %d: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+			if got := rr.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.contentType) {
+				t.Errorf("content type = %q, want %q", got, tt.contentType)
+			}
+			if !strings.Contains(rr.Body.String(), tt.contains) {
+				t.Errorf("body does not contain %q:\n%s", tt.contains, rr.Body.String())
+			}
+		})
+	}
+
+	rr = doRequest(t, router, http.MethodGet, period+"&format=ofx", alice, nil)
+	if want := `attachment; filename="statement-a1-` + strings.ReplaceAll(today, "-", ""); !strings.HasPrefix(rr.Header().Get("Content-Disposition"), want) {
+		t.Errorf("content disposition = %q, want %q...", rr.Header().Get("Content-Disposition"), want)
+	}
+}
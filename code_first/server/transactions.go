@@ -0,0 +1,95 @@
+package server
+
+import (
+	"code_first/bank"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+type ReversalRequest struct {
+	Reference string `json:"reference"`
+}
+
+func getTransaction(w http.ResponseWriter, req *http.Request) {
+	bookings, ok := ownedBookings(w, req, req.PathValue("id"))
+	if !ok {
+		return
+	}
+	respond(w, req, http.StatusOK, bookings)
+}
+
+// reverseTransaction lets a customer undo a booking as long as every account
+// the reversal takes money from is theirs, e.g. the recipient refunding a
+// transfer. Reversals that pay out of the bank's own accounts, like undoing
+// a withdrawal, stay with the back office.
+func reverseTransaction(w http.ResponseWriter, req *http.Request) {
+	txID := req.PathValue("id")
+
+	var reversal ReversalRequest
+	if err := json.NewDecoder(req.Body).Decode(&reversal); err != nil && !errors.Is(err, io.EOF) {
+		writeInvalidJSON(w)
+		return
+	}
+
+	if _, ok := ownedBookings(w, req, txID); !ok {
+		return
+	}
+
+	entry, ok := bank.GeneralLedger().EntryForTransaction(txID)
+	if !ok {
+		writeError(w, bank.ErrTransactionNotFound)
+		return
+	}
+	for _, leg := range entry.Legs {
+		if leg.Side != bank.Credit {
+			continue
+		}
+		if !ownsLedgerAccount(req, leg.Account) {
+			httpError(w, "only the receiving side can reverse this transaction", http.StatusForbidden)
+			return
+		}
+	}
+
+	reversalID, err := bank.Reverse(txID, reversal.Reference)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	bookings, ok := ownedBookings(w, req, reversalID)
+	if !ok {
+		return
+	}
+	respond(w, req, http.StatusCreated, bookings)
+}
+
+func ownedBookings(w http.ResponseWriter, req *http.Request, txID string) ([]bank.Booking, bool) {
+	bookings, err := bank.FindTransaction(txID)
+	if err != nil && !errors.Is(err, bank.ErrTransactionNotFound) {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	owned := []bank.Booking{}
+	for _, booking := range bookings {
+		if ownsLedgerAccount(req, booking.AccountID) {
+			owned = append(owned, booking)
+		}
+	}
+	if len(owned) == 0 {
+		// Transactions of other customers look the same as unknown ones.
+		writeError(w, bank.ErrTransactionNotFound)
+		return nil, false
+	}
+	return owned, true
+}
+
+func ownsLedgerAccount(req *http.Request, id string) bool {
+	if bank.IsInternalAccount(id) {
+		return false
+	}
+	account, err := bank.Store().Get(id)
+	return err == nil && account.OwnedBy(customerFrom(req))
+}
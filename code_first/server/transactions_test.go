@@ -0,0 +1,90 @@
+package server
+
+import (
+	"code_first/bank"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestTransactionsAPI(t *testing.T) {
+	bank.SetStore(bank.NewMemoryStore())
+	bank.SetCustomers(bank.NewMemoryCustomerStore())
+	router := NewRouter()
+
+	alice := loginAs(t, router, "alice")
+	bob := loginAs(t, router, "bob")
+	mallory := loginAs(t, router, "mallory")
+
+	for token, newAcc := range map[string]NewAccount{
+		alice: {Id: "a1", Name: "Alice", AccountType: bank.Savings},
+		bob:   {Id: "b1", Name: "Bob", AccountType: bank.Savings},
+	} {
+		if rr := doRequest(t, router, http.MethodPost, "/accounts", token, newAcc); rr.Code != http.StatusCreated {
+			t.Fatalf("create %s: got %d: %s", newAcc.Id, rr.Code, rr.Body.String())
+		}
+	}
+
+	lastTransaction := func(token, path string, body Transaction) string {
+		t.Helper()
+		rr := doRequest(t, router, http.MethodPost, path, token, body)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: got %d: %s", path, rr.Code, rr.Body.String())
+		}
+		var acc bank.Account
+		if err := json.Unmarshal(rr.Body.Bytes(), &acc); err != nil {
+			t.Fatal(err)
+		}
+		return acc.Transactions[len(acc.Transactions)-1].Id
+	}
+
+	lastTransaction(bob, "/accounts/b1/deposits", Transaction{Amount: eur(50)})
+	transferID := lastTransaction(bob, "/accounts/b1/transfers", Transaction{Amount: eur(20), To: "Alice", Reference: "invoice 7"})
+	withdrawalID := lastTransaction(bob, "/accounts/b1/withdrawals", Transaction{Amount: eur(5)})
+	depositID := lastTransaction(bob, "/accounts/b1/deposits", Transaction{Amount: eur(10)})
+
+	rr := doRequest(t, router, http.MethodGet, "/transactions/"+transferID, alice, nil)
+	var bookings []bank.Booking
+	if err := json.Unmarshal(rr.Body.Bytes(), &bookings); err != nil {
+		t.Fatal(err)
+	}
+	if len(bookings) != 1 || bookings[0].AccountID != "a1" || bookings[0].Reference != "invoice 7" {
+		t.Errorf("alice's view of the transfer = %+v, want her own booking only", bookings)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		wantCode int
+	}{
+		{"lookup by other customer", http.MethodGet, "/transactions/" + transferID, mallory, http.StatusNotFound},
+		{"lookup unknown", http.MethodGet, "/transactions/tx_unknown", alice, http.StatusNotFound},
+		{"sender reverses transfer", http.MethodPost, "/transactions/" + transferID + "/reversal", bob, http.StatusForbidden},
+		{"other customer reverses transfer", http.MethodPost, "/transactions/" + transferID + "/reversal", mallory, http.StatusNotFound},
+		{"recipient refunds transfer", http.MethodPost, "/transactions/" + transferID + "/reversal", alice, http.StatusCreated},
+		{"refund twice", http.MethodPost, "/transactions/" + transferID + "/reversal", alice, http.StatusConflict},
+		{"owner reverses deposit", http.MethodPost, "/transactions/" + depositID + "/reversal", bob, http.StatusCreated},
+		{"owner reverses withdrawal", http.MethodPost, "/transactions/" + withdrawalID + "/reversal", bob, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := doRequest(t, router, tt.method, tt.path, tt.token, ReversalRequest{Reference: "refund"})
+			if rr.Code != tt.wantCode {
+				t.Errorf("got %d, want %d: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+		})
+	}
+
+	for id, want := range map[string]bank.Money{"a1": eur(0), "b1": eur(45)} {
+		acc, err := bank.Store().Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if acc.Balance != want {
+			t.Errorf("%s balance = %v, want %v", id, acc.Balance, want)
+		}
+	}
+}